@@ -3,34 +3,65 @@ package main
 import (
 	"ddns-watchdog/internal/client"
 	"ddns-watchdog/internal/common"
-	"errors"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"log"
+	"os"
 	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	installOption   = flag.Bool("I", false, "安装服务并退出")
-	uninstallOption = flag.Bool("U", false, "卸载服务并退出")
-	enforcement     = flag.Bool("f", false, "强制检查 DNS 解析记录")
-	version         = flag.Bool("v", false, "查看当前版本并检查更新后退出")
-	initOption      = flag.String("i", "", "有选择地初始化配置文件并退出，可以组合使用 (例 01)\n"+
+	installOption   = flag.Bool("I", false, i18n.T(i18n.MsgFlagInstall))
+	uninstallOption = flag.Bool("U", false, i18n.T(i18n.MsgFlagUninstall))
+	enforcement     = flag.Bool("f", false, i18n.T(i18n.MsgFlagEnforcement))
+	version         = flag.Bool("v", false, i18n.T(i18n.MsgFlagVersion))
+	initOption      = flag.String("i", "", i18n.T(i18n.MsgFlagInitClient)+"\n"+
 		"0 -> "+client.ConfFileName+"\n"+
 		"1 -> "+client.DNSPodConfFileName+"\n"+
 		"2 -> "+client.AliDNSConfFileName+"\n"+
-		"3 -> "+client.CloudflareConfFileName)
-	confPath             = flag.String("c", "", "指定配置文件目录 (目录有空格请放在双引号中间)")
-	printNetworkCardInfo = flag.Bool("n", false, "输出网卡信息并退出")
+		"3 -> "+client.CloudflareConfFileName+"\n"+
+		"4 -> "+client.NotifyConfFileName+"\n"+
+		"5 -> "+client.HookConfFileName+"\n"+
+		"6 -> "+client.RFC2136ConfFileName+"\n"+
+		"7 -> "+client.Dyndns2ConfFileName+"\n"+
+		"8 -> "+client.TencentCloudConfFileName+"\n"+
+		"9 -> "+client.Route53ConfFileName+"\n"+
+		"a -> "+client.CustomHTTPConfFileName+"\n"+
+		"b -> "+client.LocalFileConfFileName+"\n"+
+		"c -> "+client.PowerDNSConfFileName+"\n"+
+		"d -> "+client.HuaweiCloudConfFileName)
+	confPath             = flag.String("c", "", i18n.T(i18n.MsgFlagConfPath))
+	printNetworkCardInfo = flag.Bool("n", false, i18n.T(i18n.MsgFlagNetworkCard))
+	quiet                = flag.Bool("q", false, i18n.T(i18n.MsgFlagQuiet))
+	verbose              = flag.Bool("verbose", false, i18n.T(i18n.MsgFlagVerbose))
+	lang                 = flag.String(i18n.FlagName, "", i18n.T(i18n.MsgFlagLang))
 )
 
 func main() {
-	// 初始化并处理 flag
+	flag.Parse()
+	runSetLogLevel()
+	// 子命令，全局参数 (例如 -c) 放在子命令之前
+	switch flag.Arg(0) {
+	case "":
+	case "history":
+		err := runHistory(flag.Args()[1:])
+		if err != nil {
+			logger.Fatal(err.Error())
+		}
+		return
+	default:
+		logger.Fatal(i18n.T(i18n.MsgUnknownCommand, flag.Arg(0)))
+	}
+
+	// 处理 flag
 	exit, err := runFlag()
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err.Error())
 	}
 	if exit {
 		return
@@ -39,7 +70,7 @@ func main() {
 	// 加载服务配置
 	err = runLoadConf()
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err.Error())
 	}
 
 	// 周期循环
@@ -55,7 +86,6 @@ func main() {
 }
 
 func runFlag() (exit bool, err error) {
-	flag.Parse()
 	// 打印网卡信息
 	if *printNetworkCardInfo {
 		ncr, err2 := client.NetworkCardRespond()
@@ -99,6 +129,14 @@ func runFlag() (exit bool, err error) {
 		return
 	}
 
+	// 按配置设置语言和日志
+	i18n.SetLocale(i18n.Select(*lang, client.Conf.Locale))
+	err = logger.Setup(client.Conf.Log, client.ConfDirectoryName)
+	if err != nil {
+		return
+	}
+	runSetLogLevel()
+
 	// 检查版本
 	if *version {
 		client.Conf.CheckLatestVersion()
@@ -126,6 +164,73 @@ func runFlag() (exit bool, err error) {
 	return
 }
 
+// runHistory 处理 history 子命令
+func runHistory(args []string) (err error) {
+	flagSet := flag.NewFlagSet("history", flag.ExitOnError)
+	historyConfPath := flagSet.String("c", "", i18n.T(i18n.MsgFlagConfPath))
+	event := flagSet.String("event", "", i18n.T(i18n.MsgFlagHistoryEvent, client.HistoryIPChanged, client.HistoryUpdate))
+	provider := flagSet.String("provider", "", i18n.T(i18n.MsgFlagHistoryProvider))
+	record := flagSet.String("record", "", i18n.T(i18n.MsgFlagHistoryRecord))
+	since := flagSet.String("since", "", i18n.T(i18n.MsgFlagHistorySince))
+	until := flagSet.String("until", "", i18n.T(i18n.MsgFlagHistoryUntil))
+	stats := flagSet.Bool("stats", false, i18n.T(i18n.MsgFlagHistoryStats))
+	jsonOutput := flagSet.Bool("json", false, i18n.T(i18n.MsgFlagHistoryJSON))
+	flagSet.String(i18n.FlagName, "", i18n.T(i18n.MsgFlagLang))
+	err = flagSet.Parse(args)
+	if err != nil {
+		return
+	}
+	// 子命令的 -c 优先于全局的 -c
+	if *confPath != "" {
+		client.ConfDirectoryName = common.FormatDirectoryPath(*confPath)
+	}
+	if *historyConfPath != "" {
+		client.ConfDirectoryName = common.FormatDirectoryPath(*historyConfPath)
+	}
+	filter := client.HistoryFilter{
+		Event:    *event,
+		Provider: *provider,
+		Record:   *record,
+	}
+	filter.Since, err = client.ParseHistoryTime(*since)
+	if err != nil {
+		return
+	}
+	filter.Until, err = client.ParseHistoryTime(*until)
+	if err != nil {
+		return
+	}
+	entries, err := client.ReadHistory(filter)
+	if err != nil {
+		return
+	}
+	switch {
+	case *stats:
+		client.PrintHistoryStats(os.Stdout, entries)
+	case *jsonOutput:
+		encoder := json.NewEncoder(os.Stdout)
+		for _, entry := range entries {
+			err = encoder.Encode(entry)
+			if err != nil {
+				return
+			}
+		}
+	default:
+		client.PrintHistory(os.Stdout, entries)
+	}
+	return
+}
+
+// runSetLogLevel 启动参数 -q 和 -verbose 优先于配置文件的日志级别
+func runSetLogLevel() {
+	switch {
+	case *verbose:
+		logger.SetLevel(logger.LevelDebug)
+	case *quiet:
+		logger.SetLevel(logger.LevelWarn)
+	}
+}
+
 func runInitConf(event string) error {
 	switch event {
 	case "0":
@@ -133,28 +238,87 @@ func runInitConf(event string) error {
 		if err != nil {
 			return err
 		}
-		log.Println(msg)
+		logger.Info(msg)
 	case "1":
 		msg, err := client.Dpc.InitConf()
 		if err != nil {
 			return err
 		}
-		log.Println(msg)
+		logger.Info(msg)
 	case "2":
 		msg, err := client.Adc.InitConf()
 		if err != nil {
 			return err
 		}
-		log.Println(msg)
+		logger.Info(msg)
 	case "3":
 		msg, err := client.Cfc.InitConf()
 		if err != nil {
 			return err
 		}
-		log.Println(msg)
+		logger.Info(msg)
+	case "4":
+		msg, err := client.Nc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "5":
+		msg, err := client.Hc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "6":
+		msg, err := client.Rfc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "7":
+		msg, err := client.Ddc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "8":
+		msg, err := client.Tcc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "9":
+		msg, err := client.R5c.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "a":
+		msg, err := client.Chc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "b":
+		msg, err := client.Lfc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "c":
+		msg, err := client.Pdc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
+	case "d":
+		msg, err := client.Hwc.InitConf()
+		if err != nil {
+			return err
+		}
+		logger.Info(msg)
 	default:
-		err := errors.New("你初始化了一个寂寞")
-		return err
+		return i18n.NewError(i18n.MsgInitNothing)
 	}
 	return nil
 }
@@ -178,19 +342,132 @@ func runLoadConf() (err error) {
 			return
 		}
 	}
+	if client.Conf.Services.RFC2136 {
+		err = client.Rfc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.Dyndns2 {
+		err = client.Ddc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.TencentCloud {
+		err = client.Tcc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.Route53 {
+		err = client.R5c.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.CustomHTTP {
+		err = client.Chc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.LocalFile {
+		err = client.Lfc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.PowerDNS {
+		err = client.Pdc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Services.HuaweiCloud {
+		err = client.Hwc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Enable.Notify {
+		err = client.Nc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
+	if client.Conf.Enable.Hook {
+		err = client.Hc.LoadConf()
+		if err != nil {
+			return
+		}
+	}
 	return
 }
 
 func check() {
+	oldIPv4, oldIPv6 := client.Conf.LatestIPv4, client.Conf.LatestIPv6
+	// 执行检查前钩子，失败则跳过本次检查
+	err := client.Hc.Run(client.HookEvent{
+		Hook:    client.HookPreCheck,
+		OldIPv4: oldIPv4,
+		OldIPv6: oldIPv6,
+	})
+	if err != nil {
+		logger.Warn(err.Error())
+		return
+	}
+
 	// 获取 IP
 	ipv4, ipv6, err := client.GetOwnIP(client.Conf.Enable, client.Conf.APIUrl, client.Conf.NetworkCard)
+	failed := int32(0)
+	defer func() {
+		// 执行检查后钩子
+		event := client.HookEvent{
+			Hook:    client.HookPostCheck,
+			IPv4:    ipv4,
+			IPv6:    ipv6,
+			OldIPv4: oldIPv4,
+			OldIPv6: oldIPv6,
+			Result:  client.HookResultSuccess,
+		}
+		if err != nil {
+			event.Result = client.HookResultFailed
+			event.Error = err.Error()
+		} else if failed > 0 {
+			event.Result = client.HookResultFailed
+		}
+		if hookErr := client.Hc.Run(event); hookErr != nil {
+			logger.Warn(hookErr.Error())
+		}
+	}()
 	if err != nil {
-		log.Println(err)
+		logger.Error(err.Error())
 		return
 	}
+	logger.Debug(i18n.T(i18n.MsgGotIP), "ipv4", ipv4, "ipv6", ipv6)
+	if historyErr := client.RecordIPHistory(ipv4, ipv6); historyErr != nil {
+		logger.Warn(historyErr.Error())
+	}
 
 	// 进入更新流程
 	if ipv4 != client.Conf.LatestIPv4 || ipv6 != client.Conf.LatestIPv6 || *enforcement {
+		// 首次运行没有旧 IP，不算作 IP 变化
+		if (client.Conf.LatestIPv4 != "" && ipv4 != client.Conf.LatestIPv4) ||
+			(client.Conf.LatestIPv6 != "" && ipv6 != client.Conf.LatestIPv6) {
+			logger.Info(i18n.T(i18n.MsgIPChanged), "old_ipv4", client.Conf.LatestIPv4, "new_ipv4", ipv4, "old_ipv6", client.Conf.LatestIPv6, "new_ipv6", ipv6)
+			notifyErrs := client.Nc.Send(client.NotifyEvent{
+				Event:   client.EventIPChanged,
+				Message: i18n.T(i18n.MsgIPChanged),
+				IPv4:    ipv4,
+				IPv6:    ipv6,
+				OldIPv4: client.Conf.LatestIPv4,
+				OldIPv6: client.Conf.LatestIPv6,
+			})
+			for _, row := range notifyErrs {
+				logger.Warn(row.Error())
+			}
+		}
 		if ipv4 != client.Conf.LatestIPv4 {
 			client.Conf.LatestIPv4 = ipv4
 		}
@@ -200,27 +477,60 @@ func check() {
 		wg := sync.WaitGroup{}
 		if client.Conf.Services.DNSPod {
 			wg.Add(1)
-			go asyncServiceInterface(ipv4, ipv6, client.Dpc.Run, &wg)
+			go asyncServiceInterface("DNSPod", ipv4, ipv6, client.Dpc.Run, &wg, &failed)
 		}
 		if client.Conf.Services.AliDNS {
 			wg.Add(1)
-			go asyncServiceInterface(ipv4, ipv6, client.Adc.Run, &wg)
+			go asyncServiceInterface("AliDNS", ipv4, ipv6, client.Adc.Run, &wg, &failed)
 		}
 		if client.Conf.Services.Cloudflare {
 			wg.Add(1)
-			go asyncServiceInterface(ipv4, ipv6, client.Cfc.Run, &wg)
+			go asyncServiceInterface("Cloudflare", ipv4, ipv6, client.Cfc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.RFC2136 {
+			wg.Add(1)
+			go asyncServiceInterface("RFC2136", ipv4, ipv6, client.Rfc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.Dyndns2 {
+			wg.Add(1)
+			go asyncServiceInterface("Dyndns2", ipv4, ipv6, client.Ddc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.TencentCloud {
+			wg.Add(1)
+			go asyncServiceInterface("TencentCloud", ipv4, ipv6, client.Tcc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.Route53 {
+			wg.Add(1)
+			go asyncServiceInterface("Route53", ipv4, ipv6, client.R5c.Run, &wg, &failed)
+		}
+		if client.Conf.Services.CustomHTTP {
+			wg.Add(1)
+			go asyncServiceInterface("CustomHTTP", ipv4, ipv6, client.Chc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.LocalFile {
+			wg.Add(1)
+			go asyncServiceInterface("LocalFile", ipv4, ipv6, client.Lfc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.PowerDNS {
+			wg.Add(1)
+			go asyncServiceInterface("PowerDNS", ipv4, ipv6, client.Pdc.Run, &wg, &failed)
+		}
+		if client.Conf.Services.HuaweiCloud {
+			wg.Add(1)
+			go asyncServiceInterface("HuaweiCloud", ipv4, ipv6, client.Hwc.Run, &wg, &failed)
 		}
 		wg.Wait()
 	}
 }
 
-func asyncServiceInterface(ipv4, ipv6 string, callback client.AsyncServiceCallback, wg *sync.WaitGroup) {
+func asyncServiceInterface(provider, ipv4, ipv6 string, callback client.AsyncServiceCallback, wg *sync.WaitGroup, failed *int32) {
 	defer wg.Done()
 	msg, err := callback(client.Conf.Enable, ipv4, ipv6)
-	for _, row := range err {
-		log.Println(row)
+	if len(err) > 0 {
+		atomic.AddInt32(failed, 1)
 	}
-	for _, row := range msg {
-		log.Println(row)
+	// 每条结果已在更新时输出日志，这里只发送通知
+	for _, row := range client.Nc.ReportResult(provider, msg, err) {
+		logger.Warn(row.Error(), "provider", provider)
 	}
 }
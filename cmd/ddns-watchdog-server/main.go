@@ -2,24 +2,27 @@ package main
 
 import (
 	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
 	"ddns-watchdog/internal/server"
-	"encoding/json"
 	"flag"
-	"io"
-	"log"
 	"net/http"
 )
 
 var (
-	installOption   = flag.Bool("I", false, "安装服务并退出")
-	uninstallOption = flag.Bool("U", false, "卸载服务并退出")
-	version         = flag.Bool("v", false, "查看当前版本并检查更新后退出")
-	confPath        = flag.String("c", "", "指定配置文件目录 (目录有空格请放在双引号中间)")
-	initOption      = flag.Bool("i", false, "初始化配置文件并退出")
+	installOption   = flag.Bool("I", false, i18n.T(i18n.MsgFlagInstall))
+	uninstallOption = flag.Bool("U", false, i18n.T(i18n.MsgFlagUninstall))
+	version         = flag.Bool("v", false, i18n.T(i18n.MsgFlagVersion))
+	confPath        = flag.String("c", "", i18n.T(i18n.MsgFlagConfPath))
+	initOption      = flag.Bool("i", false, i18n.T(i18n.MsgFlagInitServer))
+	quiet           = flag.Bool("q", false, i18n.T(i18n.MsgFlagQuiet))
+	verbose         = flag.Bool("verbose", false, i18n.T(i18n.MsgFlagVerbose))
+	lang            = flag.String(i18n.FlagName, "", i18n.T(i18n.MsgFlagLang))
 )
 
 func main() {
 	flag.Parse()
+	runSetLogLevel()
 	// 加载自定义配置文件目录
 	if *confPath != "" {
 		server.ConfDirectoryName = common.FormatDirectoryPath(*confPath)
@@ -29,7 +32,7 @@ func main() {
 	if *initOption {
 		err := RunInit()
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err.Error())
 		}
 		return
 	}
@@ -39,18 +42,18 @@ func main() {
 	case *installOption:
 		err := server.Install()
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err.Error())
 		}
 		// 初始化配置
 		err = RunInit()
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err.Error())
 		}
 		return
 	case *uninstallOption:
 		err := server.Uninstall()
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err.Error())
 		}
 		return
 	}
@@ -59,56 +62,169 @@ func main() {
 	conf := server.ServerConf{}
 	err := common.LoadAndUnmarshal(server.ConfDirectoryName+"/"+server.ConfFileName, &conf)
 	if err != nil {
-		log.Fatal(err)
+		logger.Fatal(err.Error())
 	}
 
+	// 按配置设置语言和日志
+	i18n.SetLocale(i18n.Select(*lang, conf.Locale))
+	err = logger.Setup(conf.Log, server.ConfDirectoryName)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+	runSetLogLevel()
+
 	if *version {
 		conf.CheckLatestVersion()
 		return
 	}
 
-	ddnsServerHandler := func(w http.ResponseWriter, req *http.Request) {
-		w.Header().Add("Cache-Control", "no-store")
-		info := common.PublicInfo{
-			IP:      server.GetClientIP(req),
-			Version: conf.GetLatestVersion(),
-		}
-		sendJson, err := json.Marshal(info)
+	resolver, err := server.NewClientIPResolver(conf.TrustedProxies)
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+	listeners, err := conf.ListenerConfs()
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+	err = conf.CheckAuth()
+	if err != nil {
+		logger.Fatal(err.Error())
+	}
+
+	var zone *server.DNSZone
+	if conf.DNS.Enable {
+		zone, err = server.NewDNSZone(conf.DNS, conf.DDNS.Hosts)
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err.Error())
 		}
-		_, err = io.WriteString(w, string(sendJson))
+		go func() {
+			err := zone.ListenAndServe()
+			if err != nil {
+				logger.Fatal(err.Error())
+			}
+		}()
+	}
+	if conf.DDNS.Enable {
+		err = conf.DDNS.LoadProviders(zone != nil)
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err.Error())
 		}
+		logger.Info(i18n.T(i18n.MsgDDNSEnabled), "hosts", len(conf.DDNS.Hosts))
+	}
+
+	limiter, err := server.NewLimiter(conf.Limit, resolver)
+	if err != nil {
+		logger.Fatal(err.Error())
 	}
 
+	versionCache := server.NewVersionCache(conf)
+	versionCache.Start()
+
 	// 路径绑定处理变量
-	http.HandleFunc("/", ddnsServerHandler)
+	mux := http.NewServeMux()
+	mux.Handle("/", limiter.Wrap(server.Handler{Conf: conf, Resolver: resolver, Zone: zone, Version: versionCache}))
 
-	// 启动监听
-	if conf.TLS.Enable {
-		log.Println("Work on", conf.Port, "with TLS")
-		err = http.ListenAndServeTLS(conf.Port, server.ConfDirectoryName+"/"+conf.TLS.CertFile, server.ConfDirectoryName+"/"+conf.TLS.KeyFile, nil)
-	} else {
-		log.Println("Work on", conf.Port)
-		err = http.ListenAndServe(conf.Port, nil)
+	var certs *server.CertManager
+	for _, listener := range listeners {
+		if listener.TLS && certs == nil {
+			certs, err = server.NewCertManager(conf.TLS)
+			if err != nil {
+				logger.Fatal(err.Error())
+			}
+			certs.Watch()
+		}
 	}
-	if err != nil {
-		log.Fatal(err)
+
+	// 启动监听，任意一个地址出错时退出
+	errs := make(chan error, len(listeners)+2)
+	for _, listener := range listeners {
+		httpServer := conf.Limit.HTTPServer(mux)
+		go func(listener server.ListenerConf) {
+			errs <- listener.Serve(httpServer, resolver, certs)
+		}(listener)
+	}
+	// HTTP 重定向和 ACME 的 HTTP-01 验证使用相同地址时共用一个监听
+	httpHandlers := make(map[string]http.Handler)
+	if conf.TLS.RedirectAddress != "" {
+		httpHandlers[conf.TLS.RedirectAddress] = server.RedirectHandler(conf.TLS.RedirectPort)
 	}
+	if certs != nil && conf.TLS.ACME.Enable && conf.TLS.ACME.HTTPAddress != "" {
+		httpHandlers[conf.TLS.ACME.HTTPAddress] = certs.HTTPHandler(httpHandlers[conf.TLS.ACME.HTTPAddress])
+	}
+	for addr, handler := range httpHandlers {
+		httpServer := conf.Limit.HTTPServer(handler)
+		httpServer.Addr = addr
+		logger.Info(i18n.T(i18n.MsgServerListen), "addr", addr, "redirect", "https", "acme", conf.TLS.ACME.Enable)
+		go func() {
+			errs <- httpServer.ListenAndServe()
+		}()
+	}
+	logger.Fatal((<-errs).Error())
 }
 
 func RunInit() (err error) {
 	conf := server.ServerConf{
-		Port:           ":10032",
-		IsRoot:         false,
-		RootServerAddr: "https://yzyweb.cn/ddns-watchdog",
+		Port:                  ":10032",
+		IsRoot:                false,
+		RootServerAddr:        "https://yzyweb.cn/ddns-watchdog",
+		VersionRefreshMinutes: 60,
+		AuthTokens:            []string{},
+		TrustedProxies:        []string{},
+		Listeners:             []server.ListenerConf{},
+		TLS: server.TLSConf{
+			Certificates: []server.CertConf{},
+			MinVersion:   "1.2",
+			CipherSuites: []string{},
+			ACME: server.ACMEConf{
+				DirectoryUrl:    "https://acme-v02.api.letsencrypt.org/directory",
+				Domains:         []string{},
+				CacheDir:        "acme",
+				HTTPAddress:     ":80",
+				RenewBeforeDays: 30,
+			},
+		},
+		DDNS: server.DDNSConf{Hosts: []server.DDNSHost{}},
+		DNS: server.DNSConf{
+			Listen:              ":53",
+			Zone:                "dyn.example.com",
+			NameServers:         []string{"ns1.example.com"},
+			NameServerAddresses: map[string][]string{},
+			Hostmaster:          "hostmaster@example.com",
+			TTL:                 60,
+		},
+		Limit: server.LimitConf{
+			Rate:                     1,
+			Burst:                    10,
+			Allow:                    []string{},
+			Deny:                     []string{},
+			ReadHeaderTimeoutSeconds: 10,
+			ReadTimeoutSeconds:       30,
+			WriteTimeoutSeconds:      30,
+			IdleTimeoutSeconds:       120,
+			MaxHeaderBytes:           16 << 10,
+			MaxBodyBytes:             64 << 10,
+		},
+		Log: logger.Conf{
+			Level:      "info",
+			Format:     logger.FormatText,
+			MaxSizeMB:  10,
+			MaxBackups: 3,
+		},
 	}
 	err = common.MarshalAndSave(conf, server.ConfDirectoryName+"/"+server.ConfFileName)
 	if err != nil {
 		return
 	}
-	log.Println("初始化 " + server.ConfDirectoryName + "/" + server.ConfFileName)
+	logger.Info(i18n.T(i18n.MsgInitConf, server.ConfDirectoryName+"/"+server.ConfFileName))
 	return
 }
+
+// runSetLogLevel 启动参数 -q 和 -verbose 优先于配置文件的日志级别
+func runSetLogLevel() {
+	switch {
+	case *verbose:
+		logger.SetLevel(logger.LevelDebug)
+	case *quiet:
+		logger.SetLevel(logger.LevelWarn)
+	}
+}
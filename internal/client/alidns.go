@@ -2,29 +2,36 @@ package client
 
 import (
 	"ddns-watchdog/internal/common"
-	"errors"
+	"ddns-watchdog/internal/i18n"
+	"github.com/aliyun/alibaba-cloud-sdk-go/sdk/requests"
 	"github.com/aliyun/alibaba-cloud-sdk-go/services/alidns"
+	"strings"
 )
 
 const AliDNSConfFileName = "alidns.json"
 
+// aliDNSPageSize DescribeSubDomainRecords 每页最多 500 条
+const aliDNSPageSize = 500
+
 type aliDNSConf struct {
 	AccessKeyId     string    `json:"accesskey_id"`
 	AccessKeySecret string    `json:"accesskey_secret"`
+	Region          string    `json:"region"`
+	Endpoint        string    `json:"endpoint"`
 	Domain          string    `json:"domain"`
 	SubDomain       subdomain `json:"sub_domain"`
-	RecordId        string    `json:"-"`
 }
 
 func (adc *aliDNSConf) InitConf() (msg string, err error) {
 	*adc = aliDNSConf{}
-	adc.AccessKeyId = "在 https://ram.console.aliyun.com/users 获取"
+	adc.AccessKeyId = i18n.T(i18n.MsgPlaceholderGetAt, "https://ram.console.aliyun.com/users")
 	adc.AccessKeySecret = adc.AccessKeyId
+	adc.Region = "cn-hangzhou"
 	adc.Domain = "example.com"
-	adc.SubDomain.A = "A记录子域名"
-	adc.SubDomain.AAAA = "AAAA记录子域名"
+	adc.SubDomain.A = i18n.T(i18n.MsgPlaceholderSubDomainA)
+	adc.SubDomain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA)
 	err = common.MarshalAndSave(adc, ConfDirectoryName+"/"+AliDNSConfFileName)
-	msg = "初始化 " + ConfDirectoryName + "/" + AliDNSConfFileName
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+AliDNSConfFileName)
 	return
 }
 
@@ -34,86 +41,104 @@ func (adc *aliDNSConf) LoadConf() (err error) {
 		return
 	}
 	if adc.AccessKeyId == "" || adc.AccessKeySecret == "" || adc.Domain == "" || (adc.SubDomain.A == "" && adc.SubDomain.AAAA == "") {
-		err = errors.New("请打开配置文件 " + ConfDirectoryName + "/" + AliDNSConfFileName + " 检查你的 accesskey_id, accesskey_secret, domain, sub_domain 并重新启动")
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+AliDNSConfFileName, "accesskey_id, accesskey_secret, domain, sub_domain")
+		return
+	}
+	// 旧的配置文件没有 region
+	if adc.Region == "" {
+		adc.Region = "cn-hangzhou"
 	}
 	return
 }
 
 func (adc aliDNSConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
 	if enabled.IPv4 && adc.SubDomain.A != "" {
-		// 获取解析记录
-		recordIP, err := adc.getParseRecord(adc.SubDomain.A, "A")
+		msgRow, err := adc.update(adc.SubDomain.A, "A", ipv4)
 		if err != nil {
 			errs = append(errs, err)
-		} else if recordIP != ipv4 {
-			// 更新解析记录
-			err = adc.updateParseRecord(ipv4, "A", adc.SubDomain.A)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				msg = append(msg, "AliDNS: "+adc.SubDomain.A+"."+adc.Domain+" 已更新解析记录 "+ipv4)
-			}
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
 		}
 	}
 	if enabled.IPv6 && adc.SubDomain.AAAA != "" {
-		// 获取解析记录
-		recordIP, err := adc.getParseRecord(adc.SubDomain.AAAA, "AAAA")
+		msgRow, err := adc.update(adc.SubDomain.AAAA, "AAAA", ipv6)
 		if err != nil {
 			errs = append(errs, err)
-		} else if recordIP != ipv6 {
-			// 更新解析记录
-			err = adc.updateParseRecord(ipv6, "AAAA", adc.SubDomain.AAAA)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				msg = append(msg, "AliDNS: "+adc.SubDomain.AAAA+"."+adc.Domain+" 已更新解析记录 "+ipv6)
-			}
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
 		}
 	}
 	return
 }
 
-func (adc *aliDNSConf) getParseRecord(subDomain, recordType string) (recordIP string, err error) {
-	client, err := alidns.NewClientWithAccessKey("cn-hangzhou", adc.AccessKeyId, adc.AccessKeySecret)
-	if err != nil {
-		return
-	}
-
-	request := alidns.CreateDescribeDomainRecordsRequest()
-	request.Scheme = "https"
-
-	request.DomainName = adc.Domain
+// update A 和 AAAA 分别查询记录，记录 ID 不会互相覆盖
+func (adc aliDNSConf) update(subDomain, recordType, ipAddr string) (msg string, err error) {
+	var recordId string
+	return updateRecord("AliDNS", subDomain+"."+adc.Domain, recordType, ipAddr,
+		func() (recordIP string, err error) {
+			recordId, recordIP, err = adc.getParseRecord(subDomain, recordType)
+			return
+		},
+		func() error { return adc.updateParseRecord(ipAddr, recordType, subDomain, recordId) })
+}
 
-	response, err := client.DescribeDomainRecords(request)
+// getParseRecord 按完整子域名和记录类型查询并读取所有分页，记录不存在时返回空字符串，更新时会新建
+func (adc aliDNSConf) getParseRecord(subDomain, recordType string) (recordId, recordIP string, err error) {
+	client, err := adc.newClient()
 	if err != nil {
 		return
 	}
+	fullDomain := subDomain + "." + adc.Domain
+	if subDomain == "@" {
+		fullDomain = adc.Domain
+	}
+	for page := 1; ; page++ {
+		request := alidns.CreateDescribeSubDomainRecordsRequest()
+		adc.setEndpoint(request.RpcRequest)
+		request.DomainName = adc.Domain
+		request.SubDomain = fullDomain
+		request.Type = recordType
+		request.PageNumber = requests.NewInteger(page)
+		request.PageSize = requests.NewInteger(aliDNSPageSize)
 
-	for i := range response.DomainRecords.Record {
-		if response.DomainRecords.Record[i].RR == subDomain {
-			adc.RecordId = response.DomainRecords.Record[i].RecordId
-			recordIP = response.DomainRecords.Record[i].Value
-			if response.DomainRecords.Record[i].Type == recordType {
-				break
+		var response *alidns.DescribeSubDomainRecordsResponse
+		response, err = client.DescribeSubDomainRecords(request)
+		if err != nil {
+			return
+		}
+		for _, record := range response.DomainRecords.Record {
+			if record.RR == subDomain && record.Type == recordType {
+				recordId = record.RecordId
+				recordIP = record.Value
+				return
 			}
 		}
+		if len(response.DomainRecords.Record) == 0 || int64(page*aliDNSPageSize) >= response.TotalCount {
+			return
+		}
 	}
-	if adc.RecordId == "" || recordIP == "" {
-		err = errors.New("AliDNS: " + subDomain + "." + adc.Domain + " 解析记录不存在")
-	}
-	return
 }
 
-func (adc aliDNSConf) updateParseRecord(ipAddr, recordType, subDomain string) (err error) {
-	client, err := alidns.NewClientWithAccessKey("cn-hangzhou", adc.AccessKeyId, adc.AccessKeySecret)
+func (adc aliDNSConf) updateParseRecord(ipAddr, recordType, subDomain, recordId string) (err error) {
+	client, err := adc.newClient()
 	if err != nil {
 		return
 	}
 
-	request := alidns.CreateUpdateDomainRecordRequest()
-	request.Scheme = "https"
+	if recordId == "" {
+		request := alidns.CreateAddDomainRecordRequest()
+		adc.setEndpoint(request.RpcRequest)
+		request.DomainName = adc.Domain
+		request.RR = subDomain
+		request.Type = recordType
+		request.Value = ipAddr
+		_, err = client.AddDomainRecord(request)
+		return
+	}
 
-	request.RecordId = adc.RecordId
+	request := alidns.CreateUpdateDomainRecordRequest()
+	adc.setEndpoint(request.RpcRequest)
+	request.RecordId = recordId
 	request.RR = subDomain
 	request.Type = recordType
 	request.Value = ipAddr
@@ -124,3 +149,21 @@ func (adc aliDNSConf) updateParseRecord(ipAddr, recordType, subDomain string) (e
 	}
 	return
 }
+
+func (adc aliDNSConf) newClient() (*alidns.Client, error) {
+	return alidns.NewClientWithAccessKey(adc.Region, adc.AccessKeyId, adc.AccessKeySecret)
+}
+
+// setEndpoint 配置了 endpoint 时不再按地域查找接入地址，endpoint 可以带 http:// 或 https://
+func (adc aliDNSConf) setEndpoint(request *requests.RpcRequest) {
+	request.Scheme = "https"
+	if adc.Endpoint == "" {
+		return
+	}
+	endpoint := adc.Endpoint
+	if i := strings.Index(endpoint, "://"); i >= 0 {
+		request.Scheme = endpoint[:i]
+		endpoint = endpoint[i+3:]
+	}
+	request.Domain = strings.TrimSuffix(endpoint, "/")
+}
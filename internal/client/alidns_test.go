@@ -0,0 +1,80 @@
+package client
+
+import "testing"
+
+// newAliDNSStub DescribeSubDomainRecords 忽略 Type 参数，返回同名的所有记录
+func newAliDNSStub(t *testing.T) (*apiStub, aliDNSConf) {
+	t.Helper()
+	records := mixedRecords("home")
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		header := map[string]string{"Content-Type": "application/json"}
+		if req.Form.Get("AccessKeyId") != "key" {
+			return stubResponse{Status: 404, Header: header,
+				Body: `{"RequestId": "req-denied", "Code": "InvalidAccessKeyId.NotFound", "Message": "Specified access key is not found."}`}
+		}
+		switch action := req.Form.Get("Action"); action {
+		case "DescribeSubDomainRecords":
+			if req.Form.Get("SubDomain") != "home.example.com" {
+				t.Errorf("SubDomain = %q, want home.example.com", req.Form.Get("SubDomain"))
+			}
+			var list []map[string]string
+			for _, record := range records {
+				list = append(list, map[string]string{"RecordId": record.Id, "RR": record.Name, "Type": record.Type, "Value": record.Value, "DomainName": "example.com"})
+			}
+			return stubResponse{Header: header, Body: map[string]any{
+				"RequestId":     "req-describe",
+				"TotalCount":    len(list),
+				"DomainRecords": map[string]any{"Record": list},
+			}}
+		case "UpdateDomainRecord", "AddDomainRecord":
+			return stubResponse{Header: header, Body: `{"RequestId": "req-update", "RecordId": "id-new"}`}
+		default:
+			return stubResponse{Status: 400, Header: header, Body: `{"RequestId": "req-bad", "Code": "InvalidAction", "Message": "` + action + `"}`}
+		}
+	})
+	adc := aliDNSConf{
+		AccessKeyId:     "key",
+		AccessKeySecret: "secret",
+		Region:          "cn-hangzhou",
+		Endpoint:        server.URL,
+		Domain:          "example.com",
+		SubDomain:       subdomain{A: "home", AAAA: "home"},
+	}
+	return stub, adc
+}
+
+func TestAliDNSRecordSelection(t *testing.T) {
+	stub, adc := newAliDNSStub(t)
+	checkRecordSelection(t, func(recordType string) (string, string, error) {
+		return adc.getParseRecord("home", recordType)
+	})
+	checkRunUpdatesEachRecord(t, adc.Run, func() map[string]string {
+		updated := make(map[string]string)
+		for _, req := range stub.received("", "/") {
+			if action := req.Form.Get("Action"); action == "UpdateDomainRecord" {
+				updated[req.Form.Get("RecordId")] = req.Form.Get("Value")
+			} else if action != "DescribeSubDomainRecords" {
+				t.Errorf("unexpected action %s", action)
+			}
+		}
+		return updated
+	})
+}
+
+func TestAliDNSAddMissingRecord(t *testing.T) {
+	stub, adc := newAliDNSStub(t)
+	// 没有 TXT 记录时新建，而不是修改同名的其他类型记录
+	if err := adc.updateParseRecord("v=spf1 -all", "TXT", "home", ""); err != nil {
+		t.Fatal(err)
+	}
+	requests := stub.received("", "/")
+	if len(requests) != 1 || requests[0].Form.Get("Action") != "AddDomainRecord" || requests[0].Form.Get("Type") != "TXT" {
+		t.Errorf("requests = %+v", requests)
+	}
+}
+
+func TestAliDNSAPIError(t *testing.T) {
+	_, adc := newAliDNSStub(t)
+	adc.AccessKeyId = "wrong"
+	checkRunError(t, adc.Run, "InvalidAccessKeyId.NotFound")
+}
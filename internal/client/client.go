@@ -1,11 +1,16 @@
 package client
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
 	"encoding/json"
-	"errors"
 	"io"
 	"net/http"
+	"os"
+	"path/filepath"
 )
 
 const (
@@ -14,16 +19,86 @@ const (
 	NetworkCardFileName = "network_card.json"
 )
 
+// apiUrl token 和证书用于访问自建的私有服务端，不会发送给默认的公共服务端
+// 证书路径为相对路径时相对于配置文件目录
 type apiUrl struct {
-	IPv4    string `json:"ipv4"`
-	IPv6    string `json:"ipv6"`
-	Version string `json:"version"`
+	IPv4           string `json:"ipv4"`
+	IPv6           string `json:"ipv6"`
+	Version        string `json:"version"`
+	Token          string `json:"token"`
+	CAFile         string `json:"ca_file"`
+	ClientCertFile string `json:"client_cert_file"`
+	ClientKeyFile  string `json:"client_key_file"`
+}
+
+// get 带上 token 和客户端证书请求服务端
+func (api apiUrl) get(url string) (resp *http.Response, err error) {
+	if url == common.DefaultAPIUrl || url == common.DefaultIPv6APIUrl {
+		return http.Get(url)
+	}
+	httpClient, err := api.httpClient()
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		return
+	}
+	if api.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+api.Token)
+	}
+	return httpClient.Do(req)
+}
+
+// httpClient ca_file 在系统根证书之外额外信任，用于自签名的服务端
+func (api apiUrl) httpClient() (*http.Client, error) {
+	if api.CAFile == "" && api.ClientCertFile == "" && api.ClientKeyFile == "" {
+		return http.DefaultClient, nil
+	}
+	tlsConfig := &tls.Config{}
+	if api.CAFile != "" {
+		pem, err := os.ReadFile(confPath(api.CAFile))
+		if err != nil {
+			return nil, err
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "api_url.ca_file")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if api.ClientCertFile != "" || api.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(confPath(api.ClientCertFile), confPath(api.ClientKeyFile))
+		if err != nil {
+			return nil, i18n.NewError(i18n.MsgTLSLoadFailed, confPath(api.ClientCertFile), err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+	// 每次检查都重新创建，不保留空闲连接
+	return &http.Client{Transport: &http.Transport{
+		Proxy:             http.ProxyFromEnvironment,
+		TLSClientConfig:   tlsConfig,
+		DisableKeepAlives: true,
+	}}, nil
+}
+
+// confPath 相对路径相对于配置文件目录
+func confPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return ConfDirectoryName + "/" + path
 }
 
 type enable struct {
 	IPv4        bool `json:"ipv4"`
 	IPv6        bool `json:"ipv6"`
 	NetworkCard bool `json:"network_card"`
+	Notify      bool `json:"notify"`
+	Hook        bool `json:"hook"`
 }
 
 type networkCard struct {
@@ -32,9 +107,17 @@ type networkCard struct {
 }
 
 type service struct {
-	DNSPod     bool `json:"dnspod"`
-	AliDNS     bool `json:"alidns"`
-	Cloudflare bool `json:"cloudflare"`
+	DNSPod       bool `json:"dnspod"`
+	AliDNS       bool `json:"alidns"`
+	Cloudflare   bool `json:"cloudflare"`
+	RFC2136      bool `json:"rfc2136"`
+	Dyndns2      bool `json:"dyndns2"`
+	TencentCloud bool `json:"tencentcloud"`
+	Route53      bool `json:"route53"`
+	CustomHTTP   bool `json:"custom_http"`
+	LocalFile    bool `json:"local_file"`
+	PowerDNS     bool `json:"powerdns"`
+	HuaweiCloud  bool `json:"huaweicloud"`
 }
 
 type clientConf struct {
@@ -43,6 +126,8 @@ type clientConf struct {
 	NetworkCard       networkCard `json:"network_card"`
 	Services          service     `json:"services"`
 	CheckCycleMinutes int         `json:"check_cycle_minutes"`
+	Log               logger.Conf `json:"log"`
+	Locale            string      `json:"locale"`
 	LatestIPv4        string      `json:"-"`
 	LatestIPv6        string      `json:"-"`
 }
@@ -53,8 +138,12 @@ func (conf *clientConf) InitConf() (msg string, err error) {
 	conf.APIUrl.IPv6 = common.DefaultIPv6APIUrl
 	conf.APIUrl.Version = common.DefaultAPIUrl
 	conf.CheckCycleMinutes = 0
+	conf.Log.Level = "info"
+	conf.Log.Format = logger.FormatText
+	conf.Log.MaxSizeMB = 10
+	conf.Log.MaxBackups = 3
 	err = common.MarshalAndSave(conf, ConfDirectoryName+"/"+ConfFileName)
-	msg = "初始化 " + ConfDirectoryName + "/" + ConfFileName
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+ConfFileName)
 	return
 }
 
@@ -62,21 +151,21 @@ func (conf *clientConf) LoadConf() (err error) {
 	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+ConfFileName, &conf)
 	// 检查启用 IP 类型
 	if !conf.Enable.IPv4 && !conf.Enable.IPv6 {
-		err = errors.New("请打开客户端配置文件 " + ConfDirectoryName + "/" + ConfFileName + " 启用需要使用的 IP 类型并重新启动")
+		err = i18n.NewError(i18n.MsgConfEnableIPType, ConfDirectoryName+"/"+ConfFileName)
 		return
 	}
 	// 检查启用服务
-	if !conf.Services.DNSPod && !conf.Services.AliDNS && !conf.Services.Cloudflare {
-		err = errors.New("请打开客户端配置文件 " + ConfDirectoryName + "/" + ConfFileName + " 启用需要使用的服务并重新启动")
+	if !conf.Services.DNSPod && !conf.Services.AliDNS && !conf.Services.Cloudflare && !conf.Services.RFC2136 && !conf.Services.Dyndns2 && !conf.Services.TencentCloud && !conf.Services.Route53 && !conf.Services.CustomHTTP && !conf.Services.LocalFile && !conf.Services.PowerDNS && !conf.Services.HuaweiCloud {
+		err = i18n.NewError(i18n.MsgConfEnableService, ConfDirectoryName+"/"+ConfFileName)
 		return
 	}
 	return
 }
 
 func (conf clientConf) GetLatestVersion() (str string) {
-	resp, err := http.Get(conf.APIUrl.Version)
+	resp, err := conf.APIUrl.get(conf.APIUrl.Version)
 	if err != nil {
-		return "N/A (请检查网络连接)"
+		return "N/A (" + i18n.T(i18n.MsgVersionNetworkError) + ")"
 	}
 	defer func(Body io.ReadCloser) {
 		t := Body.Close()
@@ -84,17 +173,20 @@ func (conf clientConf) GetLatestVersion() (str string) {
 			str = t.Error()
 		}
 	}(resp.Body)
+	if resp.StatusCode == http.StatusUnauthorized {
+		return "N/A (" + i18n.T(i18n.MsgAPIUnauthorized, conf.APIUrl.Version) + ")"
+	}
 	recvJson, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "N/A (数据包错误)"
+		return "N/A (" + i18n.T(i18n.MsgVersionBadPacket) + ")"
 	}
 	recv := common.PublicInfo{}
 	err = json.Unmarshal(recvJson, &recv)
 	if err != nil {
-		return "N/A (数据包错误)"
+		return "N/A (" + i18n.T(i18n.MsgVersionBadPacket) + ")"
 	}
 	if recv.Version == "" {
-		return "N/A (没有获取到版本信息)"
+		return "N/A (" + i18n.T(i18n.MsgVersionMissing) + ")"
 	}
 	return recv.Version
 }
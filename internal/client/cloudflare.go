@@ -2,6 +2,7 @@ package client
 
 import (
 	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
 	"encoding/json"
 	"errors"
 	"github.com/bitly/go-simplejson"
@@ -12,11 +13,12 @@ import (
 
 const CloudflareConfFileName = "cloudflare.json"
 
+var cloudflareAPIUrl = "https://api.cloudflare.com/client/v4"
+
 type cloudflareConf struct {
 	ZoneID   string    `json:"zone_id"`
 	APIToken string    `json:"api_token"`
 	Domain   subdomain `json:"domain"`
-	DomainID string    `json:"-"`
 }
 
 type cloudflareUpdateRequest struct {
@@ -28,12 +30,12 @@ type cloudflareUpdateRequest struct {
 
 func (cfc *cloudflareConf) InitConf() (msg string, err error) {
 	*cfc = cloudflareConf{}
-	cfc.APIToken = "在 https://dash.cloudflare.com/profile/api-tokens 获取"
-	cfc.ZoneID = "在你域名页面的右下角有个区域 ID"
-	cfc.Domain.A = "A记录子域名.example.com"
-	cfc.Domain.AAAA = "AAAA记录子域名.example.com"
+	cfc.APIToken = i18n.T(i18n.MsgPlaceholderGetAt, "https://dash.cloudflare.com/profile/api-tokens")
+	cfc.ZoneID = i18n.T(i18n.MsgPlaceholderCloudflareZoneID)
+	cfc.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	cfc.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
 	err = common.MarshalAndSave(cfc, ConfDirectoryName+"/"+CloudflareConfFileName)
-	msg = "初始化 " + ConfDirectoryName + "/" + CloudflareConfFileName
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+CloudflareConfFileName)
 	return
 }
 
@@ -43,50 +45,48 @@ func (cfc *cloudflareConf) LoadConf() (err error) {
 		return
 	}
 	if cfc.ZoneID == "" || cfc.APIToken == "" || (cfc.Domain.A == "" && cfc.Domain.AAAA == "") {
-		err = errors.New("请打开配置文件 " + ConfDirectoryName + "/" + CloudflareConfFileName + " 检查你的 zone_id, api_token, domain 并重新启动")
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+CloudflareConfFileName, "zone_id, api_token, domain")
 	}
 	return
 }
 
 func (cfc cloudflareConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
 	if enabled.IPv4 && cfc.Domain.A != "" {
-		// 获取解析记录
-		recordIP, err := cfc.getParseRecord(cfc.Domain.A, "A")
+		msgRow, err := cfc.update(cfc.Domain.A, "A", ipv4)
 		if err != nil {
 			errs = append(errs, err)
-		} else if recordIP != ipv4 {
-			// 更新解析记录
-			err = cfc.updateParseRecord(ipv4, "A", cfc.Domain.A)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				msg = append(msg, "Cloudflare: "+cfc.Domain.A+" 已更新解析记录 "+ipv4)
-			}
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
 		}
 	}
 	if enabled.IPv6 && cfc.Domain.AAAA != "" {
-		// 获取解析记录
-		recordIP, err := cfc.getParseRecord(cfc.Domain.AAAA, "AAAA")
+		msgRow, err := cfc.update(cfc.Domain.AAAA, "AAAA", ipv6)
 		if err != nil {
 			errs = append(errs, err)
-		} else if recordIP != ipv6 {
-			// 更新解析记录
-			err = cfc.updateParseRecord(ipv6, "AAAA", cfc.Domain.AAAA)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				msg = append(msg, "Cloudflare: "+cfc.Domain.AAAA+" 已更新解析记录 "+ipv6)
-			}
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
 		}
 	}
 	return
 }
 
-func (cfc *cloudflareConf) getParseRecord(domain, recordType string) (recordIP string, err error) {
+// update A 和 AAAA 分别查询记录，记录 ID 不会互相覆盖
+func (cfc cloudflareConf) update(domain, recordType, ipAddr string) (msg string, err error) {
+	var domainID string
+	return updateRecord("Cloudflare", domain, recordType, ipAddr,
+		func() (recordIP string, err error) {
+			domainID, recordIP, err = cfc.getParseRecord(domain, recordType)
+			return
+		},
+		func() error { return cfc.updateParseRecord(ipAddr, recordType, domain, domainID) })
+}
+
+// getParseRecord 按名称和记录类型查询，只选择名称和类型都一致的记录
+func (cfc cloudflareConf) getParseRecord(domain, recordType string) (domainID, recordIP string, err error) {
 	httpClient := &http.Client{
 		Transport: &http.Transport{DisableKeepAlives: true},
 	}
-	url := "https://api.cloudflare.com/client/v4/zones/" + cfc.ZoneID + "/dns_records?name=" + domain
+	url := cloudflareAPIUrl + "/zones/" + cfc.ZoneID + "/dns_records?name=" + domain + "&type=" + recordType
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
 		return
@@ -117,32 +117,28 @@ func (cfc *cloudflareConf) getParseRecord(domain, recordType string) (recordIP s
 		return
 	}
 	if !jsonObj.Get("success").MustBool() {
-		err = errors.New("Cloudflare: 身份认证似乎有问题")
-		return
-	}
-	records, err := jsonObj.Get("result").Array()
-	if len(records) == 0 {
-		err = errors.New("Cloudflare: " + domain + " 解析记录不存在")
+		err = i18n.NewError(i18n.MsgCloudflareAuth)
 		return
 	}
+	records, _ := jsonObj.Get("result").Array()
 	for _, value := range records {
-		element := value.(map[string]any)
-		if element["name"].(string) == domain {
-			cfc.DomainID = element["id"].(string)
-			recordIP = element["content"].(string)
-			if element["type"].(string) == recordType {
-				break
-			}
+		element, ok := value.(map[string]any)
+		if !ok || element["name"] != domain || element["type"] != recordType {
+			continue
 		}
+		domainID, _ = element["id"].(string)
+		recordIP, _ = element["content"].(string)
+		return
 	}
+	err = i18n.NewError(i18n.MsgRecordNotFound, "Cloudflare", domain)
 	return
 }
 
-func (cfc cloudflareConf) updateParseRecord(ipAddr, recordType, domain string) (err error) {
+func (cfc cloudflareConf) updateParseRecord(ipAddr, recordType, domain, domainID string) (err error) {
 	httpClient := &http.Client{
 		Transport: &http.Transport{DisableKeepAlives: true},
 	}
-	url := "https://api.cloudflare.com/client/v4/zones/" + cfc.ZoneID + "/dns_records/" + cfc.DomainID
+	url := cloudflareAPIUrl + "/zones/" + cfc.ZoneID + "/dns_records/" + domainID
 	reqData := cloudflareUpdateRequest{
 		Type:    recordType,
 		Name:    domain,
@@ -0,0 +1,63 @@
+package client
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+// newCloudflareStub 查询时忽略 type 参数，返回同名的所有记录
+func newCloudflareStub(t *testing.T) *apiStub {
+	t.Helper()
+	records := mixedRecords("home.example.com")
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		if req.Header.Get("Authorization") != "Bearer secret" {
+			return stubResponse{Status: 403, Body: `{"success": false, "errors": [{"code": 9109, "message": "Invalid access token"}]}`}
+		}
+		if req.Method == "GET" && req.URL.Path == "/zones/zone/dns_records" {
+			var result []map[string]string
+			for _, record := range records {
+				result = append(result, map[string]string{"id": record.Id, "name": record.Name, "type": record.Type, "content": record.Value})
+			}
+			return stubResponse{Body: map[string]any{"success": true, "result": result}}
+		}
+		if req.Method == "PUT" && strings.HasPrefix(req.URL.Path, "/zones/zone/dns_records/") {
+			return stubResponse{Body: `{"success": true, "errors": []}`}
+		}
+		return stubResponse{Status: 404, Body: `{"success": false, "errors": [{"code": 7003, "message": "Could not route"}]}`}
+	})
+	apiUrl := cloudflareAPIUrl
+	cloudflareAPIUrl = server.URL
+	t.Cleanup(func() { cloudflareAPIUrl = apiUrl })
+	return stub
+}
+
+func TestCloudflareRecordSelection(t *testing.T) {
+	stub := newCloudflareStub(t)
+	cfc := cloudflareConf{ZoneID: "zone", APIToken: "secret", Domain: subdomain{A: "home.example.com", AAAA: "home.example.com"}}
+	checkRecordSelection(t, func(recordType string) (string, string, error) {
+		return cfc.getParseRecord("home.example.com", recordType)
+	})
+	checkRunUpdatesEachRecord(t, cfc.Run, func() map[string]string {
+		updated := make(map[string]string)
+		for _, id := range []string{"id-a", "id-aaaa", "id-cname"} {
+			for _, req := range stub.received("PUT", "/zones/zone/dns_records/"+id) {
+				var update cloudflareUpdateRequest
+				if err := json.Unmarshal(req.Body, &update); err != nil {
+					t.Fatal(err)
+				}
+				if update.Name != "home.example.com" || update.Ttl != 1 {
+					t.Errorf("record %s update = %+v", id, update)
+				}
+				updated[id] = update.Content
+			}
+		}
+		return updated
+	})
+}
+
+func TestCloudflareAuthError(t *testing.T) {
+	newCloudflareStub(t)
+	cfc := cloudflareConf{ZoneID: "zone", APIToken: "wrong", Domain: subdomain{A: "home.example.com"}}
+	checkRunError(t, cfc.Run, "Cloudflare")
+}
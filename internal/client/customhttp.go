@@ -0,0 +1,322 @@
+package client
+
+import (
+	"bytes"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const CustomHTTPConfFileName = "custom_http.json"
+
+var (
+	customHTTPMutex sync.Mutex
+	// customHTTPLastIP 没有配置 get 请求时记录各域名最后一次成功提交的 IP
+	customHTTPLastIP = make(map[string]string)
+)
+
+type customHTTPConf struct {
+	Domain         subdomain         `json:"domain"`
+	Get            customHTTPRequest `json:"get"`
+	Update         customHTTPRequest `json:"update"`
+	TimeoutSeconds int               `json:"timeout_seconds"`
+}
+
+// customHTTPRequest url headers body 为模板，可使用 {{.IP}} {{.Name}} {{.Type}}
+// status_codes 为空时 2xx 视为成功
+// 用于 get 时按 json_path 或 regex 的第一个分组取出当前记录值，都为空时使用整个响应
+// 用于 update 时 json_path 的值需等于 expect (expect 为空时需为真值)，regex 需匹配响应
+type customHTTPRequest struct {
+	Method      string            `json:"method"`
+	Url         string            `json:"url"`
+	Headers     map[string]string `json:"headers"`
+	Body        string            `json:"body"`
+	StatusCodes []int             `json:"status_codes"`
+	JSONPath    string            `json:"json_path"`
+	Expect      string            `json:"expect"`
+	Regex       string            `json:"regex"`
+}
+
+// customHTTPData 模板变量
+type customHTTPData struct {
+	IP   string
+	Name string
+	Type string
+}
+
+func (chc *customHTTPConf) InitConf() (msg string, err error) {
+	*chc = customHTTPConf{}
+	chc.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	chc.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	chc.Get = customHTTPRequest{
+		Method:   "GET",
+		Url:      "https://api.example.com/records?name={{urlquery .Name}}&type={{.Type}}",
+		Headers:  map[string]string{"Authorization": "Bearer token"},
+		JSONPath: "data.0.value",
+	}
+	chc.Update = customHTTPRequest{
+		Method:      "PUT",
+		Url:         "https://api.example.com/records",
+		Headers:     map[string]string{"Authorization": "Bearer token", "Content-Type": "application/json"},
+		Body:        `{"name": {{json .Name}}, "type": {{json .Type}}, "value": {{json .IP}}}`,
+		StatusCodes: []int{200, 201, 204},
+	}
+	chc.TimeoutSeconds = 30
+	err = common.MarshalAndSave(chc, ConfDirectoryName+"/"+CustomHTTPConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+CustomHTTPConfFileName)
+	return
+}
+
+func (chc *customHTTPConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+CustomHTTPConfFileName, &chc)
+	if err != nil {
+		return
+	}
+	if chc.Update.Url == "" || (chc.Domain.A == "" && chc.Domain.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+CustomHTTPConfFileName, "domain, update.url")
+		return
+	}
+	for name, req := range map[string]customHTTPRequest{"get": chc.Get, "update": chc.Update} {
+		if req.Url == "" {
+			continue
+		}
+		if err = req.check(); err != nil {
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+CustomHTTPConfFileName, name+": "+err.Error())
+			return
+		}
+	}
+	if chc.TimeoutSeconds <= 0 {
+		chc.TimeoutSeconds = 30
+	}
+	return
+}
+
+func (chc customHTTPConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	if enabled.IPv4 && chc.Domain.A != "" {
+		msgRow, err := updateRecord("CustomHTTP", chc.Domain.A, "A", ipv4,
+			func() (string, error) { return chc.getParseRecord(chc.Domain.A, "A") },
+			func() error { return chc.updateParseRecord(ipv4, "A", chc.Domain.A) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	if enabled.IPv6 && chc.Domain.AAAA != "" {
+		msgRow, err := updateRecord("CustomHTTP", chc.Domain.AAAA, "AAAA", ipv6,
+			func() (string, error) { return chc.getParseRecord(chc.Domain.AAAA, "AAAA") },
+			func() error { return chc.updateParseRecord(ipv6, "AAAA", chc.Domain.AAAA) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// getParseRecord 没有配置 get 请求时返回最后一次成功提交的 IP，首次运行时会提交一次
+func (chc customHTTPConf) getParseRecord(domain, recordType string) (recordIP string, err error) {
+	if chc.Get.Url == "" {
+		customHTTPMutex.Lock()
+		defer customHTTPMutex.Unlock()
+		return customHTTPLastIP[domain+"/"+recordType], nil
+	}
+	body, err := chc.Get.send(customHTTPData{Name: domain, Type: recordType}, chc.TimeoutSeconds)
+	if err != nil {
+		return
+	}
+	switch {
+	case chc.Get.JSONPath != "":
+		var value any
+		value, err = jsonPathValue(body, chc.Get.JSONPath)
+		if err != nil {
+			return
+		}
+		if value != nil {
+			recordIP = fmt.Sprint(value)
+		}
+	case chc.Get.Regex != "":
+		matches := regexp.MustCompile(chc.Get.Regex).FindSubmatch(body)
+		if len(matches) == 0 {
+			err = i18n.NewError(i18n.MsgCustomHTTPNoValue, domain, chc.Get.Regex)
+			return
+		}
+		recordIP = string(matches[0])
+		if len(matches) > 1 {
+			recordIP = string(matches[1])
+		}
+	default:
+		recordIP = string(body)
+	}
+	recordIP = strings.TrimSpace(recordIP)
+	if recordType == "AAAA" && recordIP != "" {
+		recordIP = common.DecodeIPv6(recordIP)
+	}
+	return
+}
+
+func (chc customHTTPConf) updateParseRecord(ipAddr, recordType, domain string) (err error) {
+	body, err := chc.Update.send(customHTTPData{IP: ipAddr, Name: domain, Type: recordType}, chc.TimeoutSeconds)
+	if err != nil {
+		return
+	}
+	if chc.Update.JSONPath != "" {
+		var value any
+		value, err = jsonPathValue(body, chc.Update.JSONPath)
+		if err != nil {
+			return
+		}
+		if chc.Update.Expect != "" && fmt.Sprint(value) != chc.Update.Expect ||
+			chc.Update.Expect == "" && !jsonTruthy(value) {
+			err = i18n.NewError(i18n.MsgCustomHTTPMismatch, domain, chc.Update.JSONPath+" = "+fmt.Sprint(value))
+			return
+		}
+	}
+	if chc.Update.Regex != "" && !regexp.MustCompile(chc.Update.Regex).Match(body) {
+		err = i18n.NewError(i18n.MsgCustomHTTPMismatch, domain, "regex "+chc.Update.Regex)
+		return
+	}
+	if chc.Get.Url == "" {
+		customHTTPMutex.Lock()
+		customHTTPLastIP[domain+"/"+recordType] = ipAddr
+		customHTTPMutex.Unlock()
+	}
+	return
+}
+
+// check 检查模板和正则表达式能否解析
+func (chr customHTTPRequest) check() (err error) {
+	templates := []string{chr.Url, chr.Body}
+	for _, value := range chr.Headers {
+		templates = append(templates, value)
+	}
+	for _, text := range templates {
+		if _, err = template.New("custom_http").Funcs(templateFuncs).Parse(text); err != nil {
+			return
+		}
+	}
+	if chr.Regex != "" {
+		_, err = regexp.Compile(chr.Regex)
+	}
+	return
+}
+
+// send 渲染模板后发送请求，状态码不符合 status_codes 时返回错误
+func (chr customHTTPRequest) send(data customHTTPData, timeoutSeconds int) (body []byte, err error) {
+	render := func(text string) (string, error) {
+		tmpl, err := template.New("custom_http").Funcs(templateFuncs).Parse(text)
+		if err != nil {
+			return "", err
+		}
+		buf := bytes.Buffer{}
+		err = tmpl.Execute(&buf, data)
+		return buf.String(), err
+	}
+	url, err := render(chr.Url)
+	if err != nil {
+		return
+	}
+	reqBody, err := render(chr.Body)
+	if err != nil {
+		return
+	}
+	method := chr.Method
+	if method == "" {
+		method = "GET"
+	}
+	req, err := http.NewRequest(strings.ToUpper(method), url, strings.NewReader(reqBody))
+	if err != nil {
+		return
+	}
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ("+common.ProjectUrl+")")
+	for key, value := range chr.Headers {
+		var rendered string
+		rendered, err = render(value)
+		if err != nil {
+			return
+		}
+		req.Header.Set(key, rendered)
+	}
+
+	httpClient := &http.Client{
+		Timeout:   time.Duration(timeoutSeconds) * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(resp.Body)
+	body, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	ok := resp.StatusCode >= 200 && resp.StatusCode <= 299
+	if len(chr.StatusCodes) > 0 {
+		ok = false
+		for _, code := range chr.StatusCodes {
+			if resp.StatusCode == code {
+				ok = true
+				break
+			}
+		}
+	}
+	if !ok {
+		err = i18n.NewError(i18n.MsgCustomHTTPStatus, data.Name, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return
+}
+
+// jsonPathValue 按用点分隔的路径取值，数组下标为数字，例如 data.records.0.value
+// 路径上的值不存在时返回 nil
+func jsonPathValue(body []byte, path string) (value any, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err = decoder.Decode(&value); err != nil {
+		return
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := value.(type) {
+		case map[string]any:
+			value = node[key]
+		case []any:
+			index, convErr := strconv.Atoi(key)
+			if convErr != nil || index < 0 || index >= len(node) {
+				return nil, nil
+			}
+			value = node[index]
+		default:
+			return nil, nil
+		}
+	}
+	return
+}
+
+func jsonTruthy(value any) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case string:
+		return v != "" && v != "false" && v != "0"
+	case json.Number:
+		return v.String() != "0"
+	}
+	return true
+}
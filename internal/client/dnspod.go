@@ -2,6 +2,8 @@ package client
 
 import (
 	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
 	"errors"
 	"github.com/bitly/go-simplejson"
 	"io"
@@ -11,24 +13,31 @@ import (
 
 const DNSPodConfFileName = "dnspod.json"
 
+var dnspodAPIUrl = "https://dnsapi.cn"
+
 type dnspodConf struct {
-	Id           string    `json:"id"`
-	Token        string    `json:"token"`
-	Domain       string    `json:"domain"`
-	SubDomain    subdomain `json:"sub_domain"`
-	RecordId     string    `json:"-"`
-	RecordLineId string    `json:"-"`
+	Id        string    `json:"id"`
+	Token     string    `json:"token"`
+	Domain    string    `json:"domain"`
+	SubDomain subdomain `json:"sub_domain"`
+}
+
+// dnspodRecord 查询到的记录，只在同一次检查的查询和更新之间传递
+type dnspodRecord struct {
+	Id     string
+	Value  string
+	LineId string
 }
 
 func (dpc *dnspodConf) InitConf() (msg string, err error) {
 	*dpc = dnspodConf{}
-	dpc.Id = "在 https://console.dnspod.cn/account/token/token 获取"
+	dpc.Id = i18n.T(i18n.MsgPlaceholderGetAt, "https://console.dnspod.cn/account/token/token")
 	dpc.Token = dpc.Id
 	dpc.Domain = "example.com"
-	dpc.SubDomain.A = "A记录子域名"
-	dpc.SubDomain.AAAA = "AAAA记录子域名"
+	dpc.SubDomain.A = i18n.T(i18n.MsgPlaceholderSubDomainA)
+	dpc.SubDomain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA)
 	err = common.MarshalAndSave(dpc, ConfDirectoryName+"/"+DNSPodConfFileName)
-	msg = "初始化 " + ConfDirectoryName + "/" + DNSPodConfFileName
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+DNSPodConfFileName)
 	return
 }
 
@@ -38,45 +47,44 @@ func (dpc *dnspodConf) LoadConf() (err error) {
 		return
 	}
 	if dpc.Id == "" || dpc.Token == "" || dpc.Domain == "" || (dpc.SubDomain.A == "" && dpc.SubDomain.AAAA == "") {
-		err = errors.New("请打开配置文件 " + ConfDirectoryName + "/" + DNSPodConfFileName + " 检查你的 id, token, domain, sub_domain 并重新启动")
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+DNSPodConfFileName, "id, token, domain, sub_domain")
+		return
 	}
+	logger.Warn(i18n.T(i18n.MsgDNSPodDeprecated, ConfDirectoryName+"/"+TencentCloudConfFileName))
 	return
 }
 
 func (dpc dnspodConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
 	if enabled.IPv4 && dpc.SubDomain.A != "" {
-		// 获取解析记录
-		recordIP, err := dpc.getParseRecord(dpc.SubDomain.A, "A")
+		msgRow, err := dpc.update(dpc.SubDomain.A, "A", ipv4)
 		if err != nil {
 			errs = append(errs, err)
-		} else if recordIP != ipv4 {
-			// 更新解析记录
-			err = dpc.updateParseRecord(ipv4, "A", dpc.SubDomain.A)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				msg = append(msg, "DNSPod: "+dpc.SubDomain.A+"."+dpc.Domain+" 已更新解析记录 "+ipv4)
-			}
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
 		}
 	}
 	if enabled.IPv6 && dpc.SubDomain.AAAA != "" {
-		// 获取解析记录
-		recordIP, err := dpc.getParseRecord(dpc.SubDomain.AAAA, "AAAA")
+		msgRow, err := dpc.update(dpc.SubDomain.AAAA, "AAAA", ipv6)
 		if err != nil {
 			errs = append(errs, err)
-		} else if recordIP != ipv6 {
-			// 更新解析记录
-			err = dpc.updateParseRecord(ipv6, "AAAA", dpc.SubDomain.AAAA)
-			if err != nil {
-				errs = append(errs, err)
-			} else {
-				msg = append(msg, "DNSPod: "+dpc.SubDomain.AAAA+"."+dpc.Domain+" 已更新解析记录 "+ipv6)
-			}
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
 		}
 	}
 	return
 }
 
+// update A 和 AAAA 分别查询记录，记录 ID 不会互相覆盖
+func (dpc dnspodConf) update(subDomain, recordType, ipAddr string) (msg string, err error) {
+	var record dnspodRecord
+	return updateRecord("DNSPod", subDomain+"."+dpc.Domain, recordType, ipAddr,
+		func() (recordIP string, err error) {
+			record, err = dpc.getParseRecord(subDomain, recordType)
+			return record.Value, err
+		},
+		func() error { return dpc.updateParseRecord(ipAddr, recordType, subDomain, record) })
+}
+
 func checkRespondStatus(jsonObj *simplejson.Json) (err error) {
 	statusCode := jsonObj.Get("status").Get("code").MustString()
 	if statusCode != "1" {
@@ -86,10 +94,11 @@ func checkRespondStatus(jsonObj *simplejson.Json) (err error) {
 	return
 }
 
-func (dpc *dnspodConf) getParseRecord(subDomain, recordType string) (recordIP string, err error) {
+// getParseRecord 按子域名和记录类型查询，只选择名称和类型都一致的记录
+func (dpc dnspodConf) getParseRecord(subDomain, recordType string) (record dnspodRecord, err error) {
 	postContent := dpc.publicRequestInit()
-	postContent = postContent + "&" + dpc.recordRequestInit(subDomain)
-	recvJson, err := postman("https://dnsapi.cn/Record.List", postContent)
+	postContent = postContent + "&" + dpc.recordRequestInit(subDomain, recordType)
+	recvJson, err := postman(dnspodAPIUrl+"/Record.List", postContent)
 	if err != nil {
 		return
 	}
@@ -102,29 +111,25 @@ func (dpc *dnspodConf) getParseRecord(subDomain, recordType string) (recordIP st
 	if err != nil {
 		return
 	}
-	records, err := jsonObj.Get("records").Array()
-	if len(records) == 0 {
-		err = errors.New("DNSPod: " + subDomain + "." + dpc.Domain + " 解析记录不存在")
-		return
-	}
+	records, _ := jsonObj.Get("records").Array()
 	for _, value := range records {
-		element := value.(map[string]any)
-		if element["name"].(string) == subDomain {
-			dpc.RecordId = element["id"].(string)
-			recordIP = element["value"].(string)
-			dpc.RecordLineId = element["line_id"].(string)
-			if element["type"].(string) == recordType {
-				break
-			}
+		element, ok := value.(map[string]any)
+		if !ok || element["name"] != subDomain || element["type"] != recordType {
+			continue
 		}
+		record.Id, _ = element["id"].(string)
+		record.Value, _ = element["value"].(string)
+		record.LineId, _ = element["line_id"].(string)
+		return
 	}
+	err = i18n.NewError(i18n.MsgRecordNotFound, "DNSPod", subDomain+"."+dpc.Domain)
 	return
 }
 
-func (dpc dnspodConf) updateParseRecord(ipAddr, recordType, subDomain string) (err error) {
+func (dpc dnspodConf) updateParseRecord(ipAddr, recordType, subDomain string, record dnspodRecord) (err error) {
 	postContent := dpc.publicRequestInit()
-	postContent = postContent + "&" + dpc.recordModifyRequestInit(ipAddr, recordType, subDomain)
-	recvJson, err := postman("https://dnsapi.cn/Record.Modify", postContent)
+	postContent = postContent + "&" + dpc.recordModifyRequestInit(ipAddr, recordType, subDomain, record)
+	recvJson, err := postman(dnspodAPIUrl+"/Record.Modify", postContent)
 	if err != nil {
 		return
 	}
@@ -148,18 +153,19 @@ func (dpc dnspodConf) publicRequestInit() (pp string) {
 	return
 }
 
-func (dpc dnspodConf) recordRequestInit(subDomain string) (rr string) {
+func (dpc dnspodConf) recordRequestInit(subDomain, recordType string) (rr string) {
 	rr = "domain=" + dpc.Domain +
-		"&sub_domain=" + subDomain
+		"&sub_domain=" + subDomain +
+		"&record_type=" + recordType
 	return
 }
 
-func (dpc dnspodConf) recordModifyRequestInit(ipAddr, recordType, subDomain string) (rm string) {
+func (dpc dnspodConf) recordModifyRequestInit(ipAddr, recordType, subDomain string, record dnspodRecord) (rm string) {
 	rm = "domain=" + dpc.Domain +
-		"&record_id=" + dpc.RecordId +
+		"&record_id=" + record.Id +
 		"&sub_domain=" + subDomain +
 		"&record_type=" + recordType +
-		"&record_line_id=" + dpc.RecordLineId +
+		"&record_line_id=" + record.LineId +
 		"&value=" + ipAddr
 	return
 }
@@ -0,0 +1,57 @@
+package client
+
+import "testing"
+
+// newDNSPodStub Record.List 不按类型过滤，返回同名的所有记录，线路 ID 为 "line-" 加记录 ID
+func newDNSPodStub(t *testing.T) *apiStub {
+	t.Helper()
+	records := mixedRecords("home")
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		if req.Form.Get("login_token") != "1,secret" {
+			return stubResponse{Body: `{"status": {"code": "-1", "message": "登录失败"}}`}
+		}
+		status := map[string]string{"code": "1", "message": "Action completed successful"}
+		switch req.URL.Path {
+		case "/Record.List":
+			var list []map[string]string
+			for _, record := range records {
+				list = append(list, map[string]string{"id": record.Id, "name": record.Name, "type": record.Type, "value": record.Value, "line_id": "line-" + record.Id})
+			}
+			return stubResponse{Body: map[string]any{"status": status, "records": list}}
+		case "/Record.Modify":
+			return stubResponse{Body: map[string]any{"status": status}}
+		}
+		return stubResponse{Status: 404}
+	})
+	apiUrl := dnspodAPIUrl
+	dnspodAPIUrl = server.URL
+	t.Cleanup(func() { dnspodAPIUrl = apiUrl })
+	return stub
+}
+
+func TestDNSPodRecordSelection(t *testing.T) {
+	stub := newDNSPodStub(t)
+	dpc := dnspodConf{Id: "1", Token: "secret", Domain: "example.com", SubDomain: subdomain{A: "home", AAAA: "home"}}
+	checkRecordSelection(t, func(recordType string) (string, string, error) {
+		record, err := dpc.getParseRecord("home", recordType)
+		return record.Id, record.Value, err
+	})
+	checkRunUpdatesEachRecord(t, dpc.Run, func() map[string]string {
+		updated := make(map[string]string)
+		for _, req := range stub.received("POST", "/Record.Modify") {
+			id := req.Form.Get("record_id")
+			updated[id] = req.Form.Get("value")
+			// 更新时保留原来的线路
+			if req.Form.Get("record_line_id") != "line-"+id {
+				t.Errorf("record %s line = %q", id, req.Form.Get("record_line_id"))
+			}
+		}
+		return updated
+	})
+}
+
+func TestDNSPodAPIError(t *testing.T) {
+	newDNSPodStub(t)
+	dpc := dnspodConf{Id: "1", Token: "wrong", Domain: "example.com", SubDomain: subdomain{A: "home"}}
+	checkRunError(t, dpc.Run, "DNSPod: -1: 登录失败")
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Dyndns2ConfFileName = "dyndns2.json"
+
+// dyndns2Backoff 服务器返回 911 或 dnserr 后至少等待的时间
+const dyndns2Backoff = 30 * time.Minute
+
+var (
+	dyndns2Mutex sync.Mutex
+	// dyndns2LastIP 各主机名最后一次成功提交的 IP，dyndns2 协议没有查询接口
+	dyndns2LastIP = make(map[string]string)
+	// dyndns2Blocked 返回致命错误的主机名，重新启动前不再请求，避免被服务商封禁
+	dyndns2Blocked = make(map[string]string)
+	// dyndns2RetryAt 返回 911 或 dnserr 的主机名在此时间前不再请求
+	dyndns2RetryAt = make(map[string]time.Time)
+)
+
+// dyndns2 响应码对应的说明
+var dyndns2Responses = map[string]i18n.MessageID{
+	"badauth":  i18n.MsgDyndns2BadAuth,
+	"notfqdn":  i18n.MsgDyndns2NotFQDN,
+	"nohost":   i18n.MsgDyndns2NoHost,
+	"numhost":  i18n.MsgDyndns2NumHost,
+	"abuse":    i18n.MsgDyndns2Abuse,
+	"badagent": i18n.MsgDyndns2BadAgent,
+	"!donator": i18n.MsgDyndns2Donator,
+	"badsys":   i18n.MsgDyndns2BadSys,
+	"dnserr":   i18n.MsgDyndns2ServerError,
+	"911":      i18n.MsgDyndns2ServerError,
+}
+
+type dyndns2Conf struct {
+	BaseUrl  string    `json:"base_url"`
+	Username string    `json:"username"`
+	Password string    `json:"password"`
+	Hostname subdomain `json:"hostname"`
+}
+
+func (ddc *dyndns2Conf) InitConf() (msg string, err error) {
+	*ddc = dyndns2Conf{}
+	ddc.BaseUrl = "https://dynupdate.no-ip.com"
+	ddc.Username = "username"
+	ddc.Password = "password"
+	ddc.Hostname.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	ddc.Hostname.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	err = common.MarshalAndSave(ddc, ConfDirectoryName+"/"+Dyndns2ConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+Dyndns2ConfFileName)
+	return
+}
+
+func (ddc *dyndns2Conf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+Dyndns2ConfFileName, &ddc)
+	if err != nil {
+		return
+	}
+	if ddc.BaseUrl == "" || ddc.Username == "" || ddc.Password == "" || (ddc.Hostname.A == "" && ddc.Hostname.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+Dyndns2ConfFileName, "base_url, username, password, hostname")
+		return
+	}
+	ddc.BaseUrl = strings.TrimSuffix(ddc.BaseUrl, "/")
+	return
+}
+
+// Run A 和 AAAA 的主机名相同时合并为一个请求，myip 用逗号分隔
+// 部分服务商收到单个地址时会用它覆盖另一类记录
+func (ddc dyndns2Conf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	ips := make(map[string]string)
+	if enabled.IPv4 && ddc.Hostname.A != "" {
+		ips["A"] = ipv4
+	}
+	if enabled.IPv6 && ddc.Hostname.AAAA != "" {
+		ips["AAAA"] = ipv6
+	}
+	combined := len(ips) == 2 && ipv4 != "" && ipv6 != "" && strings.EqualFold(ddc.Hostname.A, ddc.Hostname.AAAA)
+	// 合并提交后另一类记录的 IP 已经更新，仍然与本次运行开始时的 IP 比较，保证两条记录都有结果
+	previous := ddc.lastIPs(ddc.Hostname.A, ddc.Hostname.AAAA)
+	submitted := false
+	for _, target := range []struct{ recordType, hostname string }{{"A", ddc.Hostname.A}, {"AAAA", ddc.Hostname.AAAA}} {
+		recordType, hostname := target.recordType, target.hostname
+		ip, ok := ips[recordType]
+		if !ok {
+			continue
+		}
+		msgRow, err := updateRecord("Dyndns2", hostname, recordType, ip,
+			func() (string, error) {
+				_, err := ddc.getParseRecord(hostname, recordType)
+				return previous[recordType], err
+			},
+			func() (err error) {
+				if !combined {
+					return ddc.updateParseRecord(hostname, map[string]string{recordType: ip})
+				}
+				if submitted {
+					return nil
+				}
+				err = ddc.updateParseRecord(hostname, ips)
+				submitted = err == nil
+				return
+			})
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// lastIPs 返回 A 和 AAAA 最后一次成功提交的 IP
+func (ddc dyndns2Conf) lastIPs(hostnameA, hostnameAAAA string) map[string]string {
+	dyndns2Mutex.Lock()
+	defer dyndns2Mutex.Unlock()
+	return map[string]string{
+		"A":    dyndns2LastIP[hostnameA+"/A"],
+		"AAAA": dyndns2LastIP[hostnameAAAA+"/AAAA"],
+	}
+}
+
+// getParseRecord 返回最后一次成功提交的 IP，首次运行时为空，会提交一次
+// 主机名被封禁或处于退避时间内时返回错误，不发送请求
+func (ddc dyndns2Conf) getParseRecord(hostname, recordType string) (recordIP string, err error) {
+	key := hostname + "/" + recordType
+	dyndns2Mutex.Lock()
+	defer dyndns2Mutex.Unlock()
+	if code, ok := dyndns2Blocked[key]; ok {
+		err = i18n.NewError(i18n.MsgDyndns2Blocked, hostname, code)
+		return
+	}
+	if retryAt, ok := dyndns2RetryAt[key]; ok && time.Now().Before(retryAt) {
+		err = i18n.NewError(i18n.MsgDyndns2Backoff, hostname, retryAt.Format("2006-01-02 15:04:05"))
+		return
+	}
+	return dyndns2LastIP[key], nil
+}
+
+// updateParseRecord ips 为记录类型对应的 IP，一次请求提交，结果对所有记录类型生效
+func (ddc dyndns2Conf) updateParseRecord(hostname string, ips map[string]string) (err error) {
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	var keys, values []string
+	for _, recordType := range []string{"A", "AAAA"} {
+		if ip, ok := ips[recordType]; ok {
+			keys = append(keys, hostname+"/"+recordType)
+			values = append(values, ip)
+		}
+	}
+	query := url.Values{}
+	query.Set("hostname", hostname)
+	query.Set("myip", strings.Join(values, ","))
+	req, err := http.NewRequest("GET", ddc.BaseUrl+"/nic/update?"+query.Encode(), nil)
+	if err != nil {
+		return
+	}
+	req.SetBasicAuth(ddc.Username, ddc.Password)
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ("+common.ProjectUrl+")")
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(resp.Body)
+	recv, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+
+	body := strings.TrimSpace(string(recv))
+	code := body
+	if fields := strings.Fields(body); len(fields) > 0 {
+		code = fields[0]
+	}
+	dyndns2Mutex.Lock()
+	defer dyndns2Mutex.Unlock()
+	switch code {
+	case "good", "nochg":
+		for i, key := range keys {
+			dyndns2LastIP[key] = values[i]
+			delete(dyndns2RetryAt, key)
+		}
+		return
+	case "911", "dnserr":
+		retryAt := time.Now().Add(dyndns2Backoff)
+		for _, key := range keys {
+			dyndns2RetryAt[key] = retryAt
+		}
+		err = i18n.NewError(i18n.MsgDyndns2Response, hostname, code, i18n.T(dyndns2Responses[code], retryAt.Format("2006-01-02 15:04:05")))
+		return
+	}
+	if id, ok := dyndns2Responses[code]; ok {
+		// 其余响应码都需要用户处理，继续请求可能导致账户被封禁
+		for _, key := range keys {
+			dyndns2Blocked[key] = code
+		}
+		err = i18n.NewError(i18n.MsgDyndns2Response, hostname, code, i18n.T(id))
+		return
+	}
+	if body == "" {
+		body = resp.Status
+	}
+	err = i18n.NewError(i18n.MsgDyndns2Response, hostname, body, i18n.T(i18n.MsgDyndns2Unknown))
+	return
+}
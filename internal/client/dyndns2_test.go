@@ -0,0 +1,157 @@
+package client
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func resetDyndns2State(t *testing.T) {
+	t.Helper()
+	dyndns2Mutex.Lock()
+	dyndns2LastIP = make(map[string]string)
+	dyndns2Blocked = make(map[string]string)
+	dyndns2RetryAt = make(map[string]time.Time)
+	dyndns2Mutex.Unlock()
+}
+
+// newDyndns2Stub 每个请求按顺序使用 replies 中的响应，用完后返回 good
+func newDyndns2Stub(t *testing.T, hostname subdomain, replies ...string) (*apiStub, dyndns2Conf) {
+	t.Helper()
+	resetDyndns2State(t)
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		if req.URL.Path != "/nic/update" {
+			return stubResponse{Status: 404}
+		}
+		if username, password, ok := (&http.Request{Header: req.Header}).BasicAuth(); !ok || username != "user" || password != "pass" {
+			return stubResponse{Status: 401, Body: "badauth"}
+		}
+		if len(replies) == 0 {
+			return stubResponse{Body: "good " + req.Form.Get("myip")}
+		}
+		reply := replies[0]
+		replies = replies[1:]
+		return stubResponse{Body: reply}
+	})
+	return stub, dyndns2Conf{BaseUrl: server.URL, Username: "user", Password: "pass", Hostname: hostname}
+}
+
+func TestDyndns2Responses(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     string
+		wantErr   string
+		wantRetry bool // 下一次运行是否再次请求
+	}{
+		{"good", "good 192.0.2.2", "", true},
+		{"nochg", "nochg 192.0.2.2", "", true},
+		{"badauth", "badauth", "badauth", false},
+		{"nohost", "nohost", "nohost", false},
+		{"911", "911", "911", false},
+		{"dnserr", "dnserr", "dnserr", false},
+		{"unknown", "<html>maintenance</html>", "<html>maintenance</html>", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, ddc := newDyndns2Stub(t, subdomain{A: "home.example.com"}, tt.reply)
+			_, errs := ddc.Run(enable{IPv4: true}, "192.0.2.2", "")
+			if tt.wantErr == "" && len(errs) != 0 {
+				t.Fatalf("Run() errors = %v", errs)
+			}
+			if tt.wantErr != "" && (len(errs) != 1 || !strings.Contains(errs[0].Error(), tt.wantErr)) {
+				t.Fatalf("Run() errors = %v, want %q", errs, tt.wantErr)
+			}
+			// IP 再次变化时，致命错误和退避时间内不再请求
+			_, errs = ddc.Run(enable{IPv4: true}, "192.0.2.3", "")
+			requests := len(stub.received("GET", "/nic/update"))
+			if tt.wantRetry && (requests != 2 || len(errs) != 0) {
+				t.Errorf("second Run() requests = %d, errs = %v, want a new request", requests, errs)
+			}
+			if !tt.wantRetry && (requests != 1 || len(errs) != 1) {
+				t.Errorf("second Run() requests = %d, errs = %v, want no request", requests, errs)
+			}
+		})
+	}
+}
+
+func TestDyndns2BackoffWindow(t *testing.T) {
+	stub, ddc := newDyndns2Stub(t, subdomain{A: "home.example.com"}, "911")
+	start := time.Now()
+	ddc.Run(enable{IPv4: true}, "192.0.2.2", "")
+	dyndns2Mutex.Lock()
+	retryAt := dyndns2RetryAt["home.example.com/A"]
+	dyndns2Mutex.Unlock()
+	if retryAt.Before(start.Add(dyndns2Backoff)) || retryAt.After(time.Now().Add(dyndns2Backoff)) {
+		t.Fatalf("retryAt = %v, want %v after the response", retryAt, dyndns2Backoff)
+	}
+	_, errs := ddc.Run(enable{IPv4: true}, "192.0.2.2", "")
+	if len(errs) != 1 || len(stub.received("GET", "/nic/update")) != 1 {
+		t.Fatalf("Run() within backoff errors = %v", errs)
+	}
+
+	// 退避时间过后再次请求，成功后清除退避
+	dyndns2Mutex.Lock()
+	dyndns2RetryAt["home.example.com/A"] = time.Now().Add(-time.Second)
+	dyndns2Mutex.Unlock()
+	if _, errs = ddc.Run(enable{IPv4: true}, "192.0.2.2", ""); len(errs) != 0 {
+		t.Fatalf("Run() after backoff errors = %v", errs)
+	}
+	dyndns2Mutex.Lock()
+	_, stillBackoff := dyndns2RetryAt["home.example.com/A"]
+	dyndns2Mutex.Unlock()
+	if stillBackoff || len(stub.received("GET", "/nic/update")) != 2 {
+		t.Errorf("backoff not cleared after good response")
+	}
+}
+
+func TestDyndns2CombinedMyIP(t *testing.T) {
+	stub, ddc := newDyndns2Stub(t, subdomain{A: "home.example.com", AAAA: "home.example.com"})
+	myips := func() (values []string) {
+		for _, req := range stub.received("GET", "/nic/update") {
+			if req.Form.Get("hostname") != "home.example.com" {
+				t.Errorf("hostname = %q", req.Form.Get("hostname"))
+			}
+			values = append(values, req.Form.Get("myip"))
+		}
+		return
+	}
+
+	// A 和 AAAA 一个请求提交，两条记录都有结果
+	msg, errs := ddc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(errs) != 0 || len(msg) != 2 {
+		t.Fatalf("Run() msg = %v, errs = %v", msg, errs)
+	}
+	if got := myips(); len(got) != 1 || got[0] != "192.0.2.2,2001:db8:0:0:0:0:0:2" {
+		t.Fatalf("myip = %v", got)
+	}
+	if msg, _ = ddc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2"); len(msg) != 0 || len(myips()) != 1 {
+		t.Fatalf("unchanged Run() msg = %v, myip = %v", msg, myips())
+	}
+	// 只有 IPv4 变化时仍然同时提交 IPv6，避免 AAAA 被覆盖
+	if msg, _ = ddc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.3", "2001:db8:0:0:0:0:0:2"); len(msg) != 1 {
+		t.Fatalf("Run() msg = %v", msg)
+	}
+	if got := myips(); len(got) != 2 || got[1] != "192.0.2.3,2001:db8:0:0:0:0:0:2" {
+		t.Errorf("myip = %v", got)
+	}
+
+	// 主机名不同时分别提交
+	stub, ddc = newDyndns2Stub(t, subdomain{A: "v4.example.com", AAAA: "v6.example.com"})
+	if _, errs = ddc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2"); len(errs) != 0 {
+		t.Fatal(errs)
+	}
+	requests := stub.received("GET", "/nic/update")
+	if len(requests) != 2 || requests[0].Form.Get("myip") != "192.0.2.2" || requests[1].Form.Get("hostname") != "v6.example.com" {
+		t.Errorf("requests = %v", requests)
+	}
+}
+
+func TestDyndns2CombinedBackoff(t *testing.T) {
+	stub, ddc := newDyndns2Stub(t, subdomain{A: "home.example.com", AAAA: "home.example.com"}, "911")
+	_, errs := ddc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	// 合并的请求失败后两类记录都进入退避，不再单独请求 AAAA
+	if len(errs) != 2 || len(stub.received("GET", "/nic/update")) != 1 {
+		t.Errorf("Run() errors = %v, requests = %d", errs, len(stub.received("GET", "/nic/update")))
+	}
+}
@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bufio"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const HistoryFileName = "history.jsonl"
+
+// 历史记录类型
+const (
+	HistoryIPChanged = "ip_changed"
+	HistoryUpdate    = "update"
+)
+
+// 更新结果
+const (
+	HistoryResultSuccess   = "success"
+	HistoryResultFailed    = "failed"
+	HistoryResultAborted   = "aborted"
+	HistoryResultUnchanged = "unchanged"
+)
+
+var (
+	historyMutex  sync.Mutex
+	historyLoaded bool
+	historyLastIP = make(map[string]string)
+)
+
+// HistoryEntry 历史文件中的一行
+type HistoryEntry struct {
+	Time       time.Time `json:"time"`
+	Event      string    `json:"event"`
+	Family     string    `json:"family,omitempty"`
+	Provider   string    `json:"provider,omitempty"`
+	Record     string    `json:"record,omitempty"`
+	RecordType string    `json:"record_type,omitempty"`
+	OldIP      string    `json:"old_ip,omitempty"`
+	NewIP      string    `json:"new_ip,omitempty"`
+	Result     string    `json:"result,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// HistoryFilter 查询条件，空值表示不过滤
+type HistoryFilter struct {
+	Event    string
+	Provider string
+	Record   string
+	Since    time.Time
+	Until    time.Time
+}
+
+func historyPath() string {
+	return ConfDirectoryName + "/" + HistoryFileName
+}
+
+// appendHistory 在历史文件末尾追加一行
+func appendHistory(entry HistoryEntry) (err error) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	return appendHistoryLocked(entry)
+}
+
+func appendHistoryLocked(entry HistoryEntry) (err error) {
+	if entry.Time.IsZero() {
+		entry.Time = time.Now()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+	err = common.IsDirExistAndCreate(ConfDirectoryName)
+	if err != nil {
+		return
+	}
+	file, err := os.OpenFile(historyPath(), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return
+	}
+	defer func(file *os.File) {
+		t := file.Close()
+		if t != nil {
+			err = t
+		}
+	}(file)
+	_, err = file.Write(append(line, '\n'))
+	return
+}
+
+// RecordIPHistory 与历史文件中最后一次记录的 IP 比较，发生变化时追加记录
+// 程序重启期间发生的变化也能被记录，历史文件无法读取时只记录本次运行期间的变化
+func RecordIPHistory(ipv4, ipv6 string) (err error) {
+	historyMutex.Lock()
+	defer historyMutex.Unlock()
+	if !historyLoaded {
+		entries, err2 := ReadHistory(HistoryFilter{Event: HistoryIPChanged})
+		if err2 != nil && !os.IsNotExist(err2) {
+			logger.Warn(err2.Error(), "path", historyPath())
+		}
+		for _, entry := range entries {
+			historyLastIP[entry.Family] = entry.NewIP
+		}
+		historyLoaded = true
+	}
+	for _, value := range [][2]string{{"ipv4", ipv4}, {"ipv6", ipv6}} {
+		family, ip := value[0], value[1]
+		if ip == "" || historyLastIP[family] == ip {
+			continue
+		}
+		err = appendHistoryLocked(HistoryEntry{
+			Event:  HistoryIPChanged,
+			Family: family,
+			OldIP:  historyLastIP[family],
+			NewIP:  ip,
+		})
+		if err != nil {
+			return
+		}
+		historyLastIP[family] = ip
+	}
+	return
+}
+
+// ReadHistory 读取历史文件并按条件过滤，无法解析的行 (例如写入时中断) 跳过并输出警告
+func ReadHistory(filter HistoryFilter) (entries []HistoryEntry, err error) {
+	file, err := os.Open(historyPath())
+	if err != nil {
+		return
+	}
+	defer func(file *os.File) {
+		t := file.Close()
+		if t != nil {
+			err = t
+		}
+	}(file)
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		entry := HistoryEntry{}
+		if jsonErr := json.Unmarshal(scanner.Bytes(), &entry); jsonErr != nil {
+			logger.Warn(i18n.T(i18n.MsgHistoryBadLine, historyPath(), lineNumber, jsonErr))
+			continue
+		}
+		if filter.match(entry) {
+			entries = append(entries, entry)
+		}
+	}
+	err = scanner.Err()
+	return
+}
+
+func (filter HistoryFilter) match(entry HistoryEntry) bool {
+	switch {
+	case filter.Event != "" && entry.Event != filter.Event:
+		return false
+	case filter.Provider != "" && !strings.EqualFold(entry.Provider, filter.Provider):
+		return false
+	case filter.Record != "" && entry.Record != filter.Record:
+		return false
+	case !filter.Since.IsZero() && entry.Time.Before(filter.Since):
+		return false
+	case !filter.Until.IsZero() && entry.Time.After(filter.Until):
+		return false
+	}
+	return true
+}
+
+// ParseHistoryTime 支持 RFC3339、2006-01-02 和相对时间 (例 24h 7d，表示距今)
+func ParseHistoryTime(str string) (t time.Time, err error) {
+	if str == "" {
+		return
+	}
+	if t, err = time.Parse(time.RFC3339, str); err == nil {
+		return
+	}
+	if t, err = time.ParseInLocation("2006-01-02", str, time.Local); err == nil {
+		return
+	}
+	if strings.HasSuffix(str, "d") {
+		days, err2 := strconv.Atoi(strings.TrimSuffix(str, "d"))
+		if err2 == nil {
+			return time.Now().AddDate(0, 0, -days), nil
+		}
+	}
+	duration, err := time.ParseDuration(str)
+	if err != nil {
+		err = i18n.NewError(i18n.MsgHistoryBadTime, str)
+		return
+	}
+	return time.Now().Add(-duration), nil
+}
+
+// PrintHistory 逐行输出历史记录
+func PrintHistory(w io.Writer, entries []HistoryEntry) {
+	for _, entry := range entries {
+		row := entry.Time.Local().Format("2006-01-02 15:04:05") + " "
+		switch entry.Event {
+		case HistoryIPChanged:
+			old := entry.OldIP
+			if old == "" {
+				old = i18n.T(i18n.MsgHistoryNone)
+			}
+			row += i18n.T(i18n.MsgHistoryIPChanged) + " " + entry.Family + " " + old + " -> " + entry.NewIP
+		default:
+			row += i18n.T(i18n.MsgHistoryUpdate) + " " + entry.Provider + " " + entry.Record + " " + entry.RecordType + " " +
+				entry.OldIP + " -> " + entry.NewIP + " " + entry.Result
+			if entry.Error != "" {
+				row += " (" + entry.Error + ")"
+			}
+		}
+		_, _ = fmt.Fprintln(w, row)
+	}
+}
+
+// PrintHistoryStats 输出 IP 变化频率和各服务商更新结果统计
+func PrintHistoryStats(w io.Writer, entries []HistoryEntry) {
+	changes := make(map[string][]time.Time)
+	changeCount := make(map[string]int)
+	results := make(map[string]map[string]int)
+	for _, entry := range entries {
+		switch entry.Event {
+		case HistoryIPChanged:
+			changes[entry.Family] = append(changes[entry.Family], entry.Time)
+			// 没有旧 IP 的是首次检测，不算作变化
+			if entry.OldIP != "" {
+				changeCount[entry.Family]++
+			}
+		case HistoryUpdate:
+			if results[entry.Provider] == nil {
+				results[entry.Provider] = make(map[string]int)
+			}
+			results[entry.Provider][entry.Result]++
+		}
+	}
+
+	for _, family := range []string{"ipv4", "ipv6"} {
+		times := changes[family]
+		if len(times) == 0 {
+			continue
+		}
+		_, _ = fmt.Fprintln(w, i18n.T(i18n.MsgHistoryStatsChanges, family, changeCount[family],
+			times[0].Local().Format("2006-01-02 15:04:05"), times[len(times)-1].Local().Format("2006-01-02 15:04:05")))
+		if len(times) < 2 {
+			continue
+		}
+		var intervals []time.Duration
+		for i := 1; i < len(times); i++ {
+			intervals = append(intervals, times[i].Sub(times[i-1]))
+		}
+		sort.Slice(intervals, func(i, j int) bool { return intervals[i] < intervals[j] })
+		total := time.Duration(0)
+		for _, value := range intervals {
+			total += value
+		}
+		_, _ = fmt.Fprintln(w, "\t"+i18n.T(i18n.MsgHistoryStatsInterval,
+			(total/time.Duration(len(intervals))).Round(time.Second),
+			intervals[0].Round(time.Second), intervals[len(intervals)-1].Round(time.Second)))
+	}
+
+	var providers []string
+	for provider := range results {
+		providers = append(providers, provider)
+	}
+	sort.Strings(providers)
+	for _, provider := range providers {
+		count := results[provider]
+		_, _ = fmt.Fprintln(w, i18n.T(i18n.MsgHistoryStatsProvider, provider,
+			count[HistoryResultSuccess], count[HistoryResultFailed], count[HistoryResultAborted], count[HistoryResultUnchanged]))
+	}
+}
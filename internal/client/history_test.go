@@ -0,0 +1,123 @@
+package client
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// resetHistoryState 模拟程序重启，下一次 RecordIPHistory 重新读取历史文件
+func resetHistoryState(t *testing.T) {
+	t.Helper()
+	historyMutex.Lock()
+	historyLoaded = false
+	historyLastIP = make(map[string]string)
+	historyMutex.Unlock()
+}
+
+func TestRecordIPHistory(t *testing.T) {
+	ConfDirectoryName = t.TempDir()
+	resetHistoryState(t)
+	steps := []struct {
+		ipv4, ipv6 string
+		restart    bool
+		want       []HistoryEntry // 本次追加的记录
+	}{
+		{ipv4: "192.0.2.1", ipv6: "2001:db8::1", want: []HistoryEntry{
+			{Family: "ipv4", NewIP: "192.0.2.1"},
+			{Family: "ipv6", NewIP: "2001:db8::1"},
+		}},
+		{ipv4: "192.0.2.1", ipv6: "2001:db8::1"},
+		// 获取失败的 IP 为空，不记录
+		{ipv4: "192.0.2.2", want: []HistoryEntry{{Family: "ipv4", OldIP: "192.0.2.1", NewIP: "192.0.2.2"}}},
+		// 重启后与历史文件中最后的 IP 比较
+		{ipv4: "192.0.2.2", ipv6: "2001:db8::1", restart: true},
+		{ipv4: "192.0.2.3", ipv6: "2001:db8::1", restart: true, want: []HistoryEntry{
+			{Family: "ipv4", OldIP: "192.0.2.2", NewIP: "192.0.2.3"},
+		}},
+	}
+	var all []HistoryEntry
+	for i, step := range steps {
+		if step.restart {
+			resetHistoryState(t)
+		}
+		if err := RecordIPHistory(step.ipv4, step.ipv6); err != nil {
+			t.Fatalf("step %d: RecordIPHistory() error = %v", i, err)
+		}
+		all = append(all, step.want...)
+		entries, err := ReadHistory(HistoryFilter{})
+		if err != nil {
+			t.Fatalf("step %d: ReadHistory() error = %v", i, err)
+		}
+		if len(entries) != len(all) {
+			t.Fatalf("step %d: %d entries, want %d", i, len(entries), len(all))
+		}
+		for j, entry := range entries {
+			if entry.Event != HistoryIPChanged || entry.Family != all[j].Family || entry.OldIP != all[j].OldIP || entry.NewIP != all[j].NewIP {
+				t.Errorf("step %d: entry %d = %+v, want %+v", i, j, entry, all[j])
+			}
+		}
+	}
+}
+
+func TestReadHistoryFilter(t *testing.T) {
+	ConfDirectoryName = t.TempDir()
+	base := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	for i, entry := range []HistoryEntry{
+		{Event: HistoryIPChanged, Family: "ipv4", NewIP: "192.0.2.1"},
+		{Event: HistoryUpdate, Provider: "DNSPod", Record: "home.example.com", Result: HistoryResultSuccess},
+		{Event: HistoryUpdate, Provider: "Cloudflare", Record: "home.example.com", Result: HistoryResultFailed},
+		{Event: HistoryUpdate, Provider: "DNSPod", Record: "www.example.com", Result: HistoryResultUnchanged},
+	} {
+		entry.Time = base.Add(time.Duration(i) * time.Hour)
+		if err := appendHistory(entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name   string
+		filter HistoryFilter
+		want   int
+	}{
+		{"all", HistoryFilter{}, 4},
+		{"event", HistoryFilter{Event: HistoryUpdate}, 3},
+		{"provider ignores case", HistoryFilter{Provider: "dnspod"}, 2},
+		{"record", HistoryFilter{Record: "home.example.com"}, 2},
+		{"since", HistoryFilter{Since: base.Add(2 * time.Hour)}, 2},
+		{"until", HistoryFilter{Until: base.Add(time.Hour)}, 2},
+		{"combined", HistoryFilter{Provider: "DNSPod", Since: base.Add(2 * time.Hour)}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries, err := ReadHistory(tt.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(entries) != tt.want {
+				t.Errorf("ReadHistory() = %d entries, want %d", len(entries), tt.want)
+			}
+		})
+	}
+}
+
+func TestHistoryMalformedLine(t *testing.T) {
+	ConfDirectoryName = t.TempDir()
+	resetHistoryState(t)
+	content := `{"time":"2024-05-01T12:00:00Z","event":"ip_changed","family":"ipv4","new_ip":"192.0.2.1"}` + "\n" +
+		`{"time":"2024-05-01T13:00:00Z","event":"ip_cha` + "\n"
+	if err := os.WriteFile(historyPath(), []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	// 中断写入的行被跳过，不影响之后的记录
+	entries, err := ReadHistory(HistoryFilter{})
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("ReadHistory() = %v, %v", entries, err)
+	}
+	if err = RecordIPHistory("192.0.2.2", ""); err != nil {
+		t.Fatalf("RecordIPHistory() error = %v", err)
+	}
+	entries, _ = ReadHistory(HistoryFilter{})
+	if len(entries) != 2 || entries[1].OldIP != "192.0.2.1" || entries[1].NewIP != "192.0.2.2" {
+		t.Errorf("entries = %+v", entries)
+	}
+}
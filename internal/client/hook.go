@@ -0,0 +1,145 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const HookConfFileName = "hook.json"
+
+// 钩子类型
+const (
+	HookPreCheck   = "pre_check"
+	HookPostCheck  = "post_check"
+	HookPreUpdate  = "pre_update"
+	HookPostUpdate = "post_update"
+)
+
+// 钩子结果
+const (
+	HookResultSuccess = "success"
+	HookResultFailed  = "failed"
+)
+
+var Hc = hookConf{}
+
+// HookEvent 通过环境变量和标准输入传给钩子命令的内容
+type HookEvent struct {
+	Hook       string `json:"hook"`
+	Provider   string `json:"provider,omitempty"`
+	Record     string `json:"record,omitempty"`
+	RecordType string `json:"record_type,omitempty"`
+	OldIP      string `json:"old_ip,omitempty"`
+	NewIP      string `json:"new_ip,omitempty"`
+	IPv4       string `json:"ipv4,omitempty"`
+	IPv6       string `json:"ipv6,omitempty"`
+	OldIPv4    string `json:"old_ipv4,omitempty"`
+	OldIPv6    string `json:"old_ipv6,omitempty"`
+	Result     string `json:"result,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type hookConf struct {
+	PreCheck       []string `json:"pre_check"`
+	PostCheck      []string `json:"post_check"`
+	PreUpdate      []string `json:"pre_update"`
+	PostUpdate     []string `json:"post_update"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+func (hc *hookConf) InitConf() (msg string, err error) {
+	*hc = hookConf{}
+	hc.PreCheck = []string{}
+	hc.PostCheck = []string{}
+	hc.PreUpdate = []string{}
+	hc.PostUpdate = []string{}
+	hc.TimeoutSeconds = 30
+	err = common.MarshalAndSave(hc, ConfDirectoryName+"/"+HookConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+HookConfFileName)
+	return
+}
+
+func (hc *hookConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+HookConfFileName, &hc)
+	if err != nil {
+		return
+	}
+	if len(hc.PreCheck) == 0 && len(hc.PostCheck) == 0 && len(hc.PreUpdate) == 0 && len(hc.PostUpdate) == 0 {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+HookConfFileName, "pre_check, post_check, pre_update, post_update")
+	}
+	return
+}
+
+// Run 执行钩子命令，命令超时或退出码不为 0 时返回错误
+// pre_check 和 pre_update 返回错误时会中止本次检查或更新
+func (hc hookConf) Run(event HookEvent) (err error) {
+	if !Conf.Enable.Hook {
+		return
+	}
+	var command []string
+	switch event.Hook {
+	case HookPreCheck:
+		command = hc.PreCheck
+	case HookPostCheck:
+		command = hc.PostCheck
+	case HookPreUpdate:
+		command = hc.PreUpdate
+	case HookPostUpdate:
+		command = hc.PostUpdate
+	}
+	if len(command) == 0 {
+		return
+	}
+	stdin, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	env := []string{
+		"DDNS_HOOK=" + event.Hook,
+		"DDNS_PROVIDER=" + event.Provider,
+		"DDNS_RECORD=" + event.Record,
+		"DDNS_RECORD_TYPE=" + event.RecordType,
+		"DDNS_OLD_IP=" + event.OldIP,
+		"DDNS_NEW_IP=" + event.NewIP,
+		"DDNS_IPV4=" + event.IPv4,
+		"DDNS_IPV6=" + event.IPv6,
+		"DDNS_OLD_IPV4=" + event.OldIPv4,
+		"DDNS_OLD_IPV6=" + event.OldIPv6,
+		"DDNS_RESULT=" + event.Result,
+		"DDNS_ERROR=" + event.Error,
+	}
+	err = runCommand(command, time.Duration(hc.TimeoutSeconds)*time.Second, env, stdin)
+	if err != nil {
+		err = i18n.NewError(i18n.MsgHookFailed, event.Hook, err)
+	}
+	return
+}
+
+// runCommand 执行外部命令，env 追加在当前环境变量之后，stdin 作为标准输入
+func runCommand(command []string, timeout time.Duration, env []string, stdin []byte) (err error) {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...)
+	cmd.Stdin = bytes.NewReader(stdin)
+	cmd.Env = append(os.Environ(), env...)
+	output, err := cmd.CombinedOutput()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = i18n.NewError(i18n.MsgCommandTimeout, command[0], timeout)
+		return
+	}
+	if err != nil {
+		err = errors.New(command[0] + ": " + err.Error() + " " + strings.TrimSpace(string(output)))
+	}
+	return
+}
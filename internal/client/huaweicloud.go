@@ -0,0 +1,339 @@
+package client
+
+import (
+	"bytes"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const HuaweiCloudConfFileName = "huaweicloud.json"
+
+const huaweiCloudAlgorithm = "SDK-HMAC-SHA256"
+
+var (
+	huaweiCloudMutex sync.Mutex
+	// huaweiCloudZoneIds 按名称查询到的公网域名 ID，程序运行期间不会变化
+	huaweiCloudZoneIds = make(map[string]string)
+)
+
+type huaweiCloudConf struct {
+	AccessKey string    `json:"access_key"`
+	SecretKey string    `json:"secret_key"`
+	ProjectId string    `json:"project_id"`
+	Region    string    `json:"region"`
+	Endpoint  string    `json:"endpoint"`
+	ZoneId    string    `json:"zone_id"`
+	ZoneName  string    `json:"zone_name"`
+	Domain    subdomain `json:"domain"`
+	TTL       int       `json:"ttl"`
+}
+
+type huaweiCloudRecordSet struct {
+	Id      string   `json:"id,omitempty"`
+	Name    string   `json:"name"`
+	Type    string   `json:"type"`
+	TTL     int      `json:"ttl,omitempty"`
+	Records []string `json:"records"`
+}
+
+func (hcc *huaweiCloudConf) InitConf() (msg string, err error) {
+	*hcc = huaweiCloudConf{}
+	hcc.AccessKey = i18n.T(i18n.MsgPlaceholderGetAt, "https://console.huaweicloud.com/iam/#/mine/accessKey")
+	hcc.SecretKey = hcc.AccessKey
+	hcc.Region = "cn-north-4"
+	hcc.ZoneName = "example.com"
+	hcc.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	hcc.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	hcc.TTL = 300
+	err = common.MarshalAndSave(hcc, ConfDirectoryName+"/"+HuaweiCloudConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+HuaweiCloudConfFileName)
+	return
+}
+
+func (hcc *huaweiCloudConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+HuaweiCloudConfFileName, &hcc)
+	if err != nil {
+		return
+	}
+	if hcc.AccessKey == "" || hcc.SecretKey == "" || (hcc.ZoneId == "" && hcc.ZoneName == "") || (hcc.Domain.A == "" && hcc.Domain.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+HuaweiCloudConfFileName, "access_key, secret_key, zone_id or zone_name, domain")
+		return
+	}
+	if hcc.Endpoint == "" {
+		if hcc.Region == "" {
+			hcc.Region = "cn-north-4"
+		}
+		hcc.Endpoint = "https://dns." + hcc.Region + ".myhuaweicloud.com"
+	}
+	if _, err = url.Parse(hcc.Endpoint); err != nil {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+HuaweiCloudConfFileName, "endpoint")
+		return
+	}
+	hcc.Endpoint = strings.TrimSuffix(hcc.Endpoint, "/")
+	if hcc.TTL <= 0 {
+		hcc.TTL = 300
+	}
+	return
+}
+
+func (hcc huaweiCloudConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	if enabled.IPv4 && hcc.Domain.A != "" {
+		msgRow, err := hcc.update(hcc.Domain.A, "A", ipv4)
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	if enabled.IPv6 && hcc.Domain.AAAA != "" {
+		msgRow, err := hcc.update(hcc.Domain.AAAA, "AAAA", ipv6)
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// update 查询到的记录集在两次回调之间共享，记录集不存在时新建
+func (hcc huaweiCloudConf) update(domain, recordType, ipAddr string) (msg string, err error) {
+	var recordSet *huaweiCloudRecordSet
+	return updateRecord("HuaweiCloud", domain, recordType, ipAddr,
+		func() (recordIP string, err error) {
+			recordSet, err = hcc.getParseRecord(domain, recordType)
+			if recordSet != nil {
+				var values []string
+				for _, value := range recordSet.Records {
+					if recordType == "AAAA" {
+						value = common.DecodeIPv6(value)
+					}
+					values = append(values, value)
+				}
+				sort.Strings(values)
+				recordIP = strings.Join(values, ",")
+			}
+			return
+		},
+		func() error {
+			if recordSet == nil {
+				return hcc.createRecord(ipAddr, recordType, domain)
+			}
+			return hcc.updateParseRecord(ipAddr, *recordSet)
+		})
+}
+
+// zoneId 未配置 zone_id 时按 zone_name 查询并缓存
+func (hcc huaweiCloudConf) zoneId() (id string, err error) {
+	if hcc.ZoneId != "" {
+		return hcc.ZoneId, nil
+	}
+	zoneName := strings.ToLower(strings.TrimSuffix(hcc.ZoneName, ".") + ".")
+	huaweiCloudMutex.Lock()
+	id, ok := huaweiCloudZoneIds[zoneName]
+	huaweiCloudMutex.Unlock()
+	if ok {
+		return
+	}
+	query := url.Values{}
+	query.Set("type", "public")
+	query.Set("name", zoneName)
+	var resp struct {
+		Zones []struct {
+			Id   string `json:"id"`
+			Name string `json:"name"`
+		} `json:"zones"`
+	}
+	err = hcc.call("GET", "/v2/zones", query, nil, &resp)
+	if err != nil {
+		return
+	}
+	// name 参数是模糊匹配，需要再比较一次
+	for _, zone := range resp.Zones {
+		if strings.ToLower(zone.Name) == zoneName {
+			id = zone.Id
+		}
+	}
+	if id == "" {
+		err = i18n.NewError(i18n.MsgHuaweiCloudZoneNotFound, hcc.ZoneName)
+		return
+	}
+	huaweiCloudMutex.Lock()
+	huaweiCloudZoneIds[zoneName] = id
+	huaweiCloudMutex.Unlock()
+	return
+}
+
+func (hcc huaweiCloudConf) getParseRecord(domain, recordType string) (recordSet *huaweiCloudRecordSet, err error) {
+	zoneId, err := hcc.zoneId()
+	if err != nil {
+		return
+	}
+	name := strings.ToLower(strings.TrimSuffix(domain, ".") + ".")
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("type", recordType)
+	var resp struct {
+		RecordSets []huaweiCloudRecordSet `json:"recordsets"`
+	}
+	err = hcc.call("GET", "/v2/zones/"+zoneId+"/recordsets", query, nil, &resp)
+	if err != nil {
+		return
+	}
+	for i := range resp.RecordSets {
+		if strings.ToLower(resp.RecordSets[i].Name) == name && resp.RecordSets[i].Type == recordType {
+			recordSet = &resp.RecordSets[i]
+			break
+		}
+	}
+	return
+}
+
+func (hcc huaweiCloudConf) updateParseRecord(ipAddr string, recordSet huaweiCloudRecordSet) (err error) {
+	zoneId, err := hcc.zoneId()
+	if err != nil {
+		return
+	}
+	reqJson, err := json.Marshal(huaweiCloudRecordSet{
+		Name:    recordSet.Name,
+		Type:    recordSet.Type,
+		TTL:     hcc.TTL,
+		Records: []string{ipAddr},
+	})
+	if err != nil {
+		return
+	}
+	err = hcc.call("PUT", "/v2/zones/"+zoneId+"/recordsets/"+recordSet.Id, nil, reqJson, nil)
+	return
+}
+
+func (hcc huaweiCloudConf) createRecord(ipAddr, recordType, domain string) (err error) {
+	zoneId, err := hcc.zoneId()
+	if err != nil {
+		return
+	}
+	reqJson, err := json.Marshal(huaweiCloudRecordSet{
+		Name:    strings.TrimSuffix(domain, ".") + ".",
+		Type:    recordType,
+		TTL:     hcc.TTL,
+		Records: []string{ipAddr},
+	})
+	if err != nil {
+		return
+	}
+	err = hcc.call("POST", "/v2/zones/"+zoneId+"/recordsets", nil, reqJson, nil)
+	return
+}
+
+// call 发送 AK/SK 签名的请求，响应不是 2xx 时返回 MsgHuaweiCloudAPI 错误
+func (hcc huaweiCloudConf) call(method, path string, query url.Values, payload []byte, result any) (err error) {
+	rawUrl := hcc.Endpoint + path
+	if len(query) > 0 {
+		rawUrl += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, rawUrl, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if hcc.ProjectId != "" {
+		req.Header.Set("X-Project-Id", hcc.ProjectId)
+	}
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ("+common.ProjectUrl+")")
+	signHuaweiCloud(req, payload, hcc.AccessKey, hcc.SecretKey, time.Now())
+
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(resp.Body)
+	recvJson, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// DNS 接口返回 code message，网关返回 error_code error_msg
+		var respErr struct {
+			Code      string `json:"code"`
+			Message   string `json:"message"`
+			ErrorCode string `json:"error_code"`
+			ErrorMsg  string `json:"error_msg"`
+		}
+		_ = json.Unmarshal(recvJson, &respErr)
+		if respErr.Code == "" {
+			respErr.Code, respErr.Message = respErr.ErrorCode, respErr.ErrorMsg
+		}
+		if respErr.Code == "" {
+			respErr.Code, respErr.Message = resp.Status, strings.TrimSpace(string(recvJson))
+		}
+		err = i18n.NewError(i18n.MsgHuaweiCloudAPI, respErr.Code, respErr.Message, resp.Header.Get("X-Request-Id"))
+		return
+	}
+	if result != nil {
+		err = json.Unmarshal(recvJson, result)
+	}
+	return
+}
+
+// signHuaweiCloud 按 SDK-HMAC-SHA256 设置 X-Sdk-Date 和 Authorization 请求头
+// 签名 host 和所有已设置的请求头 (User-Agent 除外)
+func signHuaweiCloud(req *http.Request, payload []byte, accessKey, secretKey string, now time.Time) {
+	sdkDate := now.UTC().Format("20060102T150405Z")
+	req.Header.Set("X-Sdk-Date", sdkDate)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		if strings.EqualFold(name, "User-Agent") || len(values) == 0 {
+			continue
+		}
+		headers[strings.ToLower(name)] = strings.TrimSpace(values[0])
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	// 路径的每一段分别编码，并且以 / 结尾
+	segments := strings.Split(req.URL.Path, "/")
+	for i, segment := range segments {
+		segments[i] = uriEncodeV4(segment)
+	}
+	canonicalPath := strings.Join(segments, "/")
+	if !strings.HasSuffix(canonicalPath, "/") {
+		canonicalPath += "/"
+	}
+	canonicalRequest := req.Method + "\n" +
+		canonicalPath + "\n" +
+		canonicalQueryV4(req.URL.Query()) + "\n" +
+		canonicalHeaders + "\n" +
+		signedHeaders + "\n" +
+		sha256Hex(payload)
+	stringToSign := huaweiCloudAlgorithm + "\n" + sdkDate + "\n" + sha256Hex([]byte(canonicalRequest))
+	signature := hex.EncodeToString(hmacSHA256([]byte(secretKey), stringToSign))
+	req.Header.Set("Authorization", huaweiCloudAlgorithm+" Access="+accessKey+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
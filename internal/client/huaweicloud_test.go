@@ -0,0 +1,288 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 华为云替身接受的访问密钥
+const (
+	huaweiCloudTestAK = "AKTEST"
+	huaweiCloudTestSK = "SKTEST"
+)
+
+// newHuaweiCloudStub 华为云 DNS 接口的本地替身，按 SDK-HMAC-SHA256 重新计算签名，不一致时返回 401
+// recordSets 只能在 stub.inspect 中访问
+func newHuaweiCloudStub(t *testing.T) (stub *apiStub, recordSets map[string]huaweiCloudRecordSet, endpoint string) {
+	t.Helper()
+	recordSets = map[string]huaweiCloudRecordSet{
+		"rs-a": {Id: "rs-a", Name: "home.example.com.", Type: "A", TTL: 300, Records: []string{"192.0.2.1"}},
+		// 同名的 TXT 记录集不能被选中
+		"rs-txt": {Id: "rs-txt", Name: "home.example.com.", Type: "TXT", TTL: 300, Records: []string{`"v=spf1 -all"`}},
+	}
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		if msg := verifyHuaweiCloud(req, huaweiCloudTestAK, huaweiCloudTestSK); msg != "" {
+			return stubResponse{Status: 401, Header: map[string]string{"X-Request-Id": "req-denied"},
+				Body: map[string]string{"error_code": "APIGW.0301", "error_msg": msg}}
+		}
+		path := req.URL.Path
+		switch {
+		case req.Method == "GET" && path == "/v2/zones":
+			if req.URL.Query().Get("type") != "public" {
+				t.Errorf("zones query = %v", req.URL.Query())
+			}
+			return stubResponse{Body: `{"zones": [{"id": "zone-other", "name": "sub.example.com."}, {"id": "zone-1", "name": "example.com."}]}`}
+		case req.Method == "GET" && path == "/v2/zones/zone-1/recordsets":
+			var resp struct {
+				RecordSets []huaweiCloudRecordSet `json:"recordsets"`
+			}
+			for _, recordSet := range recordSets {
+				if recordSet.Name == req.URL.Query().Get("name") {
+					resp.RecordSets = append(resp.RecordSets, recordSet)
+				}
+			}
+			sort.Slice(resp.RecordSets, func(i, j int) bool { return resp.RecordSets[i].Type > resp.RecordSets[j].Type })
+			return stubResponse{Body: resp}
+		case req.Method == "PUT" && strings.HasPrefix(path, "/v2/zones/zone-1/recordsets/"):
+			var recordSet huaweiCloudRecordSet
+			_ = json.Unmarshal(req.Body, &recordSet)
+			recordSet.Id = strings.TrimPrefix(path, "/v2/zones/zone-1/recordsets/")
+			recordSets[recordSet.Id] = recordSet
+			return stubResponse{Body: recordSet}
+		case req.Method == "POST" && path == "/v2/zones/zone-1/recordsets":
+			var recordSet huaweiCloudRecordSet
+			_ = json.Unmarshal(req.Body, &recordSet)
+			recordSet.Id = "rs-new-" + recordSet.Type
+			recordSets[recordSet.Id] = recordSet
+			return stubResponse{Status: 202, Body: recordSet}
+		}
+		return stubResponse{Status: 404, Body: `{"code": "DNS.0001", "message": "not found"}`}
+	})
+	return stub, recordSets, server.URL
+}
+
+// verifyHuaweiCloud 独立于 signHuaweiCloud 按文档重新计算签名，返回空字符串表示通过
+func verifyHuaweiCloud(req stubRequest, accessKey, secretKey string) string {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "SDK-HMAC-SHA256 ") {
+		return "missing signature"
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "SDK-HMAC-SHA256 "), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[key] = value
+	}
+	if params["Access"] != accessKey {
+		return "unknown access key"
+	}
+	sdkDate, err := time.Parse("20060102T150405Z", req.Header.Get("X-Sdk-Date"))
+	if err != nil || time.Since(sdkDate) > 15*time.Minute || time.Until(sdkDate) > 15*time.Minute {
+		return "bad X-Sdk-Date"
+	}
+	signedHeaders := strings.Split(params["SignedHeaders"], ";")
+	if !sort.StringsAreSorted(signedHeaders) {
+		return "signed headers not sorted"
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range signedHeaders {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	for _, required := range []string{"host", "x-sdk-date", "content-type"} {
+		if !strings.Contains(";"+params["SignedHeaders"]+";", ";"+required+";") {
+			return required + " not signed"
+		}
+	}
+	path := req.URL.EscapedPath()
+	if !strings.HasSuffix(path, "/") {
+		path += "/"
+	}
+	var pairs []string
+	for key, values := range req.URL.Query() {
+		for _, value := range values {
+			pairs = append(pairs, escapeRFC3986(key)+"="+escapeRFC3986(value))
+		}
+	}
+	sort.Strings(pairs)
+	payloadHash := sha256.Sum256(req.Body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		path,
+		strings.Join(pairs, "&"),
+		canonicalHeaders.String(),
+		params["SignedHeaders"],
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "SDK-HMAC-SHA256\n" + req.Header.Get("X-Sdk-Date") + "\n" + hex.EncodeToString(requestHash[:])
+	mac := hmac.New(sha256.New, []byte(secretKey))
+	mac.Write([]byte(stringToSign))
+	if !hmac.Equal([]byte(hex.EncodeToString(mac.Sum(nil))), []byte(params["Signature"])) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+func escapeRFC3986(value string) string {
+	return strings.ReplaceAll(url.QueryEscape(value), "+", "%20")
+}
+
+func resetHuaweiCloudZoneIds() {
+	huaweiCloudMutex.Lock()
+	huaweiCloudZoneIds = make(map[string]string)
+	huaweiCloudMutex.Unlock()
+}
+
+func TestHuaweiCloudSignedUpdate(t *testing.T) {
+	resetHuaweiCloudZoneIds()
+	stub, recordSets, endpoint := newHuaweiCloudStub(t)
+	hcc := huaweiCloudConf{
+		AccessKey: huaweiCloudTestAK,
+		SecretKey: huaweiCloudTestSK,
+		ProjectId: "project-1",
+		Endpoint:  endpoint,
+		ZoneName:  "example.com",
+		Domain:    subdomain{A: "home.example.com", AAAA: "home.example.com"},
+		TTL:       120,
+	}
+	msg, errs := hcc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(errs) != 0 {
+		t.Fatalf("Run() errors = %v", errs)
+	}
+	if len(msg) != 2 {
+		t.Fatalf("Run() msg = %v", msg)
+	}
+	// A 记录集原地更新，TXT 记录集不受影响，AAAA 记录集不存在时新建
+	stub.inspect(func() {
+		if got := recordSets["rs-a"]; len(got.Records) != 1 || got.Records[0] != "192.0.2.2" || got.TTL != 120 {
+			t.Errorf("A record set = %+v", got)
+		}
+		if got := recordSets["rs-txt"]; got.Records[0] != `"v=spf1 -all"` {
+			t.Errorf("TXT record set changed: %+v", got)
+		}
+		if got := recordSets["rs-new-AAAA"]; got.Name != "home.example.com." || got.Records[0] != "2001:db8:0:0:0:0:0:2" {
+			t.Errorf("AAAA record set = %+v", got)
+		}
+	})
+	// 区域 ID 只查询一次
+	if zoneQueries := len(stub.received("GET", "/v2/zones")); zoneQueries != 1 {
+		t.Errorf("zone queried %d times", zoneQueries)
+	}
+}
+
+func TestHuaweiCloudBadSignature(t *testing.T) {
+	resetHuaweiCloudZoneIds()
+	stub, recordSets, endpoint := newHuaweiCloudStub(t)
+	hcc := huaweiCloudConf{
+		AccessKey: huaweiCloudTestAK,
+		SecretKey: "wrong",
+		Endpoint:  endpoint,
+		ZoneId:    "zone-1",
+		Domain:    subdomain{A: "home.example.com"},
+		TTL:       300,
+	}
+	// 错误信息带有错误码和请求 ID
+	_, errs := hcc.Run(enable{IPv4: true}, "192.0.2.2", "")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "APIGW.0301") || !strings.Contains(errs[0].Error(), "req-denied") {
+		t.Fatalf("Run() errors = %v, want APIGW.0301 with request id", errs)
+	}
+	stub.inspect(func() {
+		if got := recordSets["rs-a"]; got.Records[0] != "192.0.2.1" {
+			t.Errorf("record set changed with a bad signature: %+v", got)
+		}
+	})
+}
+
+func TestSignHuaweiCloudCanonicalization(t *testing.T) {
+	tests := []struct {
+		name    string
+		method  string
+		rawUrl  string
+		payload string
+	}{
+		{"get with query", "GET", "https://dns.example.com/v2/zones?type=public&name=example.com.", ""},
+		{"query needs escaping", "GET", "https://dns.example.com/v2/zones/z/recordsets?name=a%20b.example.com.&type=AAAA", ""},
+		{"put with body", "PUT", "https://dns.example.com/v2/zones/z/recordsets/r", `{"records":["192.0.2.1"]}`},
+		{"path with trailing slash", "POST", "https://dns.example.com/v2/zones/z/recordsets/", `{}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.rawUrl, strings.NewReader(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("X-Project-Id", "p")
+			req.Header.Set("User-Agent", "unsigned")
+			signHuaweiCloud(req, []byte(tt.payload), "AK", "SK", time.Now())
+			// 服务端收到的请求 Host 来自 URL
+			req.Host = req.URL.Host
+			if msg := verifyHuaweiCloud(newStubRequest(req), "AK", "SK"); msg != "" {
+				t.Errorf("verify() = %s, Authorization = %s", msg, req.Header.Get("Authorization"))
+			}
+			if strings.Contains(req.Header.Get("Authorization"), "user-agent") {
+				t.Errorf("User-Agent must not be signed: %s", req.Header.Get("Authorization"))
+			}
+		})
+	}
+}
+
+func TestHuaweiCloudRegionEndpoint(t *testing.T) {
+	tests := []struct {
+		name     string
+		region   string
+		endpoint string
+		want     string
+	}{
+		{"default region", "", "", "https://dns.cn-north-4.myhuaweicloud.com"},
+		{"region", "ap-southeast-1", "", "https://dns.ap-southeast-1.myhuaweicloud.com"},
+		{"endpoint overrides region", "ap-southeast-1", "https://dns.example.internal/", "https://dns.example.internal"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ConfDirectoryName = t.TempDir()
+			content, _ := json.Marshal(huaweiCloudConf{
+				AccessKey: "AK",
+				SecretKey: "SK",
+				Region:    tt.region,
+				Endpoint:  tt.endpoint,
+				ZoneId:    "zone-1",
+				Domain:    subdomain{A: "home.example.com"},
+			})
+			if err := os.WriteFile(ConfDirectoryName+"/"+HuaweiCloudConfFileName, content, 0600); err != nil {
+				t.Fatal(err)
+			}
+			hcc := huaweiCloudConf{}
+			if err := hcc.LoadConf(); err != nil {
+				t.Fatal(err)
+			}
+			if hcc.Endpoint != tt.want {
+				t.Errorf("Endpoint = %q, want %q", hcc.Endpoint, tt.want)
+			}
+		})
+	}
+
+	// 请求发往配置的 endpoint，而不是固定的区域
+	resetHuaweiCloudZoneIds()
+	stub, _, endpoint := newHuaweiCloudStub(t)
+	hcc := huaweiCloudConf{AccessKey: huaweiCloudTestAK, SecretKey: huaweiCloudTestSK, Region: "cn-hangzhou", Endpoint: endpoint,
+		ZoneId: "zone-1", Domain: subdomain{A: "home.example.com"}, TTL: 300}
+	if _, errs := hcc.Run(enable{IPv4: true}, "192.0.2.1", ""); len(errs) != 0 {
+		t.Fatalf("Run() errors = %v", errs)
+	}
+	if len(stub.received("GET", "/v2/zones/zone-1/recordsets")) == 0 {
+		t.Fatal("configured endpoint received no requests")
+	}
+}
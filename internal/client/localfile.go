@@ -0,0 +1,304 @@
+package client
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const LocalFileConfFileName = "local_file.json"
+
+// 输出格式
+const (
+	LocalFileHosts   = "hosts"
+	LocalFileDnsmasq = "dnsmasq"
+	LocalFileUnbound = "unbound"
+)
+
+// 受管理区块的起止标记，区块外的内容保持不变
+const (
+	localFileBegin = "# BEGIN " + RunningName
+	localFileEnd   = "# END " + RunningName
+)
+
+var localFileMutex sync.Mutex
+
+type localFileConf struct {
+	Domain  subdomain         `json:"domain"`
+	TTL     int               `json:"ttl"`
+	Targets []localFileTarget `json:"targets"`
+}
+
+// localFileTarget reload_command 在文件内容变化后执行
+type localFileTarget struct {
+	Format         string   `json:"format"`
+	Path           string   `json:"path"`
+	ReloadCommand  []string `json:"reload_command"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+func (lfc *localFileConf) InitConf() (msg string, err error) {
+	*lfc = localFileConf{}
+	lfc.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	lfc.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	lfc.TTL = 300
+	lfc.Targets = []localFileTarget{
+		{
+			Format:        LocalFileHosts,
+			Path:          "/etc/hosts",
+			ReloadCommand: []string{},
+		},
+		{
+			Format:        LocalFileDnsmasq,
+			Path:          "/etc/dnsmasq.d/" + RunningName + ".conf",
+			ReloadCommand: []string{"systemctl", "restart", "dnsmasq"},
+		},
+		{
+			Format:        LocalFileUnbound,
+			Path:          "/etc/unbound/unbound.conf.d/" + RunningName + ".conf",
+			ReloadCommand: []string{"unbound-control", "reload"},
+		},
+	}
+	err = common.MarshalAndSave(lfc, ConfDirectoryName+"/"+LocalFileConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+LocalFileConfFileName)
+	return
+}
+
+func (lfc *localFileConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+LocalFileConfFileName, &lfc)
+	if err != nil {
+		return
+	}
+	if len(lfc.Targets) == 0 || (lfc.Domain.A == "" && lfc.Domain.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+LocalFileConfFileName, "domain, targets")
+		return
+	}
+	for _, target := range lfc.Targets {
+		switch target.Format {
+		case LocalFileHosts, LocalFileDnsmasq, LocalFileUnbound:
+		default:
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+LocalFileConfFileName, "targets.format")
+			return
+		}
+		if target.Path == "" {
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+LocalFileConfFileName, "targets.path")
+			return
+		}
+	}
+	if lfc.TTL <= 0 {
+		lfc.TTL = 300
+	}
+	return
+}
+
+func (lfc localFileConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	if enabled.IPv4 && lfc.Domain.A != "" {
+		msgRow, err := updateRecord("LocalFile", lfc.Domain.A, "A", ipv4,
+			func() (string, error) { return lfc.getParseRecord(lfc.Domain.A, "A") },
+			func() error { return lfc.updateParseRecord(ipv4, "A", lfc.Domain.A) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	if enabled.IPv6 && lfc.Domain.AAAA != "" {
+		msgRow, err := updateRecord("LocalFile", lfc.Domain.AAAA, "AAAA", ipv6,
+			func() (string, error) { return lfc.getParseRecord(lfc.Domain.AAAA, "AAAA") },
+			func() error { return lfc.updateParseRecord(ipv6, "AAAA", lfc.Domain.AAAA) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// getParseRecord 所有文件中的记录一致时返回该记录，否则返回空字符串使其全部重写
+func (lfc localFileConf) getParseRecord(domain, recordType string) (recordIP string, err error) {
+	localFileMutex.Lock()
+	defer localFileMutex.Unlock()
+	for i, target := range lfc.Targets {
+		var entries map[string]string
+		_, entries, _, err = target.read()
+		if err != nil {
+			return
+		}
+		value := entries[localFileKey(domain, recordType)]
+		if i > 0 && value != recordIP {
+			return "", nil
+		}
+		recordIP = value
+	}
+	return
+}
+
+func (lfc localFileConf) updateParseRecord(ipAddr, recordType, domain string) (err error) {
+	localFileMutex.Lock()
+	defer localFileMutex.Unlock()
+	for _, target := range lfc.Targets {
+		before, entries, after, readErr := target.read()
+		if readErr != nil {
+			return readErr
+		}
+		if entries[localFileKey(domain, recordType)] == ipAddr {
+			continue
+		}
+		entries[localFileKey(domain, recordType)] = ipAddr
+		err = target.write(before, target.block(entries, lfc.TTL), after)
+		if err != nil {
+			return
+		}
+		if len(target.ReloadCommand) > 0 {
+			err = runCommand(target.ReloadCommand, time.Duration(target.TimeoutSeconds)*time.Second, nil, nil)
+			if err != nil {
+				err = i18n.NewError(i18n.MsgLocalFileReload, target.Path, err)
+				return
+			}
+		}
+	}
+	return
+}
+
+func localFileKey(domain, recordType string) string {
+	return strings.ToLower(strings.TrimSuffix(domain, ".")) + "/" + recordType
+}
+
+// read 读取文件，返回区块之前的内容、区块中的记录和区块之后的内容，文件不存在时都为空
+func (target localFileTarget) read() (before string, entries map[string]string, after string, err error) {
+	entries = make(map[string]string)
+	content, err := os.ReadFile(target.Path)
+	if os.IsNotExist(err) {
+		return "", entries, "", nil
+	}
+	if err != nil {
+		return
+	}
+	text := string(content)
+	begin := strings.Index(text, localFileBegin+"\n")
+	if begin < 0 {
+		return text, entries, "", nil
+	}
+	end := strings.Index(text[begin:], localFileEnd)
+	if end < 0 {
+		err = i18n.NewError(i18n.MsgLocalFileBlock, target.Path, localFileEnd)
+		return
+	}
+	end += begin
+	before = text[:begin]
+	after = strings.TrimPrefix(text[end+len(localFileEnd):], "\n")
+	for _, line := range strings.Split(text[begin+len(localFileBegin)+1:end], "\n") {
+		if domain, recordType, ip, ok := target.parseLine(line); ok {
+			entries[localFileKey(domain, recordType)] = ip
+		}
+	}
+	return
+}
+
+// block 生成区块内容，记录按名称和类型排序
+func (target localFileTarget) block(entries map[string]string, ttl int) string {
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	builder := strings.Builder{}
+	builder.WriteString(localFileBegin + "\n")
+	for _, key := range keys {
+		i := strings.LastIndex(key, "/")
+		builder.WriteString(target.formatLine(key[:i], key[i+1:], entries[key], ttl) + "\n")
+	}
+	builder.WriteString(localFileEnd + "\n")
+	return builder.String()
+}
+
+func (target localFileTarget) formatLine(domain, recordType, ip string, ttl int) string {
+	switch target.Format {
+	case LocalFileDnsmasq:
+		return "host-record=" + domain + "," + ip
+	case LocalFileUnbound:
+		return `local-data: "` + domain + ". " + strconv.Itoa(ttl) + " IN " + recordType + " " + ip + `"`
+	default:
+		return ip + "\t" + domain
+	}
+}
+
+func (target localFileTarget) parseLine(line string) (domain, recordType, ip string, ok bool) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return
+	}
+	switch target.Format {
+	case LocalFileDnsmasq:
+		fields := strings.Split(strings.TrimPrefix(line, "host-record="), ",")
+		if len(fields) < 2 {
+			return
+		}
+		domain, ip = fields[0], fields[1]
+	case LocalFileUnbound:
+		fields := strings.Fields(strings.Trim(strings.TrimPrefix(line, "local-data:"), ` "`))
+		if len(fields) < 5 {
+			return
+		}
+		domain, ip = strings.TrimSuffix(fields[0], "."), fields[4]
+	default:
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return
+		}
+		domain, ip = fields[1], fields[0]
+	}
+	recordType = "A"
+	if strings.Contains(ip, ":") {
+		recordType = "AAAA"
+		ip = common.DecodeIPv6(ip)
+	}
+	return domain, recordType, ip, true
+}
+
+// write 先写入同目录的临时文件再重命名，保持原文件权限
+// /etc/hosts 在容器中通常是挂载点无法重命名，此时直接覆盖写入
+func (target localFileTarget) write(before, block, after string) (err error) {
+	if before != "" && !strings.HasSuffix(before, "\n") {
+		before += "\n"
+	}
+	content := []byte(before + block + after)
+	mode := os.FileMode(0644)
+	if info, statErr := os.Stat(target.Path); statErr == nil {
+		mode = info.Mode().Perm()
+	}
+	err = common.IsDirExistAndCreate(filepath.Dir(target.Path))
+	if err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(target.Path), "."+filepath.Base(target.Path)+".*")
+	if err != nil {
+		return
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+	if _, err = tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return
+	}
+	if err = tmp.Close(); err != nil {
+		return
+	}
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return
+	}
+	if err = os.Rename(tmp.Name(), target.Path); err != nil {
+		logger.Warn(i18n.T(i18n.MsgLocalFileRename, target.Path), "error", err)
+		err = os.WriteFile(target.Path, content, mode)
+	}
+	return
+}
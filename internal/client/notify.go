@@ -0,0 +1,380 @@
+package client
+
+import (
+	"bytes"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+const NotifyConfFileName = "notify.json"
+
+// 通知事件类型
+const (
+	EventIPChanged     = "ip_changed"
+	EventRecordUpdated = "record_updated"
+	EventUpdateFailed  = "update_failed"
+	EventRecovered     = "recovered"
+)
+
+var (
+	Nc = notifyConf{}
+
+	notifyMutex      sync.Mutex
+	notifyLastSent   = make(map[string]time.Time)
+	notifySuppressed = make(map[string]int)
+	providerFailed   = make(map[string]bool)
+)
+
+// notifier 通知渠道接口
+type notifier interface {
+	Notify(event NotifyEvent) error
+}
+
+// NotifyEvent 发送给通知渠道的事件内容
+type NotifyEvent struct {
+	Event      string `json:"event"`
+	Provider   string `json:"provider,omitempty"`
+	Message    string `json:"message"`
+	IPv4       string `json:"ipv4,omitempty"`
+	IPv6       string `json:"ipv6,omitempty"`
+	OldIPv4    string `json:"old_ipv4,omitempty"`
+	OldIPv6    string `json:"old_ipv6,omitempty"`
+	Time       string `json:"time"`
+	Suppressed int    `json:"suppressed,omitempty"`
+}
+
+type webhookConf struct {
+	Enable       bool              `json:"enable"`
+	Url          string            `json:"url"`
+	Method       string            `json:"method"`
+	Headers      map[string]string `json:"headers"`
+	BodyTemplate string            `json:"body_template"`
+}
+
+type smtpConf struct {
+	Enable          bool     `json:"enable"`
+	Host            string   `json:"host"`
+	Port            int      `json:"port"`
+	Username        string   `json:"username"`
+	Password        string   `json:"password"`
+	From            string   `json:"from"`
+	To              []string `json:"to"`
+	SubjectTemplate string   `json:"subject_template"`
+}
+
+type execConf struct {
+	Enable         bool     `json:"enable"`
+	Command        []string `json:"command"`
+	TimeoutSeconds int      `json:"timeout_seconds"`
+}
+
+type notifyConf struct {
+	Events           []string    `json:"events"`
+	RateLimitSeconds int         `json:"rate_limit_seconds"`
+	Webhook          webhookConf `json:"webhook"`
+	SMTP             smtpConf    `json:"smtp"`
+	Exec             execConf    `json:"exec"`
+}
+
+func (nc *notifyConf) InitConf() (msg string, err error) {
+	*nc = notifyConf{}
+	nc.Events = []string{EventIPChanged, EventRecordUpdated, EventUpdateFailed, EventRecovered}
+	nc.RateLimitSeconds = 300
+	nc.Webhook.Url = "https://example.com/webhook"
+	nc.Webhook.Method = "POST"
+	nc.Webhook.Headers = map[string]string{"Content-Type": "application/json"}
+	nc.Webhook.BodyTemplate = `{"text": {{json .Message}}}`
+	nc.SMTP.Host = "smtp.example.com"
+	nc.SMTP.Port = 587
+	nc.SMTP.From = "ddns-watchdog@example.com"
+	nc.SMTP.To = []string{"admin@example.com"}
+	nc.SMTP.SubjectTemplate = "[" + RunningName + "] {{.Event}} {{.Provider}}"
+	nc.Exec.Command = []string{}
+	nc.Exec.TimeoutSeconds = 10
+	err = common.MarshalAndSave(nc, ConfDirectoryName+"/"+NotifyConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+NotifyConfFileName)
+	return
+}
+
+func (nc *notifyConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+NotifyConfFileName, &nc)
+	if err != nil {
+		return
+	}
+	if !nc.Webhook.Enable && !nc.SMTP.Enable && !nc.Exec.Enable {
+		err = i18n.NewError(i18n.MsgConfEnableNotifier, ConfDirectoryName+"/"+NotifyConfFileName)
+		return
+	}
+	for _, event := range nc.Events {
+		switch event {
+		case EventIPChanged, EventRecordUpdated, EventUpdateFailed, EventRecovered:
+		default:
+			err = i18n.NewError(i18n.MsgConfUnsupportedEvent, ConfDirectoryName+"/"+NotifyConfFileName, event)
+			return
+		}
+	}
+	if nc.Webhook.Enable {
+		if nc.Webhook.Url == "" {
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+NotifyConfFileName, "webhook.url")
+			return
+		}
+		if _, err = template.New("body").Funcs(templateFuncs).Parse(nc.Webhook.BodyTemplate); err != nil {
+			return
+		}
+	}
+	if nc.SMTP.Enable {
+		if nc.SMTP.Host == "" || nc.SMTP.Port == 0 || nc.SMTP.From == "" || len(nc.SMTP.To) == 0 {
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+NotifyConfFileName, "smtp.host, smtp.port, smtp.from, smtp.to")
+			return
+		}
+		if _, err = template.New("subject").Funcs(templateFuncs).Parse(nc.SMTP.SubjectTemplate); err != nil {
+			return
+		}
+	}
+	if nc.Exec.Enable && len(nc.Exec.Command) == 0 {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+NotifyConfFileName, "exec.command")
+		return
+	}
+	return
+}
+
+// Send 按事件过滤和频率限制将事件发送到所有启用的通知渠道
+func (nc notifyConf) Send(event NotifyEvent) (errs []error) {
+	if !Conf.Enable.Notify || !nc.isSubscribed(event.Event) {
+		return
+	}
+	if event.Time == "" {
+		event.Time = time.Now().Format(time.RFC3339)
+	}
+
+	// 频率限制，同一事件同一服务商在限制时间内只发送一次
+	key := event.Event + "/" + event.Provider
+	notifyMutex.Lock()
+	if last, ok := notifyLastSent[key]; ok && time.Since(last) < time.Duration(nc.RateLimitSeconds)*time.Second {
+		notifySuppressed[key]++
+		notifyMutex.Unlock()
+		return
+	}
+	notifyLastSent[key] = time.Now()
+	event.Suppressed = notifySuppressed[key]
+	delete(notifySuppressed, key)
+	notifyMutex.Unlock()
+
+	for _, n := range nc.notifiers() {
+		if err := n.Notify(event); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return
+}
+
+// ReportResult 根据服务商的运行结果发送记录更新、更新失败和恢复事件，每种事件每次运行最多发送一个
+func (nc notifyConf) ReportResult(provider string, msg []string, errs []error) (notifyErrs []error) {
+	notifyMutex.Lock()
+	failedBefore := providerFailed[provider]
+	providerFailed[provider] = len(errs) > 0
+	notifyMutex.Unlock()
+
+	// 同一次运行的多条结果合并为一个事件，避免 A 和 AAAA 同时更新时后一条被频率限制忽略
+	if len(msg) > 0 {
+		notifyErrs = append(notifyErrs, nc.Send(NotifyEvent{
+			Event:    EventRecordUpdated,
+			Provider: provider,
+			Message:  strings.Join(msg, "\n"),
+		})...)
+	}
+	if len(errs) > 0 {
+		rows := make([]string, 0, len(errs))
+		for _, err := range errs {
+			rows = append(rows, err.Error())
+		}
+		notifyErrs = append(notifyErrs, nc.Send(NotifyEvent{
+			Event:    EventUpdateFailed,
+			Provider: provider,
+			Message:  strings.Join(rows, "\n"),
+		})...)
+	}
+	if failedBefore && len(errs) == 0 {
+		notifyErrs = append(notifyErrs, nc.Send(NotifyEvent{
+			Event:    EventRecovered,
+			Provider: provider,
+			Message:  i18n.T(i18n.MsgProviderRecovered, provider),
+		})...)
+	}
+	return
+}
+
+func (nc notifyConf) isSubscribed(event string) bool {
+	for _, value := range nc.Events {
+		if value == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (nc notifyConf) notifiers() (n []notifier) {
+	if nc.Webhook.Enable {
+		n = append(n, nc.Webhook)
+	}
+	if nc.SMTP.Enable {
+		n = append(n, nc.SMTP)
+	}
+	if nc.Exec.Enable {
+		n = append(n, nc.Exec)
+	}
+	return
+}
+
+// templateFuncs 通知和自定义 HTTP 服务商模板中可用的函数
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		content, err := json.Marshal(v)
+		return string(content), err
+	},
+	"base64": func(str string) string {
+		return base64.StdEncoding.EncodeToString([]byte(str))
+	},
+}
+
+func renderNotifyTemplate(text string, event NotifyEvent) (dst string, err error) {
+	tmpl, err := template.New("notify").Funcs(templateFuncs).Parse(text)
+	if err != nil {
+		return
+	}
+	buf := bytes.Buffer{}
+	err = tmpl.Execute(&buf, event)
+	if err != nil {
+		return
+	}
+	return buf.String(), nil
+}
+
+func (event NotifyEvent) text() string {
+	lines := []string{
+		i18n.T(i18n.MsgNotifyEvent) + " " + event.Event,
+		i18n.T(i18n.MsgNotifyTime) + " " + event.Time,
+	}
+	if event.Provider != "" {
+		lines = append(lines, i18n.T(i18n.MsgNotifyProvider)+" "+event.Provider)
+	}
+	lines = append(lines, i18n.T(i18n.MsgNotifyMessage)+" "+event.Message)
+	if event.OldIPv4 != "" || event.IPv4 != "" {
+		lines = append(lines, "IPv4 "+event.OldIPv4+" -> "+event.IPv4)
+	}
+	if event.OldIPv6 != "" || event.IPv6 != "" {
+		lines = append(lines, "IPv6 "+event.OldIPv6+" -> "+event.IPv6)
+	}
+	if event.Suppressed > 0 {
+		lines = append(lines, i18n.T(i18n.MsgNotifySuppressed)+" "+strconv.Itoa(event.Suppressed))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func (wc webhookConf) Notify(event NotifyEvent) (err error) {
+	var body []byte
+	if wc.BodyTemplate == "" {
+		body, err = json.Marshal(event)
+	} else {
+		var rendered string
+		rendered, err = renderNotifyTemplate(wc.BodyTemplate, event)
+		body = []byte(rendered)
+	}
+	if err != nil {
+		return
+	}
+	method := wc.Method
+	if method == "" {
+		method = "POST"
+	}
+	httpClient := &http.Client{
+		Timeout:   10 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	req, err := http.NewRequest(method, wc.Url, bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ()")
+	for key, value := range wc.Headers {
+		req.Header.Set(key, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = i18n.NewError(i18n.MsgWebhookStatus, wc.Url, resp.Status)
+		return
+	}
+	return
+}
+
+func (sc smtpConf) Notify(event NotifyEvent) (err error) {
+	subject := "[" + RunningName + "] " + event.Event
+	if sc.SubjectTemplate != "" {
+		subject, err = renderNotifyTemplate(sc.SubjectTemplate, event)
+		if err != nil {
+			return
+		}
+	}
+	content := "From: " + sc.From + "\r\n" +
+		"To: " + strings.Join(sc.To, ", ") + "\r\n" +
+		"Subject: " + mime.QEncoding.Encode("utf-8", strings.TrimSpace(subject)) + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"Content-Transfer-Encoding: 8bit\r\n\r\n" +
+		strings.ReplaceAll(event.text(), "\n", "\r\n")
+	var auth smtp.Auth
+	if sc.Username != "" {
+		auth = smtp.PlainAuth("", sc.Username, sc.Password, sc.Host)
+	}
+	err = smtp.SendMail(net.JoinHostPort(sc.Host, strconv.Itoa(sc.Port)), auth, sc.From, sc.To, []byte(content))
+	if err != nil {
+		err = errors.New("SMTP: " + err.Error())
+	}
+	return
+}
+
+func (ec execConf) Notify(event NotifyEvent) (err error) {
+	stdin, err := json.Marshal(event)
+	if err != nil {
+		return
+	}
+	env := []string{
+		"DDNS_EVENT=" + event.Event,
+		"DDNS_PROVIDER=" + event.Provider,
+		"DDNS_MESSAGE=" + event.Message,
+		"DDNS_IPV4=" + event.IPv4,
+		"DDNS_IPV6=" + event.IPv6,
+		"DDNS_OLD_IPV4=" + event.OldIPv4,
+		"DDNS_OLD_IPV6=" + event.OldIPv6,
+		"DDNS_TIME=" + event.Time,
+	}
+	err = runCommand(ec.Command, time.Duration(ec.TimeoutSeconds)*time.Second, env, stdin)
+	if err != nil {
+		err = errors.New("Exec: " + err.Error())
+	}
+	return
+}
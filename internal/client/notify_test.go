@@ -0,0 +1,293 @@
+package client
+
+import (
+	"bufio"
+	"ddns-watchdog/internal/i18n"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/mail"
+	"os"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// webhookRecorder 本地 webhook 替身，记录收到的事件
+func webhookRecorder(t *testing.T) (*httptest.Server, func() []NotifyEvent) {
+	t.Helper()
+	var mutex sync.Mutex
+	var events []NotifyEvent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		var event NotifyEvent
+		if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+			t.Errorf("decode webhook body: %v", err)
+		}
+		mutex.Lock()
+		events = append(events, event)
+		mutex.Unlock()
+	}))
+	t.Cleanup(server.Close)
+	return server, func() []NotifyEvent {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]NotifyEvent(nil), events...)
+	}
+}
+
+func resetNotifyState(t *testing.T) {
+	t.Helper()
+	enabled := Conf.Enable.Notify
+	Conf.Enable.Notify = true
+	notifyMutex.Lock()
+	notifyLastSent = make(map[string]time.Time)
+	notifySuppressed = make(map[string]int)
+	providerFailed = make(map[string]bool)
+	notifyMutex.Unlock()
+	t.Cleanup(func() { Conf.Enable.Notify = enabled })
+}
+
+func TestReportResultAggregatesRun(t *testing.T) {
+	resetNotifyState(t)
+	server, received := webhookRecorder(t)
+	nc := notifyConf{
+		Events:           []string{EventRecordUpdated, EventUpdateFailed, EventRecovered},
+		RateLimitSeconds: 300,
+		Webhook:          webhookConf{Enable: true, Url: server.URL},
+	}
+
+	// 同一次运行中 A 和 AAAA 都更新时，两条结果都要送达
+	errs := nc.ReportResult("DNSPod", []string{"A updated", "AAAA updated"}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("ReportResult() errors = %v", errs)
+	}
+	events := received()
+	if len(events) != 1 {
+		t.Fatalf("got %d events, want 1 aggregated event", len(events))
+	}
+	if events[0].Event != EventRecordUpdated || events[0].Message != "A updated\nAAAA updated" {
+		t.Errorf("event = %+v", events[0])
+	}
+
+	nc.ReportResult("DNSPod", nil, []error{errors.New("A failed"), errors.New("AAAA failed")})
+	events = received()
+	if len(events) != 2 || events[1].Event != EventUpdateFailed || !strings.Contains(events[1].Message, "AAAA failed") {
+		t.Fatalf("events = %+v", events)
+	}
+
+	// 频率限制内的下一次运行被忽略，恢复事件仍然发送
+	nc.ReportResult("DNSPod", []string{"A updated again"}, nil)
+	events = received()
+	if len(events) != 3 || events[2].Event != EventRecovered {
+		t.Fatalf("events = %+v", events)
+	}
+	notifyMutex.Lock()
+	suppressed := notifySuppressed[EventRecordUpdated+"/DNSPod"]
+	notifyMutex.Unlock()
+	if suppressed != 1 {
+		t.Errorf("suppressed = %d, want 1", suppressed)
+	}
+}
+
+// smtpMessage SMTP 替身收到的邮件
+type smtpMessage struct {
+	auth string
+	from string
+	to   []string
+	data string
+}
+
+// smtpRecorder 只实现 EHLO、AUTH PLAIN、MAIL、RCPT、DATA 和 QUIT 的本地 SMTP 服务器
+// rejectRcpt 中的收件人返回 550
+func smtpRecorder(t *testing.T, rejectRcpt string) (host string, port int, received func() []smtpMessage) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = listener.Close() })
+	var mutex sync.Mutex
+	var messages []smtpMessage
+	go func() {
+		for {
+			conn, acceptErr := listener.Accept()
+			if acceptErr != nil {
+				return
+			}
+			message := smtpSession(conn, rejectRcpt)
+			mutex.Lock()
+			messages = append(messages, message)
+			mutex.Unlock()
+		}
+	}()
+	addr := listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port, func() []smtpMessage {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return append([]smtpMessage(nil), messages...)
+	}
+}
+
+func smtpSession(conn net.Conn, rejectRcpt string) (message smtpMessage) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(5 * time.Second))
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+	reply("220 localhost ESMTP")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+		verb := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch verb {
+		case "EHLO", "HELO":
+			reply("250-localhost")
+			reply("250 AUTH PLAIN")
+		case "AUTH":
+			decoded, _ := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, "AUTH PLAIN "))
+			message.auth = string(decoded)
+			reply("235 2.7.0 Authentication successful")
+		case "MAIL":
+			message.from = strings.Trim(strings.TrimPrefix(line, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case "RCPT":
+			rcpt := strings.Trim(strings.TrimPrefix(line, "RCPT TO:"), "<>")
+			if rcpt == rejectRcpt {
+				reply("550 5.1.1 No such user")
+				continue
+			}
+			message.to = append(message.to, rcpt)
+			reply("250 OK")
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				dataLine, dataErr := reader.ReadString('\n')
+				if dataErr != nil || dataLine == ".\r\n" {
+					break
+				}
+				data.WriteString(dataLine)
+			}
+			message.data = data.String()
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPNotify(t *testing.T) {
+	resetNotifyState(t)
+	host, port, received := smtpRecorder(t, "")
+	nc := notifyConf{
+		Events: []string{EventIPChanged},
+		SMTP: smtpConf{
+			Enable:          true,
+			Host:            host,
+			Port:            port,
+			Username:        "user",
+			Password:        "pass",
+			From:            "ddns@example.com",
+			To:              []string{"admin@example.com", "ops@example.com"},
+			SubjectTemplate: "IP 变化 {{.IPv4}}",
+		},
+	}
+	errs := nc.Send(NotifyEvent{Event: EventIPChanged, Message: "changed", OldIPv4: "192.0.2.1", IPv4: "192.0.2.2"})
+	if len(errs) != 0 {
+		t.Fatalf("Send() errors = %v", errs)
+	}
+	messages := received()
+	if len(messages) != 1 {
+		t.Fatalf("got %d messages, want 1", len(messages))
+	}
+	message := messages[0]
+	if message.auth != "\x00user\x00pass" || message.from != "ddns@example.com" ||
+		!reflect.DeepEqual(message.to, []string{"admin@example.com", "ops@example.com"}) {
+		t.Errorf("envelope = %+v", message)
+	}
+	parsed, err := mail.ReadMessage(strings.NewReader(message.data))
+	if err != nil {
+		t.Fatalf("parse message: %v", err)
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(parsed.Header.Get("Subject"))
+	if err != nil || subject != "IP 变化 192.0.2.2" {
+		t.Errorf("Subject = %q, %v", subject, err)
+	}
+	body, _ := io.ReadAll(parsed.Body)
+	if !strings.Contains(string(body), "IPv4 192.0.2.1 -> 192.0.2.2\r\n") {
+		t.Errorf("body = %q", body)
+	}
+
+	// 收件人被拒绝时返回错误
+	resetNotifyState(t)
+	host, port, _ = smtpRecorder(t, "ops@example.com")
+	nc.SMTP.Host, nc.SMTP.Port = host, port
+	errs = nc.Send(NotifyEvent{Event: EventIPChanged, Message: "changed"})
+	if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), "SMTP: 550") {
+		t.Errorf("Send() errors = %v, want SMTP 550", errs)
+	}
+}
+
+func TestExecNotify(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sh")
+	}
+	resetNotifyState(t)
+	out := t.TempDir() + "/event"
+	nc := notifyConf{
+		Events: []string{EventUpdateFailed},
+		Exec: execConf{
+			Enable:  true,
+			Command: []string{"sh", "-c", `cat > "$0" && printf '%s|%s' "$DDNS_EVENT" "$DDNS_PROVIDER" > "$0.env"`, out},
+		},
+	}
+	errs := nc.Send(NotifyEvent{Event: EventUpdateFailed, Provider: "DNSPod", Message: "failed"})
+	if len(errs) != 0 {
+		t.Fatalf("Send() errors = %v", errs)
+	}
+	// 事件同时通过标准输入和环境变量传给命令
+	stdin, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var event NotifyEvent
+	if err = json.Unmarshal(stdin, &event); err != nil || event.Message != "failed" || event.Time == "" {
+		t.Errorf("stdin event = %+v, %v", event, err)
+	}
+	if env, _ := os.ReadFile(out + ".env"); string(env) != EventUpdateFailed+"|DNSPod" {
+		t.Errorf("env = %q", env)
+	}
+
+	// 命令失败和超时作为通知错误返回
+	tests := []struct {
+		name    string
+		command []string
+		want    string
+	}{
+		{"exit status", []string{"sh", "-c", "echo broken >&2; exit 3"}, "Exec: sh: exit status 3 broken"},
+		{"timeout", []string{"sleep", "5"}, "Exec: " + i18n.T(i18n.MsgCommandTimeout, "sleep", time.Second)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetNotifyState(t)
+			nc.Exec = execConf{Enable: true, Command: tt.command, TimeoutSeconds: 1}
+			errs := nc.Send(NotifyEvent{Event: EventUpdateFailed, Provider: tt.name})
+			if len(errs) != 1 || !strings.HasPrefix(errs[0].Error(), tt.want) {
+				t.Errorf("Send() errors = %v, want %q", errs, tt.want)
+			}
+		})
+	}
+}
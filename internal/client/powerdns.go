@@ -0,0 +1,284 @@
+package client
+
+import (
+	"bytes"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const PowerDNSConfFileName = "powerdns.json"
+
+// powerDNSConf domain 和 records 可以同时使用，domain 相当于 ttl 和 static 为空的 records
+type powerDNSConf struct {
+	APIUrl   string           `json:"api_url"`
+	APIKey   string           `json:"api_key"`
+	ServerId string           `json:"server_id"`
+	Zone     string           `json:"zone"`
+	Domain   subdomain        `json:"domain"`
+	Records  []powerDNSTarget `json:"records"`
+	TTL      int              `json:"ttl"`
+}
+
+// powerDNSTarget 一个由本程序管理的记录集，type 为 A 或 AAAA，ttl 为 0 时使用全局的 ttl
+// static 为与本机 IP 一起保留在记录集中的固定值，记录集中其他启用的值会被替换，已禁用的记录保持不变
+type powerDNSTarget struct {
+	Name   string   `json:"name"`
+	Type   string   `json:"type"`
+	TTL    int      `json:"ttl"`
+	Static []string `json:"static"`
+}
+
+type powerDNSRecord struct {
+	Content  string `json:"content"`
+	Disabled bool   `json:"disabled"`
+}
+
+type powerDNSRRSet struct {
+	Name       string           `json:"name"`
+	Type       string           `json:"type"`
+	TTL        int              `json:"ttl,omitempty"`
+	ChangeType string           `json:"changetype,omitempty"`
+	Records    []powerDNSRecord `json:"records"`
+}
+
+func (pdc *powerDNSConf) InitConf() (msg string, err error) {
+	*pdc = powerDNSConf{}
+	pdc.APIUrl = "http://127.0.0.1:8081"
+	pdc.APIKey = i18n.T(i18n.MsgPlaceholderPowerDNSKey)
+	pdc.ServerId = "localhost"
+	pdc.Zone = "example.com"
+	pdc.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	pdc.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	pdc.Records = []powerDNSTarget{}
+	pdc.TTL = 60
+	err = common.MarshalAndSave(pdc, ConfDirectoryName+"/"+PowerDNSConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+PowerDNSConfFileName)
+	return
+}
+
+func (pdc *powerDNSConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+PowerDNSConfFileName, &pdc)
+	if err != nil {
+		return
+	}
+	if pdc.APIUrl == "" || pdc.APIKey == "" || pdc.Zone == "" || (pdc.Domain.A == "" && pdc.Domain.AAAA == "" && len(pdc.Records) == 0) {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+PowerDNSConfFileName, "api_url, api_key, zone, domain")
+		return
+	}
+	for i, target := range pdc.Records {
+		pdc.Records[i].Type = strings.ToUpper(target.Type)
+		if target.Name == "" || (pdc.Records[i].Type != "A" && pdc.Records[i].Type != "AAAA") {
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+PowerDNSConfFileName, "records.name, records.type")
+			return
+		}
+	}
+	pdc.APIUrl = strings.TrimSuffix(pdc.APIUrl, "/")
+	// 兼容填写到 /api/v1 的地址
+	pdc.APIUrl = strings.TrimSuffix(pdc.APIUrl, "/api/v1")
+	if pdc.ServerId == "" {
+		pdc.ServerId = "localhost"
+	}
+	if pdc.TTL <= 0 {
+		pdc.TTL = 60
+	}
+	return
+}
+
+func (pdc powerDNSConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	for _, target := range pdc.targets() {
+		if (target.Type == "A" && !enabled.IPv4) || (target.Type == "AAAA" && !enabled.IPv6) {
+			continue
+		}
+		ipAddr := ipv4
+		if target.Type == "AAAA" {
+			ipAddr = ipv6
+		}
+		msgRow, err := pdc.update(target, ipAddr)
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// targets 合并 domain 和 records
+func (pdc powerDNSConf) targets() (targets []powerDNSTarget) {
+	if pdc.Domain.A != "" {
+		targets = append(targets, powerDNSTarget{Name: pdc.Domain.A, Type: "A"})
+	}
+	if pdc.Domain.AAAA != "" {
+		targets = append(targets, powerDNSTarget{Name: pdc.Domain.AAAA, Type: "AAAA"})
+	}
+	return append(targets, pdc.Records...)
+}
+
+// update 每个记录集分别保存查询到的内容，用于替换时保留已禁用的记录
+func (pdc powerDNSConf) update(target powerDNSTarget, ipAddr string) (msg string, err error) {
+	var current powerDNSRRSet
+	return updateRecord("PowerDNS", target.Name, target.Type, ipAddr,
+		func() (recordIP string, err error) {
+			current, err = pdc.getRRSet(target.Name, target.Type)
+			if err != nil {
+				return
+			}
+			return target.dynamicValue(current), nil
+		},
+		func() error { return pdc.updateParseRecord(target, ipAddr, current) })
+}
+
+// zoneUrl 区域 ID 为以点结尾的区域名称
+func (pdc powerDNSConf) zoneUrl() string {
+	return pdc.APIUrl + "/api/v1/servers/" + url.PathEscape(pdc.ServerId) +
+		"/zones/" + url.PathEscape(strings.TrimSuffix(pdc.Zone, ".")+".")
+}
+
+// getRRSet 记录集不存在时返回空的记录集，REPLACE 会新建
+func (pdc powerDNSConf) getRRSet(domain, recordType string) (result powerDNSRRSet, err error) {
+	name := strings.ToLower(strings.TrimSuffix(domain, ".") + ".")
+	query := url.Values{}
+	// 4.8 之前的版本会忽略这两个参数，返回整个区域
+	query.Set("rrset_name", name)
+	query.Set("rrset_type", recordType)
+	var zone struct {
+		RRSets []powerDNSRRSet `json:"rrsets"`
+	}
+	err = pdc.call("GET", pdc.zoneUrl()+"?"+query.Encode(), nil, &zone)
+	if err != nil {
+		return
+	}
+	for _, rrset := range zone.RRSets {
+		if strings.ToLower(rrset.Name) == name && rrset.Type == recordType {
+			return rrset, nil
+		}
+	}
+	return
+}
+
+// dynamicValue 去掉 static 后剩余的启用的值，用逗号连接以便与新 IP 比较
+// 缺少任意一个 static 时返回全部的值，使比较不相等，下次更新时补上
+func (target powerDNSTarget) dynamicValue(rrset powerDNSRRSet) string {
+	static := make(map[string]bool)
+	for _, value := range target.Static {
+		static[target.normalize(value)] = true
+	}
+	var all, dynamic []string
+	found := make(map[string]bool)
+	for _, record := range rrset.Records {
+		if record.Disabled {
+			continue
+		}
+		value := target.normalize(record.Content)
+		all = append(all, value)
+		if static[value] {
+			found[value] = true
+		} else {
+			dynamic = append(dynamic, value)
+		}
+	}
+	if len(found) < len(static) {
+		dynamic = all
+	}
+	sort.Strings(dynamic)
+	return strings.Join(dynamic, ",")
+}
+
+// normalize AAAA 的值展开后再比较
+func (target powerDNSTarget) normalize(value string) string {
+	if target.Type == "AAAA" {
+		return common.DecodeIPv6(value)
+	}
+	return value
+}
+
+// updateParseRecord 用 REPLACE 将记录集替换为新 IP 和 static，保留已禁用的记录
+func (pdc powerDNSConf) updateParseRecord(target powerDNSTarget, ipAddr string, current powerDNSRRSet) (err error) {
+	records := []powerDNSRecord{{Content: ipAddr}}
+	seen := map[string]bool{target.normalize(ipAddr): true}
+	for _, value := range target.Static {
+		if !seen[target.normalize(value)] {
+			seen[target.normalize(value)] = true
+			records = append(records, powerDNSRecord{Content: value})
+		}
+	}
+	for _, record := range current.Records {
+		if record.Disabled && !seen[target.normalize(record.Content)] {
+			records = append(records, record)
+		}
+	}
+	ttl := target.TTL
+	if ttl <= 0 {
+		ttl = pdc.TTL
+	}
+	reqData := struct {
+		RRSets []powerDNSRRSet `json:"rrsets"`
+	}{
+		RRSets: []powerDNSRRSet{{
+			Name:       strings.TrimSuffix(target.Name, ".") + ".",
+			Type:       target.Type,
+			TTL:        ttl,
+			ChangeType: "REPLACE",
+			Records:    records,
+		}},
+	}
+	reqJson, err := json.Marshal(reqData)
+	if err != nil {
+		return
+	}
+	err = pdc.call("PATCH", pdc.zoneUrl(), reqJson, nil)
+	return
+}
+
+// call 响应不是 2xx 时返回 PowerDNS 的 error 字段
+func (pdc powerDNSConf) call(method, rawUrl string, payload []byte, result any) (err error) {
+	req, err := http.NewRequest(method, rawUrl, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	req.Header.Set("X-API-Key", pdc.APIKey)
+	req.Header.Set("Accept", "application/json")
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ("+common.ProjectUrl+")")
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(resp.Body)
+	recvJson, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var respErr struct {
+			Error string `json:"error"`
+		}
+		if json.Unmarshal(recvJson, &respErr) != nil || respErr.Error == "" {
+			respErr.Error = strings.TrimSpace(string(recvJson))
+		}
+		err = i18n.NewError(i18n.MsgPowerDNSAPI, resp.Status, respErr.Error)
+		return
+	}
+	if result != nil && len(recvJson) > 0 {
+		err = json.Unmarshal(recvJson, result)
+	}
+	return
+}
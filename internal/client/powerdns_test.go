@@ -0,0 +1,158 @@
+package client
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// newPowerDNSStub 内存中的 PowerDNS 区域，只实现按名称和类型查询记录集以及 REPLACE
+// rrsets 以 "名称/类型" 为键，只能在 stub.inspect 中访问
+func newPowerDNSStub(t *testing.T, initial ...powerDNSRRSet) (stub *apiStub, rrsets map[string]powerDNSRRSet, apiUrl string) {
+	t.Helper()
+	rrsets = make(map[string]powerDNSRRSet)
+	for _, rrset := range initial {
+		rrsets[rrset.Name+"/"+rrset.Type] = rrset
+	}
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		if req.Header.Get("X-API-Key") != "secret" {
+			return stubResponse{Status: 401, Body: `{"error": "Unauthorized"}`}
+		}
+		if req.URL.Path != "/api/v1/servers/localhost/zones/example.com." {
+			return stubResponse{Status: 404, Body: `{"error": "Could not find domain"}`}
+		}
+		var zone struct {
+			RRSets []powerDNSRRSet `json:"rrsets"`
+		}
+		switch req.Method {
+		case "GET":
+			if rrset, ok := rrsets[req.Form.Get("rrset_name")+"/"+req.Form.Get("rrset_type")]; ok {
+				zone.RRSets = append(zone.RRSets, rrset)
+			}
+			return stubResponse{Body: zone}
+		case "PATCH":
+			if err := json.Unmarshal(req.Body, &zone); err != nil {
+				t.Errorf("decode patch: %v", err)
+			}
+			for _, rrset := range zone.RRSets {
+				if rrset.ChangeType != "REPLACE" {
+					t.Errorf("changetype = %q, want REPLACE", rrset.ChangeType)
+				}
+				rrset.ChangeType = ""
+				rrsets[rrset.Name+"/"+rrset.Type] = rrset
+			}
+			return stubResponse{Status: 204}
+		}
+		return stubResponse{Status: 405}
+	})
+	return stub, rrsets, server.URL
+}
+
+func contents(rrset powerDNSRRSet) (values []string) {
+	for _, record := range rrset.Records {
+		value := record.Content
+		if record.Disabled {
+			value += " (disabled)"
+		}
+		values = append(values, value)
+	}
+	sort.Strings(values)
+	return
+}
+
+func TestPowerDNSMultipleRecords(t *testing.T) {
+	stub, rrsets, apiUrl := newPowerDNSStub(t,
+		powerDNSRRSet{Name: "home.example.com.", Type: "A", TTL: 60, Records: []powerDNSRecord{{Content: "192.0.2.1"}}},
+		powerDNSRRSet{Name: "www.example.com.", Type: "A", TTL: 300, Records: []powerDNSRecord{
+			{Content: "192.0.2.1"},
+			{Content: "198.51.100.10"},
+			{Content: "203.0.113.99", Disabled: true},
+		}},
+		powerDNSRRSet{Name: "home.example.com.", Type: "AAAA", TTL: 60, Records: []powerDNSRecord{{Content: "2001:db8::1"}}},
+	)
+	pdc := powerDNSConf{
+		APIUrl:   apiUrl,
+		APIKey:   "secret",
+		ServerId: "localhost",
+		Zone:     "example.com",
+		Domain:   subdomain{A: "home.example.com"},
+		Records: []powerDNSTarget{
+			{Name: "www.example.com", Type: "A", TTL: 300, Static: []string{"198.51.100.10"}},
+			{Name: "vpn.example.com", Type: "A"},
+			{Name: "home.example.com", Type: "AAAA"},
+		},
+		TTL: 60,
+	}
+
+	msg, errs := pdc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:1")
+	if len(errs) != 0 {
+		t.Fatalf("Run() errors = %v", errs)
+	}
+	// home A、www A 和新建的 vpn A 需要更新，home AAAA 展开后与本机 IP 相同
+	patches := func() int { return len(stub.received("PATCH", "/api/v1/servers/localhost/zones/example.com.")) }
+	if len(msg) != 3 || patches() != 3 {
+		t.Fatalf("Run() msg = %v, patches = %d", msg, patches())
+	}
+	tests := []struct {
+		key  string
+		want []string
+		ttl  int
+	}{
+		{"home.example.com./A", []string{"192.0.2.2"}, 60},
+		// static 和已禁用的记录保留，旧的动态 IP 被替换
+		{"www.example.com./A", []string{"192.0.2.2", "198.51.100.10", "203.0.113.99 (disabled)"}, 300},
+		{"vpn.example.com./A", []string{"192.0.2.2"}, 60},
+		{"home.example.com./AAAA", []string{"2001:db8::1"}, 60},
+	}
+	stub.inspect(func() {
+		for _, tt := range tests {
+			rrset := rrsets[tt.key]
+			if got := contents(rrset); !reflect.DeepEqual(got, tt.want) || rrset.TTL != tt.ttl {
+				t.Errorf("%s = %v ttl %d, want %v ttl %d", tt.key, got, rrset.TTL, tt.want, tt.ttl)
+			}
+		}
+	})
+
+	// 再次运行时记录集已经是本机 IP 加 static，不需要更新
+	msg, errs = pdc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:1")
+	if len(msg) != 0 || len(errs) != 0 || patches() != 3 {
+		t.Fatalf("second Run() msg = %v, errs = %v, patches = %d", msg, errs, patches())
+	}
+}
+
+func TestPowerDNSDynamicValue(t *testing.T) {
+	target := powerDNSTarget{Name: "www.example.com", Type: "AAAA", Static: []string{"2001:db8::10"}}
+	tests := []struct {
+		name    string
+		records []powerDNSRecord
+		want    string
+	}{
+		{"empty", nil, ""},
+		{"static removed", []powerDNSRecord{{Content: "2001:db8::10"}, {Content: "2001:db8::2"}}, "2001:db8:0:0:0:0:0:2"},
+		{"disabled ignored", []powerDNSRecord{{Content: "2001:db8::10"}, {Content: "2001:db8::3", Disabled: true}}, ""},
+		// 缺少 static 时返回全部的值，保证与本机 IP 不相等
+		{"static missing", []powerDNSRecord{{Content: "2001:db8::2"}}, "2001:db8:0:0:0:0:0:2"},
+		{"several dynamic", []powerDNSRecord{{Content: "2001:db8::10"}, {Content: "2001:db8::5"}, {Content: "2001:db8::4"}},
+			"2001:db8:0:0:0:0:0:4,2001:db8:0:0:0:0:0:5"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := target.dynamicValue(powerDNSRRSet{Records: tt.records})
+			if got != tt.want {
+				t.Errorf("dynamicValue() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPowerDNSAPIError(t *testing.T) {
+	_, _, apiUrl := newPowerDNSStub(t)
+	pdc := powerDNSConf{APIUrl: apiUrl, APIKey: "wrong", ServerId: "localhost", Zone: "example.com", TTL: 60}
+	_, errs := pdc.Run(enable{IPv4: true}, "192.0.2.2", "")
+	if len(errs) != 0 {
+		t.Fatalf("Run() without targets errors = %v", errs)
+	}
+	pdc.Domain.A = "home.example.com"
+	checkRunError(t, pdc.Run, "Unauthorized")
+}
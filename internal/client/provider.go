@@ -0,0 +1,131 @@
+package client
+
+import (
+	"ddns-watchdog/internal/i18n"
+	"strings"
+)
+
+// 服务商名称，与 client.json 中 services 的字段名一致，服务端 DDNS 的 hosts 中使用
+const (
+	ProviderDNSPod       = "dnspod"
+	ProviderAliDNS       = "alidns"
+	ProviderCloudflare   = "cloudflare"
+	ProviderRFC2136      = "rfc2136"
+	ProviderDyndns2      = "dyndns2"
+	ProviderTencentCloud = "tencentcloud"
+	ProviderRoute53      = "route53"
+	ProviderCustomHTTP   = "custom_http"
+	ProviderLocalFile    = "local_file"
+	ProviderPowerDNS     = "powerdns"
+	ProviderHuaweiCloud  = "huaweicloud"
+)
+
+// LoadProvider 从 ConfDirectoryName 加载服务商的配置文件，供服务端 DDNS 使用
+func LoadProvider(provider string) (err error) {
+	switch provider {
+	case ProviderDNSPod:
+		return Dpc.LoadConf()
+	case ProviderAliDNS:
+		return Adc.LoadConf()
+	case ProviderCloudflare:
+		return Cfc.LoadConf()
+	case ProviderRFC2136:
+		return Rfc.LoadConf()
+	case ProviderDyndns2:
+		return Ddc.LoadConf()
+	case ProviderTencentCloud:
+		return Tcc.LoadConf()
+	case ProviderRoute53:
+		return R5c.LoadConf()
+	case ProviderCustomHTTP:
+		return Chc.LoadConf()
+	case ProviderLocalFile:
+		return Lfc.LoadConf()
+	case ProviderPowerDNS:
+		return Pdc.LoadConf()
+	case ProviderHuaweiCloud:
+		return Hwc.LoadConf()
+	}
+	return i18n.NewError(i18n.MsgProviderUnknown, provider)
+}
+
+// UpdateHost 使用已加载的服务商配置，将完整域名 name 的 A 或 AAAA 记录更新为 ip
+// 配置文件中的域名被替换为 name，其余设置 (区域、TTL 等) 保持不变
+// 记录无需更新时 msg 为空
+func UpdateHost(provider, name, recordType, ip string) (msg string, err error) {
+	enabled := enable{IPv4: recordType == "A", IPv6: recordType == "AAAA"}
+	domain := subdomain{A: name, AAAA: name}
+	var run AsyncServiceCallback
+	switch provider {
+	case ProviderDNSPod:
+		conf := Dpc
+		conf.SubDomain, err = splitSubDomain(name, conf.Domain)
+		run = conf.Run
+	case ProviderAliDNS:
+		conf := Adc
+		conf.SubDomain, err = splitSubDomain(name, conf.Domain)
+		run = conf.Run
+	case ProviderTencentCloud:
+		conf := Tcc
+		conf.SubDomain, err = splitSubDomain(name, conf.Domain)
+		run = conf.Run
+	case ProviderCloudflare:
+		conf := Cfc
+		conf.Domain = domain
+		run = conf.Run
+	case ProviderRFC2136:
+		conf := Rfc
+		conf.Domain = domain
+		run = conf.Run
+	case ProviderDyndns2:
+		conf := Ddc
+		conf.Hostname = domain
+		run = conf.Run
+	case ProviderRoute53:
+		conf := R5c
+		conf.Domain = domain
+		run = conf.Run
+	case ProviderCustomHTTP:
+		conf := Chc
+		conf.Domain = domain
+		run = conf.Run
+	case ProviderLocalFile:
+		conf := Lfc
+		conf.Domain = domain
+		run = conf.Run
+	case ProviderPowerDNS:
+		conf := Pdc
+		conf.Domain = domain
+		run = conf.Run
+	case ProviderHuaweiCloud:
+		conf := Hwc
+		conf.Domain = domain
+		run = conf.Run
+	default:
+		err = i18n.NewError(i18n.MsgProviderUnknown, provider)
+	}
+	if err != nil {
+		return
+	}
+	msgs, errs := run(enabled, ip, ip)
+	if len(errs) > 0 {
+		return "", errs[0]
+	}
+	return strings.Join(msgs, "\n"), nil
+}
+
+// splitSubDomain 将完整域名拆分为 zone 下的主机记录，zone 本身为 @
+func splitSubDomain(name, zone string) (sub subdomain, err error) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	zone = strings.ToLower(strings.TrimSuffix(zone, "."))
+	switch {
+	case name == zone:
+		sub.A = "@"
+	case strings.HasSuffix(name, "."+zone):
+		sub.A = strings.TrimSuffix(name, "."+zone)
+	default:
+		return sub, i18n.NewError(i18n.MsgHostNotInZone, name, zone)
+	}
+	sub.AAAA = sub.A
+	return
+}
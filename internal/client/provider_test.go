@@ -0,0 +1,114 @@
+package client
+
+import (
+	"ddns-watchdog/internal/i18n"
+	"errors"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitSubDomain(t *testing.T) {
+	tests := []struct {
+		name    string
+		zone    string
+		want    string
+		wantErr bool
+	}{
+		{"home.example.com", "example.com", "home", false},
+		{"a.b.example.com", "example.com", "a.b", false},
+		{"Home.Example.COM.", "example.com.", "home", false},
+		{"example.com", "example.com", "@", false},
+		{"EXAMPLE.com.", "example.com", "@", false},
+		{"home.example.net", "example.com", "", true},
+		// 只有后缀相同，不属于 example.com
+		{"badexample.com", "example.com", "", true},
+		{"com", "example.com", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name+"/"+tt.zone, func(t *testing.T) {
+			sub, err := splitSubDomain(tt.name, tt.zone)
+			if tt.wantErr {
+				var i18nErr *i18n.Error
+				if !errors.As(err, &i18nErr) || i18nErr.ID != i18n.MsgHostNotInZone {
+					t.Fatalf("splitSubDomain() = %v, %v, want MsgHostNotInZone", sub, err)
+				}
+				return
+			}
+			if err != nil || sub.A != tt.want || sub.AAAA != tt.want {
+				t.Errorf("splitSubDomain() = %v, %v, want %q", sub, err, tt.want)
+			}
+		})
+	}
+}
+
+// TestUpdateHostSubDomain domain 加 sub_domain 的服务商由 UpdateHost 拆分出主机记录
+func TestUpdateHostSubDomain(t *testing.T) {
+	stub := newDNSPodStub(t)
+	previous := Dpc
+	Dpc = dnspodConf{Id: "1", Token: "secret", Domain: "example.com", SubDomain: subdomain{A: "configured", AAAA: "configured"}}
+	t.Cleanup(func() { Dpc = previous })
+
+	msg, err := UpdateHost(ProviderDNSPod, "Home.example.com", "A", "192.0.2.2")
+	if err != nil || msg == "" {
+		t.Fatalf("UpdateHost() = %q, %v", msg, err)
+	}
+	// 只更新 A 记录，查询和更新都使用拆分出的主机记录
+	lists := stub.received("POST", "/Record.List")
+	if len(lists) != 1 || lists[0].Form.Get("sub_domain") != "home" || lists[0].Form.Get("domain") != "example.com" {
+		t.Fatalf("Record.List requests = %v", lists)
+	}
+	modifies := stub.received("POST", "/Record.Modify")
+	if len(modifies) != 1 || modifies[0].Form.Get("record_id") != "id-a" || modifies[0].Form.Get("value") != "192.0.2.2" {
+		t.Fatalf("Record.Modify requests = %v", modifies)
+	}
+	// 配置中的 sub_domain 保持不变
+	if Dpc.SubDomain.A != "configured" {
+		t.Errorf("Dpc.SubDomain = %v", Dpc.SubDomain)
+	}
+
+	_, err = UpdateHost(ProviderDNSPod, "home.example.net", "A", "192.0.2.2")
+	var i18nErr *i18n.Error
+	if !errors.As(err, &i18nErr) || i18nErr.ID != i18n.MsgHostNotInZone {
+		t.Fatalf("UpdateHost() out of zone error = %v", err)
+	}
+	if lists = stub.received("POST", "/Record.List"); len(lists) != 1 {
+		t.Errorf("Record.List requests after an out-of-zone name = %d", len(lists))
+	}
+}
+
+// TestUpdateHostFullName 使用完整域名的服务商直接替换配置中的域名
+func TestUpdateHostFullName(t *testing.T) {
+	ConfDirectoryName = t.TempDir()
+	previous := Lfc
+	Lfc = localFileConf{
+		Domain:  subdomain{A: "configured.example.com", AAAA: "configured.example.com"},
+		TTL:     300,
+		Targets: []localFileTarget{{Format: LocalFileHosts, Path: filepath.Join(ConfDirectoryName, "hosts")}},
+	}
+	t.Cleanup(func() { Lfc = previous })
+
+	msg, err := UpdateHost(ProviderLocalFile, "nas.example.com", "AAAA", "2001:db8:0:0:0:0:0:2")
+	if err != nil || msg == "" {
+		t.Fatalf("UpdateHost() = %q, %v", msg, err)
+	}
+	for _, check := range []struct{ domain, recordType, want string }{
+		{"nas.example.com", "AAAA", "2001:db8:0:0:0:0:0:2"},
+		{"nas.example.com", "A", ""},
+		{"configured.example.com", "AAAA", ""},
+	} {
+		if got, err := Lfc.getParseRecord(check.domain, check.recordType); err != nil || got != check.want {
+			t.Errorf("%s %s = %q, %v, want %q", check.domain, check.recordType, got, err, check.want)
+		}
+	}
+	// 记录无需更新时 msg 为空
+	msg, err = UpdateHost(ProviderLocalFile, "nas.example.com", "AAAA", "2001:db8:0:0:0:0:0:2")
+	if err != nil || msg != "" {
+		t.Errorf("UpdateHost() unchanged = %q, %v", msg, err)
+	}
+
+	_, err = UpdateHost("unknown", "nas.example.com", "A", "192.0.2.2")
+	var i18nErr *i18n.Error
+	if !errors.As(err, &i18nErr) || i18nErr.ID != i18n.MsgProviderUnknown {
+		t.Errorf("UpdateHost() unknown provider error = %v", err)
+	}
+}
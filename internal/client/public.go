@@ -2,15 +2,16 @@ package client
 
 import (
 	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
 	"encoding/json"
-	"errors"
 	"io"
-	"log"
 	"net"
 	"net/http"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 )
 
 var (
@@ -20,6 +21,14 @@ var (
 	Dpc               = dnspodConf{}
 	Adc               = aliDNSConf{}
 	Cfc               = cloudflareConf{}
+	Rfc               = rfc2136Conf{}
+	Ddc               = dyndns2Conf{}
+	Tcc               = tencentCloudConf{}
+	R5c               = route53Conf{}
+	Chc               = customHTTPConf{}
+	Lfc               = localFileConf{}
+	Pdc               = powerDNSConf{}
+	Hwc               = huaweiCloudConf{}
 )
 
 type subdomain struct {
@@ -30,13 +39,96 @@ type subdomain struct {
 // AsyncServiceCallback 异步服务回调函数类型
 type AsyncServiceCallback func(enabledServices enable, ipv4, ipv6 string) (msg []string, errs []error)
 
+// updateRecord 获取解析记录，与 ip 不一致时执行 pre_update 钩子、更新解析记录并执行 post_update 钩子
+// 供各服务商的 Run 使用，record 为完整域名
+func updateRecord(provider, record, recordType, ip string, getParseRecord func() (string, error), updateParseRecord func() error) (msg string, err error) {
+	start := time.Now()
+	family := "ipv4"
+	if recordType == "AAAA" {
+		family = "ipv6"
+	}
+	entry := HistoryEntry{
+		Event:      HistoryUpdate,
+		Family:     family,
+		Provider:   provider,
+		Record:     record,
+		RecordType: recordType,
+		NewIP:      ip,
+	}
+	defer func() {
+		if entry.Result == "" {
+			return
+		}
+		if historyErr := appendHistory(entry); historyErr != nil {
+			logger.Warn(historyErr.Error(), "provider", provider, "record", record)
+		}
+	}()
+	// 获取解析记录
+	recordIP, err := getParseRecord()
+	if err != nil {
+		entry.Result = HistoryResultFailed
+		entry.Error = err.Error()
+		logger.Error(err.Error(), "provider", provider, "record", record, "family", family, "duration", time.Since(start))
+		return
+	}
+	// 服务商返回的 IPv6 可能是压缩格式，展开后再与本机获取的 IP 比较
+	if recordType == "AAAA" && recordIP != "" {
+		values := strings.Split(recordIP, ",")
+		for i := range values {
+			values[i] = common.DecodeIPv6(values[i])
+		}
+		recordIP = strings.Join(values, ",")
+	}
+	entry.OldIP = recordIP
+	if recordIP == ip {
+		entry.Result = HistoryResultUnchanged
+		logger.Debug(i18n.T(i18n.MsgRecordUnchanged), "provider", provider, "record", record, "family", family, "ip", ip)
+		return
+	}
+	event := HookEvent{
+		Hook:       HookPreUpdate,
+		Provider:   provider,
+		Record:     record,
+		RecordType: recordType,
+		OldIP:      recordIP,
+		NewIP:      ip,
+	}
+	err = Hc.Run(event)
+	if err != nil {
+		err = i18n.NewError(i18n.MsgUpdateAborted, provider, record, err)
+		entry.Result = HistoryResultAborted
+		entry.Error = err.Error()
+		logger.Warn(err.Error(), "provider", provider, "record", record, "family", family, "old_ip", recordIP, "new_ip", ip)
+		return
+	}
+	// 更新解析记录
+	err = updateParseRecord()
+	event.Hook = HookPostUpdate
+	if err != nil {
+		event.Result = HookResultFailed
+		event.Error = err.Error()
+		entry.Result = HistoryResultFailed
+		entry.Error = err.Error()
+		logger.Error(err.Error(), "provider", provider, "record", record, "family", family, "old_ip", recordIP, "new_ip", ip, "duration", time.Since(start))
+	} else {
+		event.Result = HookResultSuccess
+		entry.Result = HistoryResultSuccess
+		msg = i18n.T(i18n.MsgRecordUpdated, provider, record, ip)
+		logger.Info(msg, "provider", provider, "record", record, "family", family, "old_ip", recordIP, "new_ip", ip, "duration", time.Since(start))
+	}
+	if hookErr := Hc.Run(event); hookErr != nil {
+		logger.Warn(hookErr.Error(), "provider", provider, "record", record)
+	}
+	return
+}
+
 func Install() (err error) {
 	if common.IsWindows() {
-		err = errors.New("windows 暂不支持安装到系统")
+		err = i18n.NewError(i18n.MsgWindowsInstallUnsupported)
 	} else {
 		// 注册系统服务
 		if Conf.CheckCycleMinutes == 0 {
-			err = errors.New("设置一下 " + ConfDirectoryName + "/" + ConfFileName + " 的 check_cycle_minutes 吧")
+			err = i18n.NewError(i18n.MsgConfCheckCycle, ConfDirectoryName+"/"+ConfFileName)
 			return
 		}
 		wd, err := os.Getwd()
@@ -59,14 +151,14 @@ func Install() (err error) {
 		if err != nil {
 			return err
 		}
-		log.Println("可以使用 systemctl 控制 " + RunningName + " 服务了")
+		logger.Info(i18n.T(i18n.MsgInstalled, RunningName), "path", installPath)
 	}
 	return
 }
 
 func Uninstall() (err error) {
 	if common.IsWindows() {
-		err = errors.New("windows 暂不支持安装到系统")
+		err = i18n.NewError(i18n.MsgWindowsInstallUnsupported)
 	} else {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -76,8 +168,8 @@ func Uninstall() (err error) {
 		if err != nil {
 			return err
 		}
-		log.Println("卸载服务成功")
-		log.Println("若要完全删除，请移步到 " + wd + " 和 " + ConfDirectoryName + " 完全删除")
+		logger.Info(i18n.T(i18n.MsgUninstalled), "path", installPath)
+		logger.Info(i18n.T(i18n.MsgUninstallHint, wd, ConfDirectoryName))
 	}
 	return
 }
@@ -119,8 +211,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 		if err != nil {
 			return
 		}
-		err = errors.New("请打开 " + ConfDirectoryName + "/" + NetworkCardFileName + " 选择网卡填入 " +
-			ConfDirectoryName + "/" + ConfFileName + " 的 network_card")
+		err = i18n.NewError(i18n.MsgSelectNetworkCard, ConfDirectoryName+"/"+NetworkCardFileName, ConfDirectoryName+"/"+ConfFileName)
 		return
 	}
 
@@ -138,7 +229,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 		if enabled.NetworkCard && nc.IPv4 != "" {
 			ipv4 = ncr[nc.IPv4]
 			if ipv4 == "" {
-				err = errors.New("IPv4 选择了不存在的网卡")
+				err = i18n.NewError(i18n.MsgNetworkCardNotFound, "IPv4")
 				return
 			}
 		} else {
@@ -146,7 +237,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 			if apiUrl.IPv4 == "" {
 				apiUrl.IPv4 = common.DefaultAPIUrl
 			}
-			resp, err2 := http.Get(apiUrl.IPv4)
+			resp, err2 := apiUrl.get(apiUrl.IPv4)
 			if err2 != nil {
 				err = err2
 				return
@@ -157,6 +248,10 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 					err = t
 				}
 			}(resp.Body)
+			if resp.StatusCode == http.StatusUnauthorized {
+				err = i18n.NewError(i18n.MsgAPIUnauthorized, apiUrl.IPv4)
+				return
+			}
 			recvJson, err2 := io.ReadAll(resp.Body)
 			if err2 != nil {
 				err = err2
@@ -170,7 +265,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 			ipv4 = ipInfo.IP
 		}
 		if strings.Contains(ipv4, ":") {
-			err = errors.New("获取到的 IPv4 格式错误，意外获取到了 " + ipv4)
+			err = i18n.NewError(i18n.MsgBadIPv4, ipv4)
 			return
 		}
 	}
@@ -181,7 +276,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 		if enabled.NetworkCard && nc.IPv6 != "" {
 			ipv6 = ncr[nc.IPv6]
 			if ipv6 == "" {
-				err = errors.New("IPv6 选择了不存在的网卡")
+				err = i18n.NewError(i18n.MsgNetworkCardNotFound, "IPv6")
 				return
 			}
 		} else {
@@ -189,7 +284,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 			if apiUrl.IPv6 == "" {
 				apiUrl.IPv6 = common.DefaultIPv6APIUrl
 			}
-			resp, err2 := http.Get(apiUrl.IPv6)
+			resp, err2 := apiUrl.get(apiUrl.IPv6)
 			if err2 != nil {
 				err = err2
 				return
@@ -200,6 +295,10 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 					err = t
 				}
 			}(resp.Body)
+			if resp.StatusCode == http.StatusUnauthorized {
+				err = i18n.NewError(i18n.MsgAPIUnauthorized, apiUrl.IPv6)
+				return
+			}
 			recvJson, err2 := io.ReadAll(resp.Body)
 			if err2 != nil {
 				err = err2
@@ -215,7 +314,7 @@ func GetOwnIP(enabled enable, apiUrl apiUrl, nc networkCard) (ipv4, ipv6 string,
 		if strings.Contains(ipv6, ":") {
 			ipv6 = common.DecodeIPv6(ipv6)
 		} else {
-			err = errors.New("获取到的 IPv6 格式错误，意外获取到了 " + ipv6)
+			err = i18n.NewError(i18n.MsgBadIPv6, ipv6)
 			return
 		}
 	}
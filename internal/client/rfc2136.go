@@ -0,0 +1,180 @@
+package client
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const RFC2136ConfFileName = "rfc2136.json"
+
+type tsigConf struct {
+	Name      string `json:"name"`
+	Algorithm string `json:"algorithm"`
+	Secret    string `json:"secret"`
+}
+
+type rfc2136Conf struct {
+	Server    string    `json:"server"`
+	Transport string    `json:"transport"`
+	Zone      string    `json:"zone"`
+	Domain    subdomain `json:"domain"`
+	TTL       uint32    `json:"ttl"`
+	TSIG      tsigConf  `json:"tsig"`
+}
+
+var tsigAlgorithms = map[string]string{
+	"hmac-sha1":   dns.HmacSHA1,
+	"hmac-sha256": dns.HmacSHA256,
+	"hmac-sha512": dns.HmacSHA512,
+}
+
+func (rc *rfc2136Conf) InitConf() (msg string, err error) {
+	*rc = rfc2136Conf{}
+	rc.Server = "ns1.example.com:53"
+	rc.Transport = "udp"
+	rc.Zone = "example.com"
+	rc.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	rc.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	rc.TTL = 60
+	rc.TSIG.Name = "ddns-watchdog"
+	rc.TSIG.Algorithm = "hmac-sha256"
+	rc.TSIG.Secret = i18n.T(i18n.MsgPlaceholderTSIGSecret)
+	err = common.MarshalAndSave(rc, ConfDirectoryName+"/"+RFC2136ConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+RFC2136ConfFileName)
+	return
+}
+
+func (rc *rfc2136Conf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+RFC2136ConfFileName, &rc)
+	if err != nil {
+		return
+	}
+	if rc.Server == "" || rc.Zone == "" || (rc.Domain.A == "" && rc.Domain.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+RFC2136ConfFileName, "server, zone, domain")
+		return
+	}
+	if _, _, err = net.SplitHostPort(rc.Server); err != nil {
+		// 没有端口时使用 53
+		rc.Server = net.JoinHostPort(rc.Server, "53")
+		err = nil
+	}
+	switch rc.Transport {
+	case "":
+		rc.Transport = "udp"
+	case "udp", "tcp":
+	default:
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+RFC2136ConfFileName, "transport")
+		return
+	}
+	if rc.TSIG.Name != "" {
+		if rc.TSIG.Algorithm == "" {
+			rc.TSIG.Algorithm = "hmac-sha256"
+		}
+		if _, ok := tsigAlgorithms[strings.ToLower(rc.TSIG.Algorithm)]; !ok || rc.TSIG.Secret == "" {
+			err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+RFC2136ConfFileName, "tsig.algorithm, tsig.secret")
+			return
+		}
+	}
+	if rc.TTL == 0 {
+		rc.TTL = 60
+	}
+	return
+}
+
+func (rc rfc2136Conf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	if enabled.IPv4 && rc.Domain.A != "" {
+		msgRow, err := updateRecord("RFC2136", rc.Domain.A, "A", ipv4,
+			func() (string, error) { return rc.getParseRecord(rc.Domain.A, dns.TypeA) },
+			func() error { return rc.updateParseRecord(ipv4, dns.TypeA, rc.Domain.A) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	if enabled.IPv6 && rc.Domain.AAAA != "" {
+		msgRow, err := updateRecord("RFC2136", rc.Domain.AAAA, "AAAA", ipv6,
+			func() (string, error) { return rc.getParseRecord(rc.Domain.AAAA, dns.TypeAAAA) },
+			func() error { return rc.updateParseRecord(ipv6, dns.TypeAAAA, rc.Domain.AAAA) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// getParseRecord 直接向权威服务器查询，记录不存在时返回空字符串，更新时会新建
+// 有多条记录时返回用逗号连接的值，以便与新 IP 比较后整体替换
+func (rc rfc2136Conf) getParseRecord(domain string, recordType uint16) (recordIP string, err error) {
+	req := new(dns.Msg)
+	req.SetQuestion(dns.Fqdn(domain), recordType)
+	req.RecursionDesired = false
+	resp, err := rc.exchange(req)
+	if err != nil {
+		return
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		err = i18n.NewError(i18n.MsgRFC2136Rcode, domain, dns.RcodeToString[resp.Rcode])
+		return
+	}
+	var values []string
+	for _, rr := range resp.Answer {
+		switch value := rr.(type) {
+		case *dns.A:
+			values = append(values, value.A.String())
+		case *dns.AAAA:
+			values = append(values, common.DecodeIPv6(value.AAAA.String()))
+		}
+	}
+	sort.Strings(values)
+	recordIP = strings.Join(values, ",")
+	return
+}
+
+// updateParseRecord 删除 domain 的整个 A 或 AAAA 记录集后写入新记录
+func (rc rfc2136Conf) updateParseRecord(ipAddr string, recordType uint16, domain string) (err error) {
+	rr, err := dns.NewRR(dns.Fqdn(domain) + " " + dns.TypeToString[recordType] + " " + ipAddr)
+	if err != nil {
+		return
+	}
+	rr.Header().Ttl = rc.TTL
+	req := new(dns.Msg)
+	req.SetUpdate(dns.Fqdn(rc.Zone))
+	req.RemoveRRset([]dns.RR{rr})
+	req.Insert([]dns.RR{rr})
+	resp, err := rc.exchange(req)
+	if err != nil {
+		return
+	}
+	if resp.Rcode != dns.RcodeSuccess {
+		err = i18n.NewError(i18n.MsgRFC2136Rcode, domain, dns.RcodeToString[resp.Rcode])
+		return
+	}
+	return
+}
+
+// exchange 发送请求，配置了 TSIG 时签名请求并校验响应
+func (rc rfc2136Conf) exchange(req *dns.Msg) (resp *dns.Msg, err error) {
+	dnsClient := &dns.Client{
+		Net:     rc.Transport,
+		Timeout: 10 * time.Second,
+	}
+	if rc.TSIG.Name != "" {
+		keyName := dns.Fqdn(rc.TSIG.Name)
+		dnsClient.TsigSecret = map[string]string{keyName: rc.TSIG.Secret}
+		req.SetTsig(keyName, tsigAlgorithms[strings.ToLower(rc.TSIG.Algorithm)], 300, time.Now().Unix())
+	}
+	resp, _, err = dnsClient.Exchange(req, rc.Server)
+	if err != nil {
+		err = i18n.NewError(i18n.MsgRFC2136Exchange, rc.Server, err)
+	}
+	return
+}
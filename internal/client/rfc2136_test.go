@@ -0,0 +1,199 @@
+package client
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const rfc2136TestSecret = "c2VjcmV0LXNlY3JldC1zZWNyZXQ=" // base64("secret-secret-secret")
+
+// rfc2136Stub 进程内的权威服务器，只有 TSIG 校验通过的请求才会被处理
+// refused 中的域名更新时返回 REFUSED
+type rfc2136Stub struct {
+	mutex   sync.Mutex
+	rrsets  map[string][]dns.RR // "名称/类型"
+	updates []*dns.Msg
+	refused string
+}
+
+func newRFC2136Stub(t *testing.T, rrs ...string) (*rfc2136Stub, string) {
+	t.Helper()
+	stub := &rfc2136Stub{rrsets: make(map[string][]dns.RR)}
+	for _, value := range rrs {
+		rr, err := dns.NewRR(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		stub.add(rr)
+	}
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	server := &dns.Server{
+		PacketConn:        conn,
+		Handler:           stub,
+		TsigSecret:        map[string]string{"ddns-watchdog.": rfc2136TestSecret},
+		NotifyStartedFunc: func() { close(started) },
+		// 默认只接受 QUERY 和 NOTIFY
+		MsgAcceptFunc: func(dh dns.Header) dns.MsgAcceptAction {
+			if int(dh.Bits>>11)&0xF == dns.OpcodeUpdate {
+				return dns.MsgAccept
+			}
+			return dns.DefaultMsgAcceptFunc(dh)
+		},
+	}
+	go func() { _ = server.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = server.Shutdown() })
+	return stub, conn.LocalAddr().String()
+}
+
+func rrsetKey(name string, rrtype uint16) string {
+	return strings.ToLower(name) + "/" + dns.TypeToString[rrtype]
+}
+
+func (stub *rfc2136Stub) add(rr dns.RR) {
+	key := rrsetKey(rr.Header().Name, rr.Header().Rrtype)
+	stub.rrsets[key] = append(stub.rrsets[key], rr)
+}
+
+func (stub *rfc2136Stub) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	tsig := req.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		// 未签名或签名错误时不签名响应
+		resp.SetRcode(req, dns.RcodeNotAuth)
+		_ = w.WriteMsg(resp)
+		return
+	}
+	stub.mutex.Lock()
+	switch req.Opcode {
+	case dns.OpcodeQuery:
+		resp.Authoritative = true
+		resp.Answer = stub.rrsets[rrsetKey(req.Question[0].Name, req.Question[0].Qtype)]
+	case dns.OpcodeUpdate:
+		stub.updates = append(stub.updates, req)
+		if len(req.Ns) > 0 && strings.EqualFold(req.Ns[0].Header().Name, stub.refused) {
+			resp.Rcode = dns.RcodeRefused
+			break
+		}
+		for _, rr := range req.Ns {
+			if rr.Header().Class == dns.ClassANY {
+				delete(stub.rrsets, rrsetKey(rr.Header().Name, rr.Header().Rrtype))
+			} else {
+				stub.add(rr)
+			}
+		}
+	}
+	stub.mutex.Unlock()
+	resp.SetTsig(tsig.Hdr.Name, tsig.Algorithm, 300, time.Now().Unix())
+	_ = w.WriteMsg(resp)
+}
+
+func (stub *rfc2136Stub) values(name string, rrtype uint16) (values []string) {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	for _, rr := range stub.rrsets[rrsetKey(name, rrtype)] {
+		values = append(values, strings.TrimPrefix(rr.String(), rr.Header().String()))
+	}
+	return
+}
+
+func (stub *rfc2136Stub) received() []*dns.Msg {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	return append([]*dns.Msg(nil), stub.updates...)
+}
+
+func TestRFC2136Update(t *testing.T) {
+	ConfDirectoryName = t.TempDir()
+	stub, addr := newRFC2136Stub(t, "home.example.com. 300 IN A 192.0.2.1", "home.example.com. 300 IN A 192.0.2.9")
+	rc := rfc2136Conf{
+		Server:    addr,
+		Transport: "udp",
+		Zone:      "example.com",
+		Domain:    subdomain{A: "home.example.com", AAAA: "home.example.com"},
+		TTL:       60,
+		TSIG:      tsigConf{Name: "ddns-watchdog", Algorithm: "hmac-sha256", Secret: rfc2136TestSecret},
+	}
+	msg, errs := rc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(errs) != 0 || len(msg) != 2 {
+		t.Fatalf("Run() msg = %v, errs = %v", msg, errs)
+	}
+
+	// 每个 UPDATE 的区域为 zone，先删除整个记录集再写入新记录，并带有 TSIG
+	updates := stub.received()
+	if len(updates) != 2 {
+		t.Fatalf("got %d updates, want 2", len(updates))
+	}
+	for i, rrtype := range []uint16{dns.TypeA, dns.TypeAAAA} {
+		update := updates[i]
+		if len(update.Question) != 1 || update.Question[0].Name != "example.com." || update.Question[0].Qtype != dns.TypeSOA {
+			t.Errorf("update %d zone = %v", i, update.Question)
+		}
+		if update.IsTsig() == nil || update.IsTsig().Algorithm != dns.HmacSHA256 {
+			t.Errorf("update %d is not signed with hmac-sha256", i)
+		}
+		if len(update.Ns) != 2 {
+			t.Fatalf("update %d = %v", i, update.Ns)
+		}
+		remove, insert := update.Ns[0].Header(), update.Ns[1].Header()
+		if remove.Class != dns.ClassANY || remove.Rrtype != rrtype || remove.Name != "home.example.com." || remove.Ttl != 0 {
+			t.Errorf("update %d remove = %v", i, update.Ns[0])
+		}
+		if insert.Class != dns.ClassINET || insert.Rrtype != rrtype || insert.Ttl != 60 {
+			t.Errorf("update %d insert = %v", i, update.Ns[1])
+		}
+	}
+	if got := stub.values("home.example.com.", dns.TypeA); len(got) != 1 || got[0] != "192.0.2.2" {
+		t.Errorf("A = %v, want only 192.0.2.2", got)
+	}
+	if got := stub.values("home.example.com.", dns.TypeAAAA); len(got) != 1 || got[0] != "2001:db8::2" {
+		t.Errorf("AAAA = %v", got)
+	}
+
+	// 记录已经是本机 IP 时不再发送 UPDATE
+	msg, errs = rc.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(msg) != 0 || len(errs) != 0 || len(stub.received()) != 2 {
+		t.Fatalf("second Run() msg = %v, errs = %v, updates = %d", msg, errs, len(stub.received()))
+	}
+}
+
+func TestRFC2136Errors(t *testing.T) {
+	ConfDirectoryName = t.TempDir()
+	tests := []struct {
+		name    string
+		secret  string
+		tsig    bool
+		refused string
+		want    string
+	}{
+		{"bad tsig", "d3Jvbmctc2VjcmV0", true, "", "NOTAUTH"},
+		{"unsigned", "", false, "", "NOTAUTH"},
+		{"refused", rfc2136TestSecret, true, "home.example.com.", "REFUSED"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, addr := newRFC2136Stub(t, "home.example.com. 300 IN A 192.0.2.1")
+			stub.mutex.Lock()
+			stub.refused = tt.refused
+			stub.mutex.Unlock()
+			rc := rfc2136Conf{Server: addr, Transport: "udp", Zone: "example.com", Domain: subdomain{A: "home.example.com"}, TTL: 60}
+			if tt.tsig {
+				rc.TSIG = tsigConf{Name: "ddns-watchdog", Algorithm: "hmac-sha256", Secret: tt.secret}
+			}
+			checkRunError(t, rc.Run, tt.want)
+			if got := stub.values("home.example.com.", dns.TypeA); len(got) != 1 || got[0] != "192.0.2.1" {
+				t.Errorf("A = %v, want unchanged", got)
+			}
+		})
+	}
+}
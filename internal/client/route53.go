@@ -0,0 +1,408 @@
+package client
+
+import (
+	"bytes"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/hex"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const Route53ConfFileName = "route53.json"
+
+const (
+	route53APIVersion = "2013-04-01"
+	route53Namespace  = "https://route53.amazonaws.com/doc/2013-04-01/"
+	route53Service    = "route53"
+)
+
+var (
+	route53Mutex sync.Mutex
+	// route53ZoneIds 按名称查询到的托管区域 ID，程序运行期间不会变化
+	route53ZoneIds = make(map[string]string)
+)
+
+type route53Conf struct {
+	AccessKeyId        string    `json:"access_key_id"`
+	SecretAccessKey    string    `json:"secret_access_key"`
+	SessionToken       string    `json:"session_token"`
+	Endpoint           string    `json:"endpoint"`
+	Region             string    `json:"region"`
+	HostedZoneId       string    `json:"hosted_zone_id"`
+	ZoneName           string    `json:"zone_name"`
+	Domain             subdomain `json:"domain"`
+	TTL                int       `json:"ttl"`
+	WaitInsync         bool      `json:"wait_insync"`
+	WaitTimeoutSeconds int       `json:"wait_timeout_seconds"`
+}
+
+type route53ResourceRecord struct {
+	Value string `xml:"Value"`
+}
+
+type route53RecordSet struct {
+	Name            string                  `xml:"Name"`
+	Type            string                  `xml:"Type"`
+	TTL             int                     `xml:"TTL,omitempty"`
+	ResourceRecords []route53ResourceRecord `xml:"ResourceRecords>ResourceRecord"`
+}
+
+type route53Change struct {
+	Action            string           `xml:"Action"`
+	ResourceRecordSet route53RecordSet `xml:"ResourceRecordSet"`
+}
+
+type route53ChangeInfo struct {
+	Id     string `xml:"Id"`
+	Status string `xml:"Status"`
+}
+
+type route53ChangeRequest struct {
+	XMLName xml.Name        `xml:"ChangeResourceRecordSetsRequest"`
+	Xmlns   string          `xml:"xmlns,attr"`
+	Changes []route53Change `xml:"ChangeBatch>Changes>Change"`
+}
+
+type route53Error struct {
+	Code      string `xml:"Error>Code"`
+	Message   string `xml:"Error>Message"`
+	RequestId string `xml:"RequestId"`
+}
+
+func (r5c *route53Conf) InitConf() (msg string, err error) {
+	*r5c = route53Conf{}
+	r5c.AccessKeyId = i18n.T(i18n.MsgPlaceholderGetAt, "https://console.aws.amazon.com/iam/home#/security_credentials")
+	r5c.SecretAccessKey = r5c.AccessKeyId
+	r5c.Endpoint = "https://route53.amazonaws.com"
+	r5c.Region = "us-east-1"
+	r5c.ZoneName = "example.com"
+	r5c.Domain.A = i18n.T(i18n.MsgPlaceholderSubDomainA) + ".example.com"
+	r5c.Domain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA) + ".example.com"
+	r5c.TTL = 300
+	r5c.WaitTimeoutSeconds = 60
+	err = common.MarshalAndSave(r5c, ConfDirectoryName+"/"+Route53ConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+Route53ConfFileName)
+	return
+}
+
+func (r5c *route53Conf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+Route53ConfFileName, &r5c)
+	if err != nil {
+		return
+	}
+	if r5c.AccessKeyId == "" || r5c.SecretAccessKey == "" || (r5c.HostedZoneId == "" && r5c.ZoneName == "") || (r5c.Domain.A == "" && r5c.Domain.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+Route53ConfFileName, "access_key_id, secret_access_key, hosted_zone_id or zone_name, domain")
+		return
+	}
+	if r5c.Endpoint == "" {
+		r5c.Endpoint = "https://route53.amazonaws.com"
+	}
+	if _, err = url.Parse(r5c.Endpoint); err != nil {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+Route53ConfFileName, "endpoint")
+		return
+	}
+	r5c.Endpoint = strings.TrimSuffix(r5c.Endpoint, "/")
+	if r5c.Region == "" {
+		r5c.Region = "us-east-1"
+	}
+	// 控制台显示的 ID 可能带有 /hostedzone/ 前缀
+	r5c.HostedZoneId = strings.TrimPrefix(r5c.HostedZoneId, "/hostedzone/")
+	if r5c.TTL <= 0 {
+		r5c.TTL = 300
+	}
+	if r5c.WaitTimeoutSeconds <= 0 {
+		r5c.WaitTimeoutSeconds = 60
+	}
+	return
+}
+
+func (r5c route53Conf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	if enabled.IPv4 && r5c.Domain.A != "" {
+		msgRow, err := updateRecord("Route53", r5c.Domain.A, "A", ipv4,
+			func() (string, error) { return r5c.getParseRecord(r5c.Domain.A, "A") },
+			func() error { return r5c.updateParseRecord(ipv4, "A", r5c.Domain.A) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	if enabled.IPv6 && r5c.Domain.AAAA != "" {
+		msgRow, err := updateRecord("Route53", r5c.Domain.AAAA, "AAAA", ipv6,
+			func() (string, error) { return r5c.getParseRecord(r5c.Domain.AAAA, "AAAA") },
+			func() error { return r5c.updateParseRecord(ipv6, "AAAA", r5c.Domain.AAAA) })
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// zoneId 未配置 hosted_zone_id 时按 zone_name 查询并缓存
+func (r5c route53Conf) zoneId() (id string, err error) {
+	if r5c.HostedZoneId != "" {
+		return r5c.HostedZoneId, nil
+	}
+	zoneName := strings.ToLower(strings.TrimSuffix(r5c.ZoneName, ".") + ".")
+	route53Mutex.Lock()
+	id, ok := route53ZoneIds[zoneName]
+	route53Mutex.Unlock()
+	if ok {
+		return
+	}
+	query := url.Values{}
+	query.Set("dnsname", zoneName)
+	query.Set("maxitems", "1")
+	var resp struct {
+		HostedZones []struct {
+			Id   string `xml:"Id"`
+			Name string `xml:"Name"`
+		} `xml:"HostedZones>HostedZone"`
+	}
+	err = r5c.call("GET", "/"+route53APIVersion+"/hostedzonesbyname", query, nil, &resp)
+	if err != nil {
+		return
+	}
+	// 结果按名称排序，第一个不一定是要找的区域
+	if len(resp.HostedZones) == 0 || strings.ToLower(resp.HostedZones[0].Name) != zoneName {
+		err = i18n.NewError(i18n.MsgRoute53ZoneNotFound, r5c.ZoneName)
+		return
+	}
+	id = strings.TrimPrefix(resp.HostedZones[0].Id, "/hostedzone/")
+	route53Mutex.Lock()
+	route53ZoneIds[zoneName] = id
+	route53Mutex.Unlock()
+	return
+}
+
+// getParseRecord 记录集不存在时返回空字符串，UPSERT 会新建
+// 有多个值时返回用逗号连接的值，以便与新 IP 比较后整体替换
+func (r5c route53Conf) getParseRecord(domain, recordType string) (recordIP string, err error) {
+	zoneId, err := r5c.zoneId()
+	if err != nil {
+		return
+	}
+	name := strings.ToLower(strings.TrimSuffix(domain, ".") + ".")
+	query := url.Values{}
+	query.Set("name", name)
+	query.Set("type", recordType)
+	query.Set("maxitems", "1")
+	var resp struct {
+		ResourceRecordSets []route53RecordSet `xml:"ResourceRecordSets>ResourceRecordSet"`
+	}
+	err = r5c.call("GET", "/"+route53APIVersion+"/hostedzone/"+zoneId+"/rrset", query, nil, &resp)
+	if err != nil {
+		return
+	}
+	// 返回的是从 name 开始的记录集，名称或类型不同说明记录不存在
+	if len(resp.ResourceRecordSets) == 0 ||
+		strings.ToLower(resp.ResourceRecordSets[0].Name) != name ||
+		resp.ResourceRecordSets[0].Type != recordType {
+		return
+	}
+	var values []string
+	for _, record := range resp.ResourceRecordSets[0].ResourceRecords {
+		if recordType == "AAAA" {
+			values = append(values, common.DecodeIPv6(record.Value))
+		} else {
+			values = append(values, record.Value)
+		}
+	}
+	sort.Strings(values)
+	recordIP = strings.Join(values, ",")
+	return
+}
+
+func (r5c route53Conf) updateParseRecord(ipAddr, recordType, domain string) (err error) {
+	zoneId, err := r5c.zoneId()
+	if err != nil {
+		return
+	}
+	reqData := route53ChangeRequest{
+		Xmlns: route53Namespace,
+		Changes: []route53Change{{
+			Action: "UPSERT",
+			ResourceRecordSet: route53RecordSet{
+				Name:            strings.TrimSuffix(domain, ".") + ".",
+				Type:            recordType,
+				TTL:             r5c.TTL,
+				ResourceRecords: []route53ResourceRecord{{Value: ipAddr}},
+			},
+		}},
+	}
+	reqXml, err := xml.Marshal(reqData)
+	if err != nil {
+		return
+	}
+	var resp struct {
+		ChangeInfo route53ChangeInfo `xml:"ChangeInfo"`
+	}
+	err = r5c.call("POST", "/"+route53APIVersion+"/hostedzone/"+zoneId+"/rrset/", nil, append([]byte(xml.Header), reqXml...), &resp)
+	if err != nil {
+		return
+	}
+	if r5c.WaitInsync {
+		err = r5c.waitInsync(resp.ChangeInfo)
+	}
+	return
+}
+
+// waitInsync 轮询 GetChange 直到变更同步到所有权威服务器
+func (r5c route53Conf) waitInsync(change route53ChangeInfo) (err error) {
+	changeId := strings.TrimPrefix(change.Id, "/change/")
+	deadline := time.Now().Add(time.Duration(r5c.WaitTimeoutSeconds) * time.Second)
+	for change.Status != "INSYNC" {
+		if time.Now().After(deadline) {
+			err = i18n.NewError(i18n.MsgRoute53WaitTimeout, changeId, r5c.WaitTimeoutSeconds)
+			return
+		}
+		time.Sleep(5 * time.Second)
+		var resp struct {
+			ChangeInfo route53ChangeInfo `xml:"ChangeInfo"`
+		}
+		err = r5c.call("GET", "/"+route53APIVersion+"/change/"+changeId, nil, nil, &resp)
+		if err != nil {
+			return
+		}
+		change = resp.ChangeInfo
+	}
+	return
+}
+
+// call 发送 SigV4 签名的请求，响应不是 2xx 时解析 ErrorResponse 返回 MsgRoute53API 错误
+func (r5c route53Conf) call(method, path string, query url.Values, payload []byte, result any) (err error) {
+	endpoint, err := url.Parse(r5c.Endpoint)
+	if err != nil {
+		return
+	}
+	rawUrl := r5c.Endpoint + path
+	if len(query) > 0 {
+		rawUrl += "?" + query.Encode()
+	}
+	req, err := http.NewRequest(method, rawUrl, bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "text/xml")
+	}
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ("+common.ProjectUrl+")")
+	signV4(req, payload, endpoint.Host, r5c.Region, route53Service, r5c.AccessKeyId, r5c.SecretAccessKey, r5c.SessionToken, time.Now())
+
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(resp.Body)
+	recvXml, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respErr := route53Error{}
+		if xml.Unmarshal(recvXml, &respErr) != nil || respErr.Code == "" {
+			respErr.Code = resp.Status
+		}
+		err = i18n.NewError(i18n.MsgRoute53API, respErr.Code, respErr.Message, respErr.RequestId)
+		return
+	}
+	err = xml.Unmarshal(recvXml, result)
+	return
+}
+
+// signV4 按 AWS Signature Version 4 设置 X-Amz-Date 和 Authorization 请求头
+// 签名的请求头为 host、x-amz-date 以及已设置的 content-type 和 x-amz-security-token
+func signV4(req *http.Request, payload []byte, host, region, service, accessKeyId, secretAccessKey, sessionToken string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	if sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", sessionToken)
+	}
+
+	headers := map[string]string{"host": host}
+	for _, name := range []string{"Content-Type", "X-Amz-Date", "X-Amz-Security-Token"} {
+		if value := req.Header.Get(name); value != "" {
+			headers[strings.ToLower(name)] = strings.TrimSpace(value)
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	canonicalHeaders := ""
+	for _, name := range names {
+		canonicalHeaders += name + ":" + headers[name] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalPath := req.URL.EscapedPath()
+	if canonicalPath == "" {
+		canonicalPath = "/"
+	}
+	canonicalRequest := req.Method + "\n" +
+		canonicalPath + "\n" +
+		canonicalQueryV4(req.URL.Query()) + "\n" +
+		canonicalHeaders + "\n" +
+		signedHeaders + "\n" +
+		sha256Hex(payload)
+	credentialScope := date + "/" + region + "/" + service + "/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + credentialScope + "\n" + sha256Hex([]byte(canonicalRequest))
+	signingKey := hmacSHA256([]byte("AWS4"+secretAccessKey), date)
+	signingKey = hmacSHA256(signingKey, region)
+	signingKey = hmacSHA256(signingKey, service)
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+accessKeyId+"/"+credentialScope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+// canonicalQueryV4 按键名排序并使用 RFC 3986 编码，空格编码为 %20 而不是 +
+func canonicalQueryV4(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []string
+	for _, key := range keys {
+		values := append([]string{}, query[key]...)
+		sort.Strings(values)
+		for _, value := range values {
+			pairs = append(pairs, uriEncodeV4(key)+"="+uriEncodeV4(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+func uriEncodeV4(str string) string {
+	builder := strings.Builder{}
+	for _, b := range []byte(str) {
+		if 'A' <= b && b <= 'Z' || 'a' <= b && b <= 'z' || '0' <= b && b <= '9' || b == '-' || b == '_' || b == '.' || b == '~' {
+			builder.WriteByte(b)
+		} else {
+			builder.WriteString("%" + strings.ToUpper(strconv.FormatInt(int64(b)|0x100, 16)[1:]))
+		}
+	}
+	return builder.String()
+}
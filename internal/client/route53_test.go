@@ -0,0 +1,266 @@
+package client
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"ddns-watchdog/internal/i18n"
+)
+
+// Route 53 替身接受的访问密钥
+const (
+	route53TestKeyId  = "AKIDTEST"
+	route53TestSecret = "SECRETTEST"
+	route53TestToken  = "session-token"
+)
+
+func resetRoute53ZoneIds() {
+	route53Mutex.Lock()
+	route53ZoneIds = make(map[string]string)
+	route53Mutex.Unlock()
+}
+
+// route53TestChange 替身按 API 文档解析的 ChangeResourceRecordSets 请求
+type route53TestChange struct {
+	XMLName xml.Name `xml:"https://route53.amazonaws.com/doc/2013-04-01/ ChangeResourceRecordSetsRequest"`
+	Changes []struct {
+		Action string `xml:"Action"`
+		Set    struct {
+			Name   string   `xml:"Name"`
+			Type   string   `xml:"Type"`
+			TTL    int      `xml:"TTL"`
+			Values []string `xml:"ResourceRecords>ResourceRecord>Value"`
+		} `xml:"ResourceRecordSet"`
+	} `xml:"ChangeBatch>Changes>Change"`
+}
+
+// newRoute53Stub Route 53 接口的本地替身，用收到的 X-Amz-Date 重新签名，与 Authorization 不一致时返回 403
+// 与真实接口一样，ListHostedZonesByName 和 ListResourceRecordSets 返回从查询名称开始排序的结果，
+// rrsets 以 "名称 类型" 为键，只能在 stub.inspect 中访问
+func newRoute53Stub(t *testing.T) (stub *apiStub, rrsets map[string][]string, r5c route53Conf) {
+	t.Helper()
+	rrsets = map[string][]string{
+		"home.example.com. A": {"192.0.2.1"},
+		// 同名的 TXT 记录集排在 AAAA 之后，查询 AAAA 时会返回它
+		"home.example.com. TXT": {`"v=spf1 -all"`},
+	}
+	fail := func(status int, code, message string) stubResponse {
+		return stubResponse{Status: status, Header: map[string]string{"Content-Type": "text/xml"},
+			Body: `<?xml version="1.0"?><ErrorResponse xmlns="` + route53Namespace + `"><Error><Type>Sender</Type><Code>` +
+				code + `</Code><Message>` + message + `</Message></Error><RequestId>req-` + code + `</RequestId></ErrorResponse>`}
+	}
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		if msg := verifyRoute53(req, route53TestKeyId, route53TestSecret); msg != "" {
+			return fail(403, "SignatureDoesNotMatch", msg)
+		}
+		if req.Header.Get("X-Amz-Security-Token") != route53TestToken {
+			return fail(403, "InvalidClientTokenId", "security token")
+		}
+		query := req.URL.Query()
+		switch {
+		case req.Method == "GET" && req.URL.Path == "/2013-04-01/hostedzonesbyname":
+			body := `<ListHostedZonesByNameResponse xmlns="` + route53Namespace + `"><HostedZones>`
+			for _, zone := range [][2]string{{"Z1", "example.com."}, {"Z2", "example.net."}} {
+				if zone[1] >= query.Get("dnsname") {
+					body += `<HostedZone><Id>/hostedzone/` + zone[0] + `</Id><Name>` + zone[1] + `</Name></HostedZone>`
+				}
+			}
+			return stubResponse{Body: body + `</HostedZones></ListHostedZonesByNameResponse>`}
+		case req.Method == "GET" && req.URL.Path == "/2013-04-01/hostedzone/Z1/rrset":
+			keys := make([]string, 0, len(rrsets))
+			for key := range rrsets {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			body := `<ListResourceRecordSetsResponse xmlns="` + route53Namespace + `"><ResourceRecordSets>`
+			for _, key := range keys {
+				if key >= query.Get("name")+" "+query.Get("type") {
+					name, recordType, _ := strings.Cut(key, " ")
+					body += `<ResourceRecordSet><Name>` + name + `</Name><Type>` + recordType + `</Type><TTL>300</TTL><ResourceRecords>`
+					for _, value := range rrsets[key] {
+						body += `<ResourceRecord><Value>` + value + `</Value></ResourceRecord>`
+					}
+					body += `</ResourceRecords></ResourceRecordSet>`
+					break
+				}
+			}
+			return stubResponse{Body: body + `</ResourceRecordSets></ListResourceRecordSetsResponse>`}
+		case req.Method == "POST" && req.URL.Path == "/2013-04-01/hostedzone/Z1/rrset/":
+			var change route53TestChange
+			if req.Header.Get("Content-Type") != "text/xml" || xml.Unmarshal(req.Body, &change) != nil {
+				return fail(400, "MalformedInput", "ChangeResourceRecordSetsRequest")
+			}
+			for _, c := range change.Changes {
+				if c.Action != "UPSERT" || c.Set.TTL != 300 || len(c.Set.Values) == 0 {
+					return fail(400, "InvalidChangeBatch", c.Action)
+				}
+				rrsets[c.Set.Name+" "+c.Set.Type] = c.Set.Values
+			}
+			return stubResponse{Body: `<ChangeResourceRecordSetsResponse xmlns="` + route53Namespace +
+				`"><ChangeInfo><Id>/change/C1</Id><Status>INSYNC</Status></ChangeInfo></ChangeResourceRecordSetsResponse>`}
+		}
+		return fail(404, "NoSuchHostedZone", req.URL.Path)
+	})
+	r5c = route53Conf{
+		AccessKeyId:        route53TestKeyId,
+		SecretAccessKey:    route53TestSecret,
+		SessionToken:       route53TestToken,
+		Endpoint:           server.URL,
+		Region:             "us-east-1",
+		ZoneName:           "example.com",
+		Domain:             subdomain{A: "home.example.com", AAAA: "home.example.com"},
+		TTL:                300,
+		WaitInsync:         true,
+		WaitTimeoutSeconds: 60,
+	}
+	return
+}
+
+// verifyRoute53 用收到的方法、路径、查询、请求头和请求体重新签名，返回空字符串表示通过
+// signV4 本身由 TestSignV4KnownAnswer 校验，这里确认实际发送的请求与签名时一致
+func verifyRoute53(req stubRequest, accessKeyId, secretAccessKey string) string {
+	now, err := time.Parse("20060102T150405Z", req.Header.Get("X-Amz-Date"))
+	if err != nil || time.Since(now) > 5*time.Minute {
+		return "bad X-Amz-Date"
+	}
+	signed, err := http.NewRequest(req.Method, req.URL.String(), nil)
+	if err != nil {
+		return err.Error()
+	}
+	if contentType := req.Header.Get("Content-Type"); contentType != "" {
+		signed.Header.Set("Content-Type", contentType)
+	}
+	signV4(signed, req.Body, req.Host, "us-east-1", route53Service, accessKeyId, secretAccessKey,
+		req.Header.Get("X-Amz-Security-Token"), now)
+	if signed.Header.Get("Authorization") != req.Header.Get("Authorization") {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+// TestSignV4KnownAnswer 用例取自 AWS Signature Version 4 测试套件
+func TestSignV4KnownAnswer(t *testing.T) {
+	tests := []struct {
+		name          string
+		method        string
+		rawUrl        string
+		contentType   string
+		payload       string
+		wantSigned    string
+		wantSignature string
+	}{
+		{"get-vanilla", "GET", "https://example.amazonaws.com/", "", "", "host;x-amz-date",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "GET", "https://example.amazonaws.com/?Param2=value2&Param1=value1", "", "", "host;x-amz-date",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+		{"post-vanilla", "POST", "https://example.amazonaws.com/", "", "", "host;x-amz-date",
+			"5da7c1a2acd57cee7505fc6676e4e544621c30862966e37dddb68e92efbe5d6b"},
+		{"post-vanilla-query", "POST", "https://example.amazonaws.com/?Param1=value1", "", "", "host;x-amz-date",
+			"28038455d6de14eafc1f9222cf5aa6f1a96197d7deb8263271d420d138af7f11"},
+		{"post-x-www-form-urlencoded", "POST", "https://example.amazonaws.com/", "application/x-www-form-urlencoded", "Param1=value1", "content-type;host;x-amz-date",
+			"ff11897932ad3f4e8b18135d722051e5ac45fc38421b1da7b9d196a0fe09473a"},
+	}
+	now := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest(tt.method, tt.rawUrl, strings.NewReader(tt.payload))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			signV4(req, []byte(tt.payload), "example.amazonaws.com", "us-east-1", "service",
+				"AKIDEXAMPLE", "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY", "", now)
+			want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, SignedHeaders=" +
+				tt.wantSigned + ", Signature=" + tt.wantSignature
+			if got := req.Header.Get("Authorization"); got != want {
+				t.Errorf("Authorization = %s\nwant %s", got, want)
+			}
+			if got := req.Header.Get("X-Amz-Date"); got != "20150830T123600Z" {
+				t.Errorf("X-Amz-Date = %s", got)
+			}
+		})
+	}
+}
+
+func TestRoute53Upsert(t *testing.T) {
+	resetRoute53ZoneIds()
+	stub, rrsets, r5c := newRoute53Stub(t)
+	msg, errs := r5c.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(errs) != 0 || len(msg) != 2 {
+		t.Fatalf("Run() msg = %v, errs = %v", msg, errs)
+	}
+	// 已有的 A 记录集和不存在的 AAAA 记录集都使用 UPSERT
+	stub.inspect(func() {
+		want := map[string][]string{
+			"home.example.com. A":    {"192.0.2.2"},
+			"home.example.com. AAAA": {"2001:db8:0:0:0:0:0:2"},
+			"home.example.com. TXT":  {`"v=spf1 -all"`},
+		}
+		if !reflect.DeepEqual(rrsets, want) {
+			t.Errorf("rrsets = %v, want %v", rrsets, want)
+		}
+	})
+	// 托管区域 ID 只查询一次，变更已是 INSYNC 时不再轮询
+	if lookups := stub.received("GET", "/2013-04-01/hostedzonesbyname"); len(lookups) != 1 {
+		t.Errorf("hostedzonesbyname requests = %d", len(lookups))
+	}
+	if polls := stub.received("GET", "/2013-04-01/change/C1"); len(polls) != 0 {
+		t.Errorf("GetChange requests = %d", len(polls))
+	}
+
+	// IP 未变化时不发送变更
+	msg, errs = r5c.Run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(errs) != 0 || len(msg) != 0 {
+		t.Fatalf("Run() msg = %v, errs = %v", msg, errs)
+	}
+	if changes := stub.received("POST", "/2013-04-01/hostedzone/Z1/rrset/"); len(changes) != 2 {
+		t.Errorf("ChangeResourceRecordSets requests = %d", len(changes))
+	}
+}
+
+func TestRoute53ZoneNotFound(t *testing.T) {
+	// example.co 查询结果的第一个是 example.com.，不能当作要找的区域
+	for _, zoneName := range []string{"example.org", "example.co"} {
+		t.Run(zoneName, func(t *testing.T) {
+			resetRoute53ZoneIds()
+			stub, _, r5c := newRoute53Stub(t)
+			r5c.ZoneName = zoneName
+			_, errs := r5c.Run(enable{IPv4: true}, "192.0.2.2", "")
+			var i18nErr *i18n.Error
+			if len(errs) != 1 || !errors.As(errs[0], &i18nErr) || i18nErr.ID != i18n.MsgRoute53ZoneNotFound {
+				t.Fatalf("Run() errors = %v", errs)
+			}
+			if changes := stub.received("POST", "/2013-04-01/hostedzone/Z1/rrset/"); len(changes) != 0 {
+				t.Errorf("ChangeResourceRecordSets requests = %d", len(changes))
+			}
+		})
+	}
+}
+
+func TestRoute53APIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(r5c *route53Conf)
+		want   string
+	}{
+		{"bad signature", func(r5c *route53Conf) { r5c.SecretAccessKey = "wrong" }, "SignatureDoesNotMatch: signature mismatch (RequestId: req-SignatureDoesNotMatch)"},
+		{"missing session token", func(r5c *route53Conf) { r5c.SessionToken = "" }, "InvalidClientTokenId"},
+		{"unknown hosted zone", func(r5c *route53Conf) { r5c.HostedZoneId = "Z9" }, "NoSuchHostedZone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetRoute53ZoneIds()
+			_, _, r5c := newRoute53Stub(t)
+			tt.modify(&r5c)
+			checkRunError(t, r5c.Run, tt.want)
+		})
+	}
+}
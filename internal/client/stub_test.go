@@ -0,0 +1,170 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// stubRequest 替身收到的请求，Form 包含查询参数和表单
+type stubRequest struct {
+	Method string
+	URL    *url.URL
+	Host   string
+	Header http.Header
+	Form   url.Values
+	Body   []byte
+}
+
+// stubResponse Status 为 0 时为 200，Body 为 string 时原样返回，其他类型编码为 JSON
+type stubResponse struct {
+	Status int
+	Header map[string]string
+	Body   any
+}
+
+// apiStub 服务商接口的本地替身，记录收到的请求，响应由各服务商的测试决定
+// respond 在持有锁时调用，服务商的状态只在 respond 和 inspect 中访问
+type apiStub struct {
+	mutex    sync.Mutex
+	requests []stubRequest
+	respond  func(req stubRequest) stubResponse
+}
+
+// newAPIStub 同时将 ConfDirectoryName 指向临时目录，Run 会在其中写入历史记录
+func newAPIStub(t *testing.T, respond func(req stubRequest) stubResponse) (*apiStub, *httptest.Server) {
+	t.Helper()
+	ConfDirectoryName = t.TempDir()
+	stub := &apiStub{respond: respond}
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+	return stub, server
+}
+
+func newStubRequest(req *http.Request) stubRequest {
+	var body []byte
+	if req.Body != nil {
+		body, _ = io.ReadAll(req.Body)
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+	_ = req.ParseForm()
+	return stubRequest{Method: req.Method, URL: req.URL, Host: req.Host, Header: req.Header, Form: req.Form, Body: body}
+}
+
+func (stub *apiStub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	request := newStubRequest(req)
+	stub.mutex.Lock()
+	stub.requests = append(stub.requests, request)
+	resp := stub.respond(request)
+	stub.mutex.Unlock()
+	for key, value := range resp.Header {
+		w.Header().Set(key, value)
+	}
+	if resp.Status != 0 {
+		w.WriteHeader(resp.Status)
+	}
+	switch body := resp.Body.(type) {
+	case nil:
+	case string:
+		_, _ = w.Write([]byte(body))
+	default:
+		_ = json.NewEncoder(w).Encode(body)
+	}
+}
+
+// received 返回方法和路径一致的请求，method 为空时不检查方法
+func (stub *apiStub) received(method, path string) (requests []stubRequest) {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	for _, request := range stub.requests {
+		if (method == "" || request.Method == method) && request.URL.Path == path {
+			requests = append(requests, request)
+		}
+	}
+	return
+}
+
+func (stub *apiStub) inspect(f func()) {
+	stub.mutex.Lock()
+	defer stub.mutex.Unlock()
+	f()
+}
+
+// stubRecord 替身中的解析记录
+type stubRecord struct {
+	Id    string
+	Name  string
+	Type  string
+	Value string
+}
+
+// mixedRecords 同名的 A、AAAA 和 CNAME 记录，替身不按类型过滤，由客户端选择类型一致的记录
+func mixedRecords(name string) []stubRecord {
+	return []stubRecord{
+		{Id: "id-cname", Name: name, Type: "CNAME", Value: "other.example.net"},
+		{Id: "id-aaaa", Name: name, Type: "AAAA", Value: "2001:db8::1"},
+		{Id: "id-a", Name: name, Type: "A", Value: "192.0.2.1"},
+	}
+}
+
+// checkRecordSelection lookup 按类型查询 mixedRecords，没有该类型的记录时返回错误或空的记录 ID
+func checkRecordSelection(t *testing.T, lookup func(recordType string) (id, value string, err error)) {
+	t.Helper()
+	tests := []struct {
+		recordType string
+		wantId     string
+		wantValue  string
+	}{
+		{"A", "id-a", "192.0.2.1"},
+		{"AAAA", "id-aaaa", "2001:db8::1"},
+		{"CNAME", "id-cname", "other.example.net"},
+		{"TXT", "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.recordType, func(t *testing.T) {
+			id, value, err := lookup(tt.recordType)
+			if tt.wantId == "" {
+				if err == nil && id != "" {
+					t.Errorf("lookup() = %q, %q, want no record", id, value)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("lookup() error = %v", err)
+			}
+			if id != tt.wantId || value != tt.wantValue {
+				t.Errorf("lookup() = %q, %q, want %q, %q", id, value, tt.wantId, tt.wantValue)
+			}
+		})
+	}
+}
+
+// checkRunUpdatesEachRecord 同一次运行中 A 和 AAAA 都需要更新，各自使用自己的记录 ID
+// updated 返回替身收到的更新，记录 ID 对应新的值
+func checkRunUpdatesEachRecord(t *testing.T, run AsyncServiceCallback, updated func() map[string]string) {
+	t.Helper()
+	msg, errs := run(enable{IPv4: true, IPv6: true}, "192.0.2.2", "2001:db8:0:0:0:0:0:2")
+	if len(errs) != 0 || len(msg) != 2 {
+		t.Fatalf("Run() msg = %v, errs = %v", msg, errs)
+	}
+	want := map[string]string{"id-a": "192.0.2.2", "id-aaaa": "2001:db8:0:0:0:0:0:2"}
+	if got := updated(); !reflect.DeepEqual(got, want) {
+		t.Errorf("updated = %v, want %v", got, want)
+	}
+}
+
+// checkRunError 接口返回的错误作为 Run 的错误返回，want 为错误信息中应有的内容
+func checkRunError(t *testing.T, run AsyncServiceCallback, want string) {
+	t.Helper()
+	_, errs := run(enable{IPv4: true}, "192.0.2.2", "")
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), want) {
+		t.Fatalf("Run() errors = %v, want %q", errs, want)
+	}
+}
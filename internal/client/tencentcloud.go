@@ -0,0 +1,286 @@
+package client
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const TencentCloudConfFileName = "tencentcloud.json"
+
+const (
+	tencentCloudService   = "dnspod"
+	tencentCloudVersion   = "2021-03-23"
+	tencentCloudAlgorithm = "TC3-HMAC-SHA256"
+	// tencentCloudNoRecord 查询不到记录时返回的错误码，此时新建记录
+	tencentCloudNoRecord = "ResourceNotFound.NoDataOfRecord"
+)
+
+type tencentCloudConf struct {
+	SecretId  string    `json:"secret_id"`
+	SecretKey string    `json:"secret_key"`
+	Endpoint  string    `json:"endpoint"`
+	Domain    string    `json:"domain"`
+	SubDomain subdomain `json:"sub_domain"`
+	TTL       uint64    `json:"ttl"`
+}
+
+type tencentCloudRecord struct {
+	RecordId uint64 `json:"RecordId"`
+	Name     string `json:"Name"`
+	Type     string `json:"Type"`
+	Value    string `json:"Value"`
+	Line     string `json:"Line"`
+	LineId   string `json:"LineId"`
+}
+
+type tencentCloudResponse struct {
+	Response struct {
+		Error *struct {
+			Code    string `json:"Code"`
+			Message string `json:"Message"`
+		} `json:"Error"`
+		RequestId  string               `json:"RequestId"`
+		RecordList []tencentCloudRecord `json:"RecordList"`
+	} `json:"Response"`
+}
+
+// InitConf 已有 dnspod.json 时沿用其中的 domain 和 sub_domain
+func (tcc *tencentCloudConf) InitConf() (msg string, err error) {
+	*tcc = tencentCloudConf{}
+	tcc.SecretId = i18n.T(i18n.MsgPlaceholderGetAt, "https://console.cloud.tencent.com/cam/capi")
+	tcc.SecretKey = tcc.SecretId
+	tcc.Endpoint = "https://dnspod.tencentcloudapi.com"
+	tcc.Domain = "example.com"
+	tcc.SubDomain.A = i18n.T(i18n.MsgPlaceholderSubDomainA)
+	tcc.SubDomain.AAAA = i18n.T(i18n.MsgPlaceholderSubDomainAAAA)
+	tcc.TTL = 600
+	migrated := false
+	legacy := dnspodConf{}
+	if _, statErr := os.Stat(ConfDirectoryName + "/" + DNSPodConfFileName); statErr == nil {
+		if common.LoadAndUnmarshal(ConfDirectoryName+"/"+DNSPodConfFileName, &legacy) == nil && legacy.Domain != "" {
+			tcc.Domain = legacy.Domain
+			tcc.SubDomain = legacy.SubDomain
+			migrated = true
+		}
+	}
+	err = common.MarshalAndSave(tcc, ConfDirectoryName+"/"+TencentCloudConfFileName)
+	msg = i18n.T(i18n.MsgInitConf, ConfDirectoryName+"/"+TencentCloudConfFileName)
+	if migrated {
+		msg += "\n" + i18n.T(i18n.MsgTencentCloudMigrated, ConfDirectoryName+"/"+DNSPodConfFileName)
+	}
+	return
+}
+
+func (tcc *tencentCloudConf) LoadConf() (err error) {
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+TencentCloudConfFileName, &tcc)
+	if err != nil {
+		return
+	}
+	if tcc.SecretId == "" || tcc.SecretKey == "" || tcc.Domain == "" || (tcc.SubDomain.A == "" && tcc.SubDomain.AAAA == "") {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+TencentCloudConfFileName, "secret_id, secret_key, domain, sub_domain")
+		return
+	}
+	if tcc.Endpoint == "" {
+		tcc.Endpoint = "https://dnspod.tencentcloudapi.com"
+	}
+	if _, err = url.Parse(tcc.Endpoint); err != nil {
+		err = i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+TencentCloudConfFileName, "endpoint")
+		return
+	}
+	tcc.Endpoint = strings.TrimSuffix(tcc.Endpoint, "/")
+	return
+}
+
+func (tcc tencentCloudConf) Run(enabled enable, ipv4, ipv6 string) (msg []string, errs []error) {
+	if enabled.IPv4 && tcc.SubDomain.A != "" {
+		msgRow, err := tcc.update(tcc.SubDomain.A, "A", ipv4)
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	if enabled.IPv6 && tcc.SubDomain.AAAA != "" {
+		msgRow, err := tcc.update(tcc.SubDomain.AAAA, "AAAA", ipv6)
+		if err != nil {
+			errs = append(errs, err)
+		} else if msgRow != "" {
+			msg = append(msg, msgRow)
+		}
+	}
+	return
+}
+
+// update 查询到的记录在两次回调之间共享，记录不存在时新建
+func (tcc tencentCloudConf) update(subDomain, recordType, ipAddr string) (msg string, err error) {
+	var record *tencentCloudRecord
+	return updateRecord("TencentCloud", subDomain+"."+tcc.Domain, recordType, ipAddr,
+		func() (recordIP string, err error) {
+			record, err = tcc.getParseRecord(subDomain, recordType)
+			if record != nil {
+				recordIP = record.Value
+			}
+			return
+		},
+		func() error {
+			if record == nil {
+				return tcc.createRecord(ipAddr, recordType, subDomain)
+			}
+			return tcc.updateParseRecord(ipAddr, *record)
+		})
+}
+
+func (tcc tencentCloudConf) getParseRecord(subDomain, recordType string) (record *tencentCloudRecord, err error) {
+	resp, err := tcc.call("DescribeRecordList", map[string]any{
+		"Domain":     tcc.Domain,
+		"Subdomain":  subDomain,
+		"RecordType": recordType,
+	})
+	if err != nil {
+		var apiErr *i18n.Error
+		if errors.As(err, &apiErr) && apiErr.ID == i18n.MsgTencentCloudAPI && apiErr.Args[0] == tencentCloudNoRecord {
+			err = nil
+		}
+		return
+	}
+	for i := range resp.Response.RecordList {
+		// Subdomain 参数是模糊匹配，需要再比较一次
+		if resp.Response.RecordList[i].Name == subDomain && resp.Response.RecordList[i].Type == recordType {
+			record = &resp.Response.RecordList[i]
+			break
+		}
+	}
+	return
+}
+
+func (tcc tencentCloudConf) updateParseRecord(ipAddr string, record tencentCloudRecord) (err error) {
+	params := map[string]any{
+		"Domain":     tcc.Domain,
+		"RecordId":   record.RecordId,
+		"SubDomain":  record.Name,
+		"RecordType": record.Type,
+		"RecordLine": record.Line,
+		"Value":      ipAddr,
+	}
+	if record.LineId != "" {
+		params["RecordLineId"] = record.LineId
+	}
+	if tcc.TTL != 0 {
+		params["TTL"] = tcc.TTL
+	}
+	_, err = tcc.call("ModifyRecord", params)
+	return
+}
+
+func (tcc tencentCloudConf) createRecord(ipAddr, recordType, subDomain string) (err error) {
+	params := map[string]any{
+		"Domain":     tcc.Domain,
+		"SubDomain":  subDomain,
+		"RecordType": recordType,
+		"RecordLine": "默认",
+		"Value":      ipAddr,
+	}
+	if tcc.TTL != 0 {
+		params["TTL"] = tcc.TTL
+	}
+	_, err = tcc.call("CreateRecord", params)
+	return
+}
+
+// call 发送签名后的 API 3.0 请求，Response.Error 不为空时返回 MsgTencentCloudAPI 错误
+func (tcc tencentCloudConf) call(action string, params map[string]any) (resp tencentCloudResponse, err error) {
+	payload, err := json.Marshal(params)
+	if err != nil {
+		return
+	}
+	endpoint, err := url.Parse(tcc.Endpoint)
+	if err != nil {
+		return
+	}
+	req, err := http.NewRequest("POST", tcc.Endpoint+"/", bytes.NewReader(payload))
+	if err != nil {
+		return
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Action", action)
+	req.Header.Set("X-TC-Version", tencentCloudVersion)
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Authorization", tencentCloudAuthorization(tcc.SecretId, tcc.SecretKey, endpoint.Host, payload, timestamp))
+	req.Header.Set("User-Agent", RunningName+"/"+common.LocalVersion+" ("+common.ProjectUrl+")")
+	if i18n.Locale() == i18n.En {
+		req.Header.Set("X-TC-Language", "en-US")
+	} else {
+		req.Header.Set("X-TC-Language", "zh-CN")
+	}
+
+	httpClient := &http.Client{
+		Timeout:   30 * time.Second,
+		Transport: &http.Transport{DisableKeepAlives: true},
+	}
+	httpResp, err := httpClient.Do(req)
+	if err != nil {
+		return
+	}
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil {
+			err = t
+		}
+	}(httpResp.Body)
+	recvJson, err := io.ReadAll(httpResp.Body)
+	if err != nil {
+		return
+	}
+	err = json.Unmarshal(recvJson, &resp)
+	if err != nil {
+		return
+	}
+	if resp.Response.Error != nil {
+		err = i18n.NewError(i18n.MsgTencentCloudAPI, resp.Response.Error.Code, resp.Response.Error.Message, resp.Response.RequestId)
+	}
+	return
+}
+
+// tencentCloudAuthorization 按 TC3-HMAC-SHA256 计算 Authorization 请求头
+// 签名的请求头只有 content-type 和 host，与 call 中设置的值一致
+func tencentCloudAuthorization(secretId, secretKey, host string, payload []byte, timestamp int64) string {
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	signedHeaders := "content-type;host"
+	canonicalRequest := "POST\n/\n\n" +
+		"content-type:application/json; charset=utf-8\nhost:" + host + "\n\n" +
+		signedHeaders + "\n" + sha256Hex(payload)
+	credentialScope := date + "/" + tencentCloudService + "/tc3_request"
+	stringToSign := tencentCloudAlgorithm + "\n" + strconv.FormatInt(timestamp, 10) + "\n" +
+		credentialScope + "\n" + sha256Hex([]byte(canonicalRequest))
+	secretDate := hmacSHA256([]byte("TC3"+secretKey), date)
+	secretService := hmacSHA256(secretDate, tencentCloudService)
+	secretSigning := hmacSHA256(secretService, "tc3_request")
+	signature := hex.EncodeToString(hmacSHA256(secretSigning, stringToSign))
+	return tencentCloudAlgorithm + " Credential=" + secretId + "/" + credentialScope +
+		", SignedHeaders=" + signedHeaders + ", Signature=" + signature
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
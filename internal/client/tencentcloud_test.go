@@ -0,0 +1,231 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 腾讯云替身接受的密钥
+const (
+	tencentCloudTestId  = "AKIDTEST"
+	tencentCloudTestKey = "SKTEST"
+)
+
+// tencentCloudTestIds mixedRecords 的记录 ID 对应的数字 ID
+var tencentCloudTestIds = map[string]uint64{"id-cname": 100, "id-aaaa": 101, "id-a": 102}
+
+// newTencentCloudStub API 3.0 的本地替身，按 TC3-HMAC-SHA256 重新计算签名，不一致时返回 AuthFailure.SignatureFailure
+// DescribeRecordList 与真实接口一样按子域名模糊匹配，不按类型过滤
+func newTencentCloudStub(t *testing.T) (*apiStub, tencentCloudConf) {
+	t.Helper()
+	records := mixedRecords("home")
+	stub, server := newAPIStub(t, func(req stubRequest) stubResponse {
+		fail := func(code, message string) stubResponse {
+			return stubResponse{Body: map[string]any{"Response": map[string]any{
+				"Error":     map[string]string{"Code": code, "Message": message},
+				"RequestId": "req-" + code,
+			}}}
+		}
+		if msg := verifyTencentCloud(req, tencentCloudTestId, tencentCloudTestKey); msg != "" {
+			return fail("AuthFailure.SignatureFailure", msg)
+		}
+		if req.Header.Get("X-TC-Version") != tencentCloudVersion {
+			return fail("InvalidParameter", "version")
+		}
+		var params map[string]any
+		if err := json.Unmarshal(req.Body, &params); err != nil || params["Domain"] != "example.com" {
+			return fail("InvalidParameter", "Domain")
+		}
+		switch req.Header.Get("X-TC-Action") {
+		case "DescribeRecordList":
+			var list []tencentCloudRecord
+			for _, record := range records {
+				if strings.Contains(record.Name, params["Subdomain"].(string)) {
+					list = append(list, tencentCloudRecord{RecordId: tencentCloudTestIds[record.Id], Name: record.Name, Type: record.Type,
+						Value: record.Value, Line: "默认", LineId: "0"})
+				}
+			}
+			if len(list) == 0 {
+				return fail(tencentCloudNoRecord, "记录列表为空。")
+			}
+			return stubResponse{Body: map[string]any{"Response": map[string]any{"RecordList": list, "RequestId": "req-list"}}}
+		case "ModifyRecord", "CreateRecord":
+			return stubResponse{Body: `{"Response": {"RecordId": 200, "RequestId": "req-write"}}`}
+		}
+		return fail("InvalidAction", req.Header.Get("X-TC-Action"))
+	})
+	tcc := tencentCloudConf{
+		SecretId:  tencentCloudTestId,
+		SecretKey: tencentCloudTestKey,
+		Endpoint:  server.URL,
+		Domain:    "example.com",
+		SubDomain: subdomain{A: "home", AAAA: "home"},
+		TTL:       600,
+	}
+	return stub, tcc
+}
+
+// verifyTencentCloud 独立于 tencentCloudAuthorization 按文档重新计算签名，返回空字符串表示通过
+func verifyTencentCloud(req stubRequest, secretId, secretKey string) string {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "TC3-HMAC-SHA256 ") {
+		return "missing signature"
+	}
+	params := make(map[string]string)
+	for _, part := range strings.Split(strings.TrimPrefix(auth, "TC3-HMAC-SHA256 "), ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		params[key] = value
+	}
+	credential := strings.Split(params["Credential"], "/")
+	if len(credential) != 4 || credential[0] != secretId || credential[2] != "dnspod" || credential[3] != "tc3_request" {
+		return "bad credential " + params["Credential"]
+	}
+	timestamp, err := strconv.ParseInt(req.Header.Get("X-TC-Timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > 5*time.Minute || time.Until(time.Unix(timestamp, 0)) > 5*time.Minute {
+		return "bad X-TC-Timestamp"
+	}
+	date := time.Unix(timestamp, 0).UTC().Format("2006-01-02")
+	if credential[1] != date {
+		return "credential date does not match timestamp"
+	}
+	var canonicalHeaders strings.Builder
+	for _, name := range strings.Split(params["SignedHeaders"], ";") {
+		value := req.Header.Get(name)
+		if name == "host" {
+			value = req.Host
+		}
+		canonicalHeaders.WriteString(name + ":" + strings.ToLower(strings.TrimSpace(value)) + "\n")
+	}
+	if !strings.Contains(params["SignedHeaders"], "content-type") || !strings.Contains(params["SignedHeaders"], "host") {
+		return "content-type and host must be signed"
+	}
+	path := req.URL.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	payloadHash := sha256.Sum256(req.Body)
+	canonicalRequest := req.Method + "\n" + path + "\n" + req.URL.RawQuery + "\n" +
+		canonicalHeaders.String() + "\n" + params["SignedHeaders"] + "\n" + hex.EncodeToString(payloadHash[:])
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "TC3-HMAC-SHA256\n" + req.Header.Get("X-TC-Timestamp") + "\n" +
+		date + "/dnspod/tc3_request\n" + hex.EncodeToString(requestHash[:])
+	sign := func(key []byte, data string) []byte {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(data))
+		return mac.Sum(nil)
+	}
+	signingKey := sign(sign(sign([]byte("TC3"+secretKey), date), "dnspod"), "tc3_request")
+	if !hmac.Equal([]byte(hex.EncodeToString(sign(signingKey, stringToSign))), []byte(params["Signature"])) {
+		return "signature mismatch"
+	}
+	return ""
+}
+
+// tencentCloudWrites 返回替身收到的 ModifyRecord 和 CreateRecord 请求参数
+func tencentCloudWrites(t *testing.T, stub *apiStub) (writes []map[string]any) {
+	t.Helper()
+	for _, req := range stub.received("POST", "/") {
+		action := req.Header.Get("X-TC-Action")
+		if action != "ModifyRecord" && action != "CreateRecord" {
+			continue
+		}
+		params := map[string]any{"Action": action}
+		if err := json.Unmarshal(req.Body, &params); err != nil {
+			t.Fatal(err)
+		}
+		writes = append(writes, params)
+	}
+	return
+}
+
+func TestTencentCloudRecordSelection(t *testing.T) {
+	stub, tcc := newTencentCloudStub(t)
+	checkRecordSelection(t, func(recordType string) (string, string, error) {
+		record, err := tcc.getParseRecord("home", recordType)
+		if record == nil {
+			return "", "", err
+		}
+		for id, number := range tencentCloudTestIds {
+			if number == record.RecordId {
+				return id, record.Value, err
+			}
+		}
+		return "", "", err
+	})
+	checkRunUpdatesEachRecord(t, tcc.Run, func() map[string]string {
+		updated := make(map[string]string)
+		for _, params := range tencentCloudWrites(t, stub) {
+			for id, number := range tencentCloudTestIds {
+				if params["Action"] == "ModifyRecord" && params["RecordId"] == float64(number) {
+					updated[id] = params["Value"].(string)
+				}
+			}
+			// 更新时保留原来的线路和配置的 TTL
+			if params["RecordLine"] != "默认" || params["RecordLineId"] != "0" || params["TTL"] != float64(600) {
+				t.Errorf("ModifyRecord params = %v", params)
+			}
+		}
+		return updated
+	})
+}
+
+func TestTencentCloudCreateMissingRecord(t *testing.T) {
+	stub, tcc := newTencentCloudStub(t)
+	tcc.SubDomain = subdomain{A: "new"}
+	msg, errs := tcc.Run(enable{IPv4: true}, "192.0.2.2", "")
+	if len(errs) != 0 || len(msg) != 1 {
+		t.Fatalf("Run() msg = %v, errs = %v", msg, errs)
+	}
+	writes := tencentCloudWrites(t, stub)
+	if len(writes) != 1 || writes[0]["Action"] != "CreateRecord" || writes[0]["SubDomain"] != "new" ||
+		writes[0]["RecordType"] != "A" || writes[0]["Value"] != "192.0.2.2" || writes[0]["RecordLine"] != "默认" {
+		t.Errorf("writes = %v", writes)
+	}
+}
+
+func TestTencentCloudAPIError(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(tcc *tencentCloudConf)
+		want   string
+	}{
+		{"bad signature", func(tcc *tencentCloudConf) { tcc.SecretKey = "wrong" }, "AuthFailure.SignatureFailure"},
+		{"unknown secret id", func(tcc *tencentCloudConf) { tcc.SecretId = "AKIDOTHER" }, "req-AuthFailure.SignatureFailure"},
+		{"bad domain", func(tcc *tencentCloudConf) { tcc.Domain = "example.net" }, "InvalidParameter"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			stub, tcc := newTencentCloudStub(t)
+			tt.modify(&tcc)
+			checkRunError(t, tcc.Run, tt.want)
+			if writes := tencentCloudWrites(t, stub); len(writes) != 0 {
+				t.Errorf("writes = %v", writes)
+			}
+		})
+	}
+}
+
+func TestTencentCloudAuthorizationVerifies(t *testing.T) {
+	payload := []byte(`{"Domain":"example.com","Subdomain":"home","RecordType":"A"}`)
+	timestamp := time.Now().Unix()
+	header := make(map[string][]string)
+	req := stubRequest{Method: "POST", Host: "dnspod.tencentcloudapi.com", Body: payload, Header: header}
+	req.URL, _ = req.URL.Parse("https://dnspod.tencentcloudapi.com/")
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("X-TC-Timestamp", strconv.FormatInt(timestamp, 10))
+	req.Header.Set("Authorization", tencentCloudAuthorization("AKID", "SK", req.Host, payload, timestamp))
+	if msg := verifyTencentCloud(req, "AKID", "SK"); msg != "" {
+		t.Fatalf("verify() = %s", msg)
+	}
+	// 修改请求体后签名失效
+	req.Body = []byte(`{"Domain":"example.org"}`)
+	if msg := verifyTencentCloud(req, "AKID", "SK"); msg != "signature mismatch" {
+		t.Errorf("verify() with a modified body = %q", msg)
+	}
+}
@@ -1,6 +1,8 @@
 package common
 
 import (
+	"bytes"
+	"ddns-watchdog/internal/i18n"
 	"encoding/json"
 	"fmt"
 	"os"
@@ -71,11 +73,16 @@ func MarshalAndSave(content any, filePath string) (err error) {
 	if err != nil {
 		return
 	}
-	jsonContent, err := json.MarshalIndent(content, "", "\t")
+	// 不转义 & < >，模板中的 URL 保持原样便于编辑
+	buf := bytes.Buffer{}
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "\t")
+	err = encoder.Encode(content)
 	if err != nil {
 		return
 	}
-	err = os.WriteFile(filePath, jsonContent, 0600)
+	err = os.WriteFile(filePath, bytes.TrimSuffix(buf.Bytes(), []byte("\n")), 0600)
 	if err != nil {
 		return
 	}
@@ -128,14 +135,15 @@ func DecodeIPv6(srcIP string) (dstIP string) {
 	return
 }
 
+// VersionTips 版本信息是 -v 的输出，直接打印到标准输出，不受日志级别和日志文件影响
 func VersionTips(LatestVersion string) {
-	fmt.Println("当前版本 ", LocalVersion)
-	fmt.Println("最新版本 ", LatestVersion)
-	fmt.Println("项目地址 ", ProjectUrl)
+	fmt.Println(i18n.T(i18n.MsgVersionLocal), LocalVersion)
+	fmt.Println(i18n.T(i18n.MsgVersionLatest), LatestVersion)
+	fmt.Println(i18n.T(i18n.MsgVersionProjectUrl), ProjectUrl)
 	switch {
 	case strings.Contains(LatestVersion, "N/A"):
-		fmt.Println("\n" + LatestVersion + "\n需要手动检查更新，请前往 项目地址 查看")
+		fmt.Println("\n" + LatestVersion + "\n" + i18n.T(i18n.MsgVersionCheckManually))
 	case CompareVersionString(LatestVersion, LocalVersion):
-		fmt.Println("\n发现新版本，请前往 项目地址 下载")
+		fmt.Println("\n" + i18n.T(i18n.MsgVersionNewAvailable))
 	}
 }
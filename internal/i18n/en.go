@@ -0,0 +1,154 @@
+package i18n
+
+var en = map[MessageID]string{
+	// 启动参数
+	MsgFlagInstall:         "Install the service and exit",
+	MsgFlagUninstall:       "Uninstall the service and exit",
+	MsgFlagEnforcement:     "Force checking DNS records",
+	MsgFlagVersion:         "Show the current version, check for updates and exit",
+	MsgFlagInitClient:      "Initialize the selected config files and exit, codes can be combined (e.g. 01)",
+	MsgFlagInitServer:      "Initialize the config file and exit",
+	MsgFlagConfPath:        "Config directory (quote it if it contains spaces)",
+	MsgFlagNetworkCard:     "Print network interface addresses and exit",
+	MsgFlagQuiet:           "Only log warnings and errors",
+	MsgFlagVerbose:         "Log debug messages",
+	MsgFlagLang:            "Language (zh-CN or en), overrides the config file and environment",
+	MsgFlagHistoryEvent:    "Only show entries of this type (%v or %v)",
+	MsgFlagHistoryProvider: "Only show entries of this provider (e.g. DNSPod)",
+	MsgFlagHistoryRecord:   "Only show entries of this record (e.g. www.example.com)",
+	MsgFlagHistorySince:    "Start time (RFC3339, 2006-01-02 or relative like 24h 7d)",
+	MsgFlagHistoryUntil:    "End time (same formats as -since)",
+	MsgFlagHistoryStats:    "Print statistics",
+	MsgFlagHistoryJSON:     "Print as JSON Lines",
+	MsgUnknownCommand:      "Unknown command %v, global flags go before the command, e.g. -c ./conf history",
+
+	// 配置文件
+	MsgInitConf:             "Initialized %v",
+	MsgInitNothing:          "Nothing to initialize, unknown code",
+	MsgConfCheckFields:      "Please open %v, check %v and restart",
+	MsgProviderUnknown:      "unknown provider %v",
+	MsgHostNotInZone:        "%v is not within domain %v",
+	MsgConfEnableIPType:     "Please open the client config file %v, enable the IP types you need and restart",
+	MsgConfEnableService:    "Please open the client config file %v, enable the services you need and restart",
+	MsgConfEnableNotifier:   "Please open %v, enable at least one notification channel and restart",
+	MsgConfUnsupportedEvent: "Please open %v and check events, unsupported event %v",
+	MsgConfCheckCycle:       "Please set check_cycle_minutes in %v",
+	MsgLogLevelUnsupported:  "Unsupported log level %v",
+	MsgLogFormatUnsupported: "Unsupported log format %v",
+
+	// 版本
+	MsgVersionNetworkError:  "please check the network connection",
+	MsgVersionBadPacket:     "malformed response",
+	MsgVersionMissing:       "no version information",
+	MsgVersionInfo:          "Version information",
+	MsgVersionCheckManually: "Please check for updates manually at the project url",
+	MsgVersionNewAvailable:  "A new version is available, download it from the project url",
+	MsgVersionLocal:         "Local version",
+	MsgVersionLatest:        "Latest version",
+	MsgVersionProjectUrl:    "Project URL",
+	MsgRootServer:           "This is the root server",
+
+	// 系统服务
+	MsgWindowsInstallUnsupported: "Installing as a service is not supported on windows",
+	MsgInstalled:                 "The %v service can now be managed with systemctl",
+	MsgUninstalled:               "Service uninstalled",
+	MsgUninstallHint:             "To remove everything, delete %v and %v",
+
+	// IP 地址
+	MsgSelectNetworkCard:   "Please open %v, pick an interface and fill it into network_card of %v",
+	MsgNetworkCardNotFound: "%v uses a network interface that does not exist",
+	MsgBadIPv4:             "Malformed IPv4 address, got %v",
+	MsgBadIPv6:             "Malformed IPv6 address, got %v",
+	MsgAPIUnauthorized:     "%v rejected the request, please check token and client certificate in api_url",
+	MsgGotIP:               "Got IP",
+	MsgIPChanged:           "IP address changed",
+
+	// 解析记录
+	MsgRecordNotFound:          "%v: record %v does not exist",
+	MsgRecordUnchanged:         "Record is up to date",
+	MsgRecordUpdated:           "%v: %v updated to %v",
+	MsgUpdateAborted:           "%v: update of %v aborted: %v",
+	MsgCloudflareAuth:          "Cloudflare: authentication seems to have failed",
+	MsgRFC2136Rcode:            "RFC2136: %v: server returned %v",
+	MsgRFC2136Exchange:         "RFC2136: cannot talk to %v: %v",
+	MsgDyndns2Response:         "Dyndns2: %v: server returned %v, %v",
+	MsgDyndns2Blocked:          "Dyndns2: %v previously returned %v, updates are stopped to avoid being banned, check the config and restart",
+	MsgDyndns2Backoff:          "Dyndns2: %v: server is unavailable, retrying after %v",
+	MsgDyndns2BadAuth:          "wrong username or password",
+	MsgDyndns2NotFQDN:          "the hostname is not a fully qualified domain name",
+	MsgDyndns2NoHost:           "the hostname does not exist or does not belong to this account",
+	MsgDyndns2NumHost:          "too many hostnames in one update",
+	MsgDyndns2Abuse:            "the hostname is blocked for abuse",
+	MsgDyndns2BadAgent:         "the User-Agent was rejected",
+	MsgDyndns2Donator:          "this feature requires a paid account",
+	MsgDyndns2BadSys:           "the system parameter is invalid",
+	MsgDyndns2ServerError:      "server failure, retrying after %v",
+	MsgDyndns2Unknown:          "unrecognized response",
+	MsgTencentCloudAPI:         "TencentCloud: %v: %v (RequestId: %v)",
+	MsgTencentCloudMigrated:    "Migrated domain and sub_domain from %v, please fill in secret_id and secret_key",
+	MsgDNSPodDeprecated:        "The legacy DNSPod API (dnsapi.cn) is being phased out, consider running -i 8 to create %v and switching to Tencent Cloud API 3.0",
+	MsgRoute53API:              "Route53: %v: %v (RequestId: %v)",
+	MsgRoute53ZoneNotFound:     "Route53: hosted zone %v not found",
+	MsgRoute53WaitTimeout:      "Route53: change %v was not INSYNC within %v seconds",
+	MsgCustomHTTPStatus:        "CustomHTTP: %v request failed %v %v",
+	MsgCustomHTTPMismatch:      "CustomHTTP: %v response does not meet the success condition (%v)",
+	MsgCustomHTTPNoValue:       "CustomHTTP: nothing in the response for %v matches %v",
+	MsgLocalFileReload:         "LocalFile: reload command failed after writing %v: %v",
+	MsgLocalFileBlock:          "LocalFile: %v is missing the %v marker, please check the file",
+	MsgLocalFileRename:         "LocalFile: cannot replace %v, writing in place instead",
+	MsgPowerDNSAPI:             "PowerDNS: %v %v",
+	MsgHuaweiCloudAPI:          "HuaweiCloud: %v: %v (X-Request-Id: %v)",
+	MsgHuaweiCloudZoneNotFound: "HuaweiCloud: public zone %v not found",
+
+	// 钩子
+	MsgHookFailed:     "hook %v: %v",
+	MsgCommandTimeout: "%v: timed out (%v)",
+
+	// 通知
+	MsgProviderRecovered: "%v: recovered",
+	MsgNotifyEvent:       "Event",
+	MsgNotifyTime:        "Time",
+	MsgNotifyProvider:    "Provider",
+	MsgNotifyMessage:     "Message",
+	MsgNotifySuppressed:  "Notifications suppressed by rate limit",
+	MsgWebhookStatus:     "Webhook: %v returned %v",
+
+	// 更新历史
+	MsgHistoryBadLine:       "%v line %v is malformed: %v",
+	MsgHistoryBadTime:       "Unrecognized time %v, use RFC3339, 2006-01-02 or relative like 24h 7d",
+	MsgHistoryNone:          "(none)",
+	MsgHistoryIPChanged:     "IP changed",
+	MsgHistoryUpdate:        "Update",
+	MsgHistoryStatsChanges:  "%v changed %v times, first seen %v, last %v",
+	MsgHistoryStatsInterval: "average interval %v, shortest %v, longest %v",
+	MsgHistoryStatsProvider: "%v succeeded %v, failed %v, aborted %v, unchanged %v",
+
+	// 服务端
+	MsgServerRespond:          "Responded",
+	MsgServerFamilyMismatch:   "This connection uses %v",
+	MsgServerListen:           "Listening",
+	MsgCIDRInvalid:            "%[2]v in %[1]v of server.json is not a valid IP or CIDR",
+	MsgProxyProtocolNoTrusted: "trusted_proxies must be set when proxy_protocol is enabled",
+	MsgACMENoTLSListener:      "tls.acme requires at least one listener with tls enabled (tls.enable when listeners is empty)",
+	MsgProxyProtocolHeader:    "invalid PROXY protocol header from %v: %v",
+	MsgDDNSEnabled:            "Server-side DDNS enabled",
+	MsgDDNSBadAuth:            "DDNS update request failed authentication",
+	MsgDDNSBadIP:              "%v in myip is not a valid IP",
+	MsgDNSNotEnabled:          "%v uses the built-in DNS, enable dns in server.json",
+	MsgDNSListen:              "Built-in DNS listening",
+	MsgDNSRecordUpdated:       "Built-in DNS: %v updated to %v",
+	MsgLimitDenied:            "Access denied",
+	MsgLimitExceeded:          "Too many requests",
+	MsgTLSLoadFailed:          "failed to load certificate %v: %v",
+	MsgTLSReloadFailed:        "Failed to reload certificates, keeping the current ones",
+	MsgTLSReloaded:            "Certificates reloaded",
+	MsgAuthFailed:             "Request failed authentication",
+
+	// 初始化配置文件的占位内容
+	MsgPlaceholderGetAt:            "Get it at %v",
+	MsgPlaceholderSubDomainA:       "subdomain-of-A-record",
+	MsgPlaceholderSubDomainAAAA:    "subdomain-of-AAAA-record",
+	MsgPlaceholderCloudflareZoneID: "Zone ID at the bottom right of your domain overview page",
+	MsgPlaceholderTSIGSecret:       "Base64 TSIG secret (e.g. the secret generated by tsig-keygen)",
+	MsgPlaceholderPowerDNSKey:      "value of api-key in pdns.conf",
+}
@@ -0,0 +1,143 @@
+package i18n
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+)
+
+const (
+	ZhCN = "zh-CN"
+	En   = "en"
+
+	DefaultLocale = ZhCN
+	// EnvName 优先于 LC_ALL LC_MESSAGES LANG 的环境变量
+	EnvName = "DDNS_WATCHDOG_LANG"
+	// FlagName 启动参数名称，在包初始化时从 os.Args 中读取，以便本地化启动参数的帮助信息
+	FlagName = "lang"
+)
+
+type MessageID string
+
+var (
+	mutex    sync.RWMutex
+	current  = Select(FlagValue(os.Args[1:], FlagName))
+	catalogs = map[string]map[MessageID]string{
+		ZhCN: zhCN,
+		En:   en,
+	}
+)
+
+// Error 带消息编号的错误，Error() 按当前语言输出，errors.Is 按消息编号比较
+type Error struct {
+	ID   MessageID
+	Args []any
+}
+
+func NewError(id MessageID, args ...any) *Error {
+	return &Error{ID: id, Args: args}
+}
+
+func (e *Error) Error() string {
+	return T(e.ID, e.Args...)
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.ID == e.ID
+}
+
+// Unwrap 返回参数中的第一个 error
+func (e *Error) Unwrap() error {
+	for _, arg := range e.Args {
+		if err, ok := arg.(error); ok {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsMessage 判断 err 或其包装的错误是否为指定消息编号
+func IsMessage(err error, id MessageID) bool {
+	return errors.Is(err, &Error{ID: id})
+}
+
+// T 按当前语言格式化消息，找不到时回退到默认语言
+func T(id MessageID, args ...any) string {
+	mutex.RLock()
+	format, ok := catalogs[current][id]
+	mutex.RUnlock()
+	if !ok {
+		format, ok = catalogs[DefaultLocale][id]
+		if !ok {
+			format = string(id)
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+func Locale() string {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	return current
+}
+
+func SetLocale(locale string) {
+	mutex.Lock()
+	current = locale
+	mutex.Unlock()
+}
+
+// Normalize 将 zh_CN.UTF-8 en_US 之类的值转换为支持的语言
+func Normalize(str string) (locale string, ok bool) {
+	str = strings.ToLower(strings.TrimSpace(str))
+	// 去掉 .UTF-8 和 @modifier
+	if i := strings.IndexAny(str, ".@"); i >= 0 {
+		str = str[:i]
+	}
+	switch {
+	case str == "zh" || strings.HasPrefix(str, "zh_") || strings.HasPrefix(str, "zh-"):
+		return ZhCN, true
+	case str == "en" || strings.HasPrefix(str, "en_") || strings.HasPrefix(str, "en-"):
+		return En, true
+	}
+	return "", false
+}
+
+// Select 依次尝试 candidates (启动参数、配置文件)、环境变量，都不支持时使用默认语言
+func Select(candidates ...string) string {
+	for _, name := range []string{EnvName, "LC_ALL", "LC_MESSAGES", "LANG"} {
+		candidates = append(candidates, os.Getenv(name))
+	}
+	for _, value := range candidates {
+		if locale, ok := Normalize(value); ok {
+			return locale
+		}
+	}
+	return DefaultLocale
+}
+
+// FlagValue 在 flag.Parse 之前从参数中找出 -name value 或 -name=value
+func FlagValue(args []string, name string) string {
+	for i, arg := range args {
+		if arg == "--" {
+			break
+		}
+		trimmed := strings.TrimLeft(arg, "-")
+		if len(trimmed) == len(arg) || len(arg)-len(trimmed) > 2 {
+			continue
+		}
+		switch {
+		case trimmed == name && i+1 < len(args):
+			return args[i+1]
+		case strings.HasPrefix(trimmed, name+"="):
+			return strings.TrimPrefix(trimmed, name+"=")
+		}
+	}
+	return ""
+}
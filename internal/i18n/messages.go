@@ -0,0 +1,174 @@
+package i18n
+
+// 启动参数
+const (
+	MsgFlagInstall         MessageID = "flag_install"
+	MsgFlagUninstall       MessageID = "flag_uninstall"
+	MsgFlagEnforcement     MessageID = "flag_enforcement"
+	MsgFlagVersion         MessageID = "flag_version"
+	MsgFlagInitClient      MessageID = "flag_init_client"
+	MsgFlagInitServer      MessageID = "flag_init_server"
+	MsgFlagConfPath        MessageID = "flag_conf_path"
+	MsgFlagNetworkCard     MessageID = "flag_network_card"
+	MsgFlagQuiet           MessageID = "flag_quiet"
+	MsgFlagVerbose         MessageID = "flag_verbose"
+	MsgFlagLang            MessageID = "flag_lang"
+	MsgFlagHistoryEvent    MessageID = "flag_history_event"
+	MsgFlagHistoryProvider MessageID = "flag_history_provider"
+	MsgFlagHistoryRecord   MessageID = "flag_history_record"
+	MsgFlagHistorySince    MessageID = "flag_history_since"
+	MsgFlagHistoryUntil    MessageID = "flag_history_until"
+	MsgFlagHistoryStats    MessageID = "flag_history_stats"
+	MsgFlagHistoryJSON     MessageID = "flag_history_json"
+	MsgUnknownCommand      MessageID = "unknown_command"
+)
+
+// 配置文件
+const (
+	MsgInitConf             MessageID = "init_conf"
+	MsgInitNothing          MessageID = "init_nothing"
+	MsgConfCheckFields      MessageID = "conf_check_fields"
+	MsgProviderUnknown      MessageID = "provider_unknown"
+	MsgHostNotInZone        MessageID = "host_not_in_zone"
+	MsgConfEnableIPType     MessageID = "conf_enable_ip_type"
+	MsgConfEnableService    MessageID = "conf_enable_service"
+	MsgConfEnableNotifier   MessageID = "conf_enable_notifier"
+	MsgConfUnsupportedEvent MessageID = "conf_unsupported_event"
+	MsgConfCheckCycle       MessageID = "conf_check_cycle"
+	MsgLogLevelUnsupported  MessageID = "log_level_unsupported"
+	MsgLogFormatUnsupported MessageID = "log_format_unsupported"
+)
+
+// 版本
+const (
+	MsgVersionNetworkError  MessageID = "version_network_error"
+	MsgVersionBadPacket     MessageID = "version_bad_packet"
+	MsgVersionMissing       MessageID = "version_missing"
+	MsgVersionInfo          MessageID = "version_info"
+	MsgVersionCheckManually MessageID = "version_check_manually"
+	MsgVersionNewAvailable  MessageID = "version_new_available"
+	MsgVersionLocal         MessageID = "version_local"
+	MsgVersionLatest        MessageID = "version_latest"
+	MsgVersionProjectUrl    MessageID = "version_project_url"
+	MsgRootServer           MessageID = "root_server"
+)
+
+// 系统服务
+const (
+	MsgWindowsInstallUnsupported MessageID = "windows_install_unsupported"
+	MsgInstalled                 MessageID = "installed"
+	MsgUninstalled               MessageID = "uninstalled"
+	MsgUninstallHint             MessageID = "uninstall_hint"
+)
+
+// IP 地址
+const (
+	MsgSelectNetworkCard   MessageID = "select_network_card"
+	MsgNetworkCardNotFound MessageID = "network_card_not_found"
+	MsgBadIPv4             MessageID = "bad_ipv4"
+	MsgBadIPv6             MessageID = "bad_ipv6"
+	MsgAPIUnauthorized     MessageID = "api_unauthorized"
+	MsgGotIP               MessageID = "got_ip"
+	MsgIPChanged           MessageID = "ip_changed"
+)
+
+// 解析记录
+const (
+	MsgRecordNotFound          MessageID = "record_not_found"
+	MsgRecordUnchanged         MessageID = "record_unchanged"
+	MsgRecordUpdated           MessageID = "record_updated"
+	MsgUpdateAborted           MessageID = "update_aborted"
+	MsgCloudflareAuth          MessageID = "cloudflare_auth"
+	MsgRFC2136Rcode            MessageID = "rfc2136_rcode"
+	MsgRFC2136Exchange         MessageID = "rfc2136_exchange"
+	MsgDyndns2Response         MessageID = "dyndns2_response"
+	MsgDyndns2Blocked          MessageID = "dyndns2_blocked"
+	MsgDyndns2Backoff          MessageID = "dyndns2_backoff"
+	MsgDyndns2BadAuth          MessageID = "dyndns2_bad_auth"
+	MsgDyndns2NotFQDN          MessageID = "dyndns2_not_fqdn"
+	MsgDyndns2NoHost           MessageID = "dyndns2_no_host"
+	MsgDyndns2NumHost          MessageID = "dyndns2_num_host"
+	MsgDyndns2Abuse            MessageID = "dyndns2_abuse"
+	MsgDyndns2BadAgent         MessageID = "dyndns2_bad_agent"
+	MsgDyndns2Donator          MessageID = "dyndns2_donator"
+	MsgDyndns2BadSys           MessageID = "dyndns2_bad_sys"
+	MsgDyndns2ServerError      MessageID = "dyndns2_server_error"
+	MsgDyndns2Unknown          MessageID = "dyndns2_unknown"
+	MsgTencentCloudAPI         MessageID = "tencent_cloud_api"
+	MsgTencentCloudMigrated    MessageID = "tencent_cloud_migrated"
+	MsgDNSPodDeprecated        MessageID = "dns_pod_deprecated"
+	MsgRoute53API              MessageID = "route53_api"
+	MsgRoute53ZoneNotFound     MessageID = "route53_zone_not_found"
+	MsgRoute53WaitTimeout      MessageID = "route53_wait_timeout"
+	MsgCustomHTTPStatus        MessageID = "custom_http_status"
+	MsgCustomHTTPMismatch      MessageID = "custom_http_mismatch"
+	MsgCustomHTTPNoValue       MessageID = "custom_http_no_value"
+	MsgLocalFileReload         MessageID = "local_file_reload"
+	MsgLocalFileBlock          MessageID = "local_file_block"
+	MsgLocalFileRename         MessageID = "local_file_rename"
+	MsgPowerDNSAPI             MessageID = "power_dnsapi"
+	MsgHuaweiCloudAPI          MessageID = "huawei_cloud_api"
+	MsgHuaweiCloudZoneNotFound MessageID = "huawei_cloud_zone_not_found"
+)
+
+// 钩子
+const (
+	MsgHookFailed     MessageID = "hook_failed"
+	MsgCommandTimeout MessageID = "command_timeout"
+)
+
+// 通知
+const (
+	MsgProviderRecovered MessageID = "provider_recovered"
+	MsgNotifyEvent       MessageID = "notify_event"
+	MsgNotifyTime        MessageID = "notify_time"
+	MsgNotifyProvider    MessageID = "notify_provider"
+	MsgNotifyMessage     MessageID = "notify_message"
+	MsgNotifySuppressed  MessageID = "notify_suppressed"
+	MsgWebhookStatus     MessageID = "webhook_status"
+)
+
+// 更新历史
+const (
+	MsgHistoryBadLine       MessageID = "history_bad_line"
+	MsgHistoryBadTime       MessageID = "history_bad_time"
+	MsgHistoryNone          MessageID = "history_none"
+	MsgHistoryIPChanged     MessageID = "history_ip_changed"
+	MsgHistoryUpdate        MessageID = "history_update"
+	MsgHistoryStatsChanges  MessageID = "history_stats_changes"
+	MsgHistoryStatsInterval MessageID = "history_stats_interval"
+	MsgHistoryStatsProvider MessageID = "history_stats_provider"
+)
+
+// 服务端
+const (
+	MsgServerRespond          MessageID = "server_respond"
+	MsgServerFamilyMismatch   MessageID = "server_family_mismatch"
+	MsgServerListen           MessageID = "server_listen"
+	MsgCIDRInvalid            MessageID = "cidr_invalid"
+	MsgProxyProtocolNoTrusted MessageID = "proxy_protocol_no_trusted"
+	MsgACMENoTLSListener      MessageID = "acme_no_tls_listener"
+	MsgProxyProtocolHeader    MessageID = "proxy_protocol_header"
+	MsgDDNSEnabled            MessageID = "ddns_enabled"
+	MsgDDNSBadAuth            MessageID = "ddns_bad_auth"
+	MsgDDNSBadIP              MessageID = "ddns_bad_ip"
+	MsgDNSNotEnabled          MessageID = "dns_not_enabled"
+	MsgDNSListen              MessageID = "dns_listen"
+	MsgDNSRecordUpdated       MessageID = "dns_record_updated"
+	MsgLimitDenied            MessageID = "limit_denied"
+	MsgLimitExceeded          MessageID = "limit_exceeded"
+	MsgTLSLoadFailed          MessageID = "tls_load_failed"
+	MsgTLSReloadFailed        MessageID = "tls_reload_failed"
+	MsgTLSReloaded            MessageID = "tls_reloaded"
+	MsgAuthFailed             MessageID = "auth_failed"
+)
+
+// 初始化配置文件的占位内容
+const (
+	MsgPlaceholderGetAt            MessageID = "placeholder_get_at"
+	MsgPlaceholderSubDomainA       MessageID = "placeholder_sub_domain_a"
+	MsgPlaceholderSubDomainAAAA    MessageID = "placeholder_sub_domain_aaaa"
+	MsgPlaceholderCloudflareZoneID MessageID = "placeholder_cloudflare_zone_id"
+	MsgPlaceholderTSIGSecret       MessageID = "placeholder_tsig_secret"
+	MsgPlaceholderPowerDNSKey      MessageID = "placeholder_power_dns_key"
+)
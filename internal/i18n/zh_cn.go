@@ -0,0 +1,154 @@
+package i18n
+
+var zhCN = map[MessageID]string{
+	// 启动参数
+	MsgFlagInstall:         "安装服务并退出",
+	MsgFlagUninstall:       "卸载服务并退出",
+	MsgFlagEnforcement:     "强制检查 DNS 解析记录",
+	MsgFlagVersion:         "查看当前版本并检查更新后退出",
+	MsgFlagInitClient:      "有选择地初始化配置文件并退出，可以组合使用 (例 01)",
+	MsgFlagInitServer:      "初始化配置文件并退出",
+	MsgFlagConfPath:        "指定配置文件目录 (目录有空格请放在双引号中间)",
+	MsgFlagNetworkCard:     "输出网卡信息并退出",
+	MsgFlagQuiet:           "只输出警告和错误日志",
+	MsgFlagVerbose:         "输出调试日志",
+	MsgFlagLang:            "指定语言 (zh-CN 或 en)，优先于配置文件和环境变量",
+	MsgFlagHistoryEvent:    "只显示指定类型 (%v 或 %v)",
+	MsgFlagHistoryProvider: "只显示指定服务商 (例 DNSPod)",
+	MsgFlagHistoryRecord:   "只显示指定解析记录 (例 www.example.com)",
+	MsgFlagHistorySince:    "起始时间 (RFC3339、2006-01-02 或 24h 7d 这样的相对时间)",
+	MsgFlagHistoryUntil:    "截止时间 (格式同 -since)",
+	MsgFlagHistoryStats:    "输出统计信息",
+	MsgFlagHistoryJSON:     "以 JSON Lines 格式输出",
+	MsgUnknownCommand:      "未知的命令 %v，全局参数需要放在命令之前，例如 -c ./conf history",
+
+	// 配置文件
+	MsgInitConf:             "初始化 %v",
+	MsgInitNothing:          "你初始化了一个寂寞",
+	MsgConfCheckFields:      "请打开配置文件 %v 检查你的 %v 并重新启动",
+	MsgProviderUnknown:      "未知的服务商 %v",
+	MsgHostNotInZone:        "%v 不属于域名 %v",
+	MsgConfEnableIPType:     "请打开客户端配置文件 %v 启用需要使用的 IP 类型并重新启动",
+	MsgConfEnableService:    "请打开客户端配置文件 %v 启用需要使用的服务并重新启动",
+	MsgConfEnableNotifier:   "请打开配置文件 %v 启用至少一个通知渠道并重新启动",
+	MsgConfUnsupportedEvent: "请打开配置文件 %v 检查你的 events，不支持的事件 %v",
+	MsgConfCheckCycle:       "设置一下 %v 的 check_cycle_minutes 吧",
+	MsgLogLevelUnsupported:  "不支持的日志级别 %v",
+	MsgLogFormatUnsupported: "不支持的日志格式 %v",
+
+	// 版本
+	MsgVersionNetworkError:  "请检查网络连接",
+	MsgVersionBadPacket:     "数据包错误",
+	MsgVersionMissing:       "没有获取到版本信息",
+	MsgVersionInfo:          "版本信息",
+	MsgVersionCheckManually: "需要手动检查更新，请前往 项目地址 查看",
+	MsgVersionNewAvailable:  "发现新版本，请前往 项目地址 下载",
+	MsgVersionLocal:         "当前版本",
+	MsgVersionLatest:        "最新版本",
+	MsgVersionProjectUrl:    "项目地址",
+	MsgRootServer:           "本机是根服务器",
+
+	// 系统服务
+	MsgWindowsInstallUnsupported: "windows 暂不支持安装到系统",
+	MsgInstalled:                 "可以使用 systemctl 控制 %v 服务了",
+	MsgUninstalled:               "卸载服务成功",
+	MsgUninstallHint:             "若要完全删除，请移步到 %v 和 %v 完全删除",
+
+	// IP 地址
+	MsgSelectNetworkCard:   "请打开 %v 选择网卡填入 %v 的 network_card",
+	MsgNetworkCardNotFound: "%v 选择了不存在的网卡",
+	MsgBadIPv4:             "获取到的 IPv4 格式错误，意外获取到了 %v",
+	MsgBadIPv6:             "获取到的 IPv6 格式错误，意外获取到了 %v",
+	MsgAPIUnauthorized:     "%v 拒绝了请求，请检查 api_url 的 token 和客户端证书",
+	MsgGotIP:               "获取到 IP",
+	MsgIPChanged:           "IP 地址发生变化",
+
+	// 解析记录
+	MsgRecordNotFound:          "%v: %v 解析记录不存在",
+	MsgRecordUnchanged:         "解析记录无需更新",
+	MsgRecordUpdated:           "%v: %v 已更新解析记录 %v",
+	MsgUpdateAborted:           "%v: %v 已中止更新: %v",
+	MsgCloudflareAuth:          "Cloudflare: 身份认证似乎有问题",
+	MsgRFC2136Rcode:            "RFC2136: %v 服务器返回了 %v",
+	MsgRFC2136Exchange:         "RFC2136: 无法与 %v 通信: %v",
+	MsgDyndns2Response:         "Dyndns2: %v 服务器返回了 %v，%v",
+	MsgDyndns2Blocked:          "Dyndns2: %v 之前返回了 %v，为避免账户被封禁已停止更新，请检查配置后重新启动",
+	MsgDyndns2Backoff:          "Dyndns2: %v 服务器暂时不可用，将在 %v 后重试",
+	MsgDyndns2BadAuth:          "用户名或密码错误",
+	MsgDyndns2NotFQDN:          "主机名不是完整域名",
+	MsgDyndns2NoHost:           "主机名不存在或不属于该账户",
+	MsgDyndns2NumHost:          "一次更新的主机名过多",
+	MsgDyndns2Abuse:            "主机名因滥用被封禁",
+	MsgDyndns2BadAgent:         "User-Agent 被拒绝",
+	MsgDyndns2Donator:          "该功能需要付费账户",
+	MsgDyndns2BadSys:           "system 参数无效",
+	MsgDyndns2ServerError:      "服务器故障，将在 %v 后重试",
+	MsgDyndns2Unknown:          "无法识别的响应",
+	MsgTencentCloudAPI:         "TencentCloud: %v: %v (RequestId: %v)",
+	MsgTencentCloudMigrated:    "已从 %v 迁移 domain 和 sub_domain，请填入 secret_id 和 secret_key",
+	MsgDNSPodDeprecated:        "DNSPod 旧版 API (dnsapi.cn) 正在停用，建议使用 -i 8 生成 %v 并改用腾讯云 API 3.0",
+	MsgRoute53API:              "Route53: %v: %v (RequestId: %v)",
+	MsgRoute53ZoneNotFound:     "Route53: 没有找到托管区域 %v",
+	MsgRoute53WaitTimeout:      "Route53: 变更 %v 在 %v 秒内没有同步完成",
+	MsgCustomHTTPStatus:        "CustomHTTP: %v 请求失败 %v %v",
+	MsgCustomHTTPMismatch:      "CustomHTTP: %v 响应不符合成功条件 (%v)",
+	MsgCustomHTTPNoValue:       "CustomHTTP: %v 的响应中没有匹配 %v 的内容",
+	MsgLocalFileReload:         "LocalFile: 写入 %v 后执行重新加载命令失败: %v",
+	MsgLocalFileBlock:          "LocalFile: %v 中缺少 %v 标记，请检查文件",
+	MsgLocalFileRename:         "LocalFile: 无法替换 %v，改为直接写入",
+	MsgPowerDNSAPI:             "PowerDNS: %v %v",
+	MsgHuaweiCloudAPI:          "HuaweiCloud: %v: %v (X-Request-Id: %v)",
+	MsgHuaweiCloudZoneNotFound: "HuaweiCloud: 没有找到公网域名 %v",
+
+	// 钩子
+	MsgHookFailed:     "钩子 %v: %v",
+	MsgCommandTimeout: "%v: 执行超时 (%v)",
+
+	// 通知
+	MsgProviderRecovered: "%v: 已恢复正常",
+	MsgNotifyEvent:       "事件",
+	MsgNotifyTime:        "时间",
+	MsgNotifyProvider:    "服务商",
+	MsgNotifyMessage:     "内容",
+	MsgNotifySuppressed:  "期间被频率限制忽略的通知",
+	MsgWebhookStatus:     "Webhook: %v 返回了 %v",
+
+	// 更新历史
+	MsgHistoryBadLine:       "%v 第 %v 行格式错误: %v",
+	MsgHistoryBadTime:       "无法识别的时间 %v，请使用 RFC3339、2006-01-02 或 24h 7d 这样的格式",
+	MsgHistoryNone:          "(无)",
+	MsgHistoryIPChanged:     "IP 变化",
+	MsgHistoryUpdate:        "更新",
+	MsgHistoryStatsChanges:  "%v 变化 %v 次，最早 %v，最近 %v",
+	MsgHistoryStatsInterval: "平均间隔 %v，最短 %v，最长 %v",
+	MsgHistoryStatsProvider: "%v 成功 %v 次，失败 %v 次，中止 %v 次，无需更新 %v 次",
+
+	// 服务端
+	MsgServerRespond:          "响应请求",
+	MsgServerFamilyMismatch:   "当前连接使用 %v",
+	MsgServerListen:           "开始监听",
+	MsgCIDRInvalid:            "server.json 中 %v 的 %v 不是有效的 IP 或 CIDR",
+	MsgProxyProtocolNoTrusted: "启用 proxy_protocol 时必须设置 trusted_proxies",
+	MsgACMENoTLSListener:      "启用 tls.acme 时至少需要一个 tls 为 true 的监听地址 (未设置 listeners 时为 tls.enable)",
+	MsgProxyProtocolHeader:    "%v 的 PROXY 协议头无效: %v",
+	MsgDDNSEnabled:            "服务端 DDNS 已启用",
+	MsgDDNSBadAuth:            "DDNS 更新请求认证失败",
+	MsgDDNSBadIP:              "myip 中的 %v 不是有效的 IP",
+	MsgDNSNotEnabled:          "%v 使用内置 DNS，请在 server.json 中启用 dns",
+	MsgDNSListen:              "内置 DNS 开始监听",
+	MsgDNSRecordUpdated:       "内置 DNS: %v 已更新为 %v",
+	MsgLimitDenied:            "拒绝访问",
+	MsgLimitExceeded:          "请求过于频繁",
+	MsgTLSLoadFailed:          "加载证书 %v 失败: %v",
+	MsgTLSReloadFailed:        "重新加载证书失败，继续使用原来的证书",
+	MsgTLSReloaded:            "已重新加载证书",
+	MsgAuthFailed:             "请求未通过认证",
+
+	// 初始化配置文件的占位内容
+	MsgPlaceholderGetAt:            "在 %v 获取",
+	MsgPlaceholderSubDomainA:       "A记录子域名",
+	MsgPlaceholderSubDomainAAAA:    "AAAA记录子域名",
+	MsgPlaceholderCloudflareZoneID: "在你域名页面的右下角有个区域 ID",
+	MsgPlaceholderTSIGSecret:       "Base64 编码的 TSIG 密钥 (例 tsig-keygen 生成的 secret)",
+	MsgPlaceholderPowerDNSKey:      "pdns.conf 中 api-key 的值",
+}
@@ -0,0 +1,220 @@
+package logger
+
+import (
+	"ddns-watchdog/internal/i18n"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+var (
+	mutex        sync.Mutex
+	output       io.Writer = os.Stderr
+	currentLevel           = LevelInfo
+	currentFmt             = FormatText
+	levelNames             = map[Level]string{
+		LevelDebug: "DEBUG",
+		LevelInfo:  "INFO",
+		LevelWarn:  "WARN",
+		LevelError: "ERROR",
+	}
+)
+
+// Conf 日志配置，file 为相对路径时相对于配置文件目录
+type Conf struct {
+	Level      string `json:"level"`
+	Format     string `json:"format"`
+	File       string `json:"file"`
+	MaxSizeMB  int    `json:"max_size_mb"`
+	MaxBackups int    `json:"max_backups"`
+}
+
+// ParseLevel 将 debug, info, warn, error 转换为日志级别
+func ParseLevel(str string) (level Level, err error) {
+	switch strings.ToLower(str) {
+	case "debug":
+		level = LevelDebug
+	case "", "info":
+		level = LevelInfo
+	case "warn", "warning":
+		level = LevelWarn
+	case "error":
+		level = LevelError
+	default:
+		err = i18n.NewError(i18n.MsgLogLevelUnsupported, str)
+	}
+	return
+}
+
+// Setup 按配置设置日志级别、格式和输出位置
+func Setup(conf Conf, confDirectoryName string) (err error) {
+	level, err := ParseLevel(conf.Level)
+	if err != nil {
+		return
+	}
+	format := strings.ToLower(conf.Format)
+	switch format {
+	case "":
+		format = FormatText
+	case FormatText, FormatJSON:
+	default:
+		err = i18n.NewError(i18n.MsgLogFormatUnsupported, conf.Format)
+		return
+	}
+	var writer io.Writer = os.Stderr
+	if conf.File != "" {
+		path := conf.File
+		if !filepath.IsAbs(path) {
+			path = confDirectoryName + "/" + path
+		}
+		writer, err = newRotateWriter(path, int64(conf.MaxSizeMB)*1024*1024, conf.MaxBackups)
+		if err != nil {
+			return
+		}
+	}
+	mutex.Lock()
+	currentLevel = level
+	currentFmt = format
+	output = writer
+	mutex.Unlock()
+	// 标准库 log 的输出也写到同一位置
+	log.SetOutput(writer)
+	return
+}
+
+// SetLevel 覆盖配置中的日志级别，用于 -q 和 -verbose 启动参数
+func SetLevel(level Level) {
+	mutex.Lock()
+	currentLevel = level
+	mutex.Unlock()
+}
+
+// Debug fields 为交替的键和值，例如 Debug("检查", "provider", "DNSPod")
+func Debug(msg string, fields ...any) {
+	write(LevelDebug, msg, fields)
+}
+
+func Info(msg string, fields ...any) {
+	write(LevelInfo, msg, fields)
+}
+
+func Warn(msg string, fields ...any) {
+	write(LevelWarn, msg, fields)
+}
+
+func Error(msg string, fields ...any) {
+	write(LevelError, msg, fields)
+}
+
+// Fatal 输出错误日志后退出程序
+func Fatal(msg string, fields ...any) {
+	write(LevelError, msg, fields)
+	os.Exit(1)
+}
+
+func write(level Level, msg string, fields []any) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	if level < currentLevel {
+		return
+	}
+	now := time.Now()
+	var line string
+	if currentFmt == FormatJSON {
+		line = formatJSON(now, level, msg, fields)
+	} else {
+		line = formatText(now, level, msg, fields)
+	}
+	_, _ = io.WriteString(output, line+"\n")
+}
+
+func formatText(now time.Time, level Level, msg string, fields []any) string {
+	builder := strings.Builder{}
+	builder.WriteString(now.Format("2006/01/02 15:04:05"))
+	builder.WriteString(" ")
+	builder.WriteString(levelNames[level])
+	builder.WriteString(" ")
+	builder.WriteString(msg)
+	for i := 0; i < len(fields); i += 2 {
+		builder.WriteString(" ")
+		builder.WriteString(fieldKey(fields, i))
+		builder.WriteString("=")
+		value := fmt.Sprint(fieldValue(fields, i))
+		if value == "" || strings.ContainsAny(value, " \t\"=") {
+			value = strconv.Quote(value)
+		}
+		builder.WriteString(value)
+	}
+	return builder.String()
+}
+
+func formatJSON(now time.Time, level Level, msg string, fields []any) string {
+	// 手动拼接以保持 time, level, msg 在前且字段按传入顺序输出
+	builder := strings.Builder{}
+	builder.WriteString(`{"time":`)
+	builder.WriteString(marshalValue(now.Format(time.RFC3339)))
+	builder.WriteString(`,"level":`)
+	builder.WriteString(marshalValue(strings.ToLower(levelNames[level])))
+	builder.WriteString(`,"msg":`)
+	builder.WriteString(marshalValue(msg))
+	for i := 0; i < len(fields); i += 2 {
+		builder.WriteString(",")
+		builder.WriteString(marshalValue(fieldKey(fields, i)))
+		builder.WriteString(":")
+		builder.WriteString(marshalValue(fieldValue(fields, i)))
+	}
+	builder.WriteString("}")
+	return builder.String()
+}
+
+func fieldKey(fields []any, i int) string {
+	if key, ok := fields[i].(string); ok {
+		return key
+	}
+	return fmt.Sprint(fields[i])
+}
+
+func fieldValue(fields []any, i int) any {
+	if i+1 >= len(fields) {
+		return ""
+	}
+	switch value := fields[i+1].(type) {
+	case error:
+		return value.Error()
+	case time.Duration:
+		return value.String()
+	case fmt.Stringer:
+		return value.String()
+	default:
+		return value
+	}
+}
+
+func marshalValue(value any) string {
+	content, err := json.Marshal(value)
+	if err != nil {
+		content, _ = json.Marshal(fmt.Sprint(value))
+	}
+	return string(content)
+}
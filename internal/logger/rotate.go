@@ -0,0 +1,89 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+)
+
+// rotateWriter 按文件大小轮转的日志文件
+// 超过 maxSize 时 file 重命名为 file.1，原有的 file.1 重命名为 file.2，以此类推，最多保留 maxBackups 个
+type rotateWriter struct {
+	mutex      sync.Mutex
+	path       string
+	maxSize    int64
+	maxBackups int
+	file       *os.File
+	size       int64
+}
+
+func newRotateWriter(path string, maxSize int64, maxBackups int) (w *rotateWriter, err error) {
+	err = os.MkdirAll(filepath.Dir(path), 0750)
+	if err != nil {
+		return
+	}
+	w = &rotateWriter{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	err = w.open()
+	return
+}
+
+func (w *rotateWriter) open() (err error) {
+	w.file, err = os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0640)
+	if err != nil {
+		return
+	}
+	info, err := w.file.Stat()
+	if err != nil {
+		return
+	}
+	w.size = info.Size()
+	return
+}
+
+// Write 轮转失败但文件已重新打开时仍然写入，并返回轮转的错误，下一次写入时再次尝试轮转
+func (w *rotateWriter) Write(p []byte) (n int, err error) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	var rotateErr error
+	if w.maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > w.maxSize {
+		rotateErr = w.rotate()
+		if w.file == nil {
+			return 0, rotateErr
+		}
+	}
+	n, err = w.file.Write(p)
+	w.size += int64(n)
+	if err == nil {
+		err = rotateErr
+	}
+	return
+}
+
+// rotate 重命名失败时仍然重新打开原来的文件继续写入，避免之后的日志写入已关闭的文件
+func (w *rotateWriter) rotate() (err error) {
+	err = w.file.Close()
+	if err != nil {
+		return
+	}
+	if w.maxBackups <= 0 {
+		err = os.Remove(w.path)
+	} else {
+		_ = os.Remove(w.path + "." + strconv.Itoa(w.maxBackups))
+		for i := w.maxBackups - 1; i > 0; i-- {
+			_ = os.Rename(w.path+"."+strconv.Itoa(i), w.path+"."+strconv.Itoa(i+1))
+		}
+		err = os.Rename(w.path, w.path+".1")
+	}
+	if err != nil && os.IsNotExist(err) {
+		err = nil
+	}
+	if openErr := w.open(); openErr != nil {
+		return openErr
+	}
+	return
+}
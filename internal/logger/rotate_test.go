@@ -0,0 +1,60 @@
+package logger
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRotateWriter(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	w, err := newRotateWriter(path, 10, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		if _, err = w.Write([]byte(line)); err != nil {
+			t.Fatalf("Write(%q) error = %v", line, err)
+		}
+	}
+	for file, want := range map[string]string{path: "third\n", path + ".1": "second\n", path + ".2": "first\n"} {
+		if got, _ := os.ReadFile(file); string(got) != want {
+			t.Errorf("%s = %q, want %q", file, got, want)
+		}
+	}
+}
+
+func TestRotateWriterRenameFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.log")
+	w, err := newRotateWriter(path, 10, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("first\n")); err != nil {
+		t.Fatal(err)
+	}
+	// server.log.1 是非空目录时重命名失败
+	if err = os.MkdirAll(filepath.Join(path+".1", "keep"), 0750); err != nil {
+		t.Fatal(err)
+	}
+	n, err := w.Write([]byte("second\n"))
+	if err == nil || n != len("second\n") {
+		t.Fatalf("Write() = %d, %v, want rotate error after writing", n, err)
+	}
+	// 之后的日志继续写入原来的文件
+	if _, err = w.Write([]byte("third\n")); err == nil {
+		t.Fatal("Write() error = nil while rename keeps failing")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "first\nsecond\nthird\n" {
+		t.Errorf("%s = %q", path, got)
+	}
+	if err = os.RemoveAll(path + ".1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = w.Write([]byte("fourth\n")); err != nil {
+		t.Fatalf("Write() after recovery error = %v", err)
+	}
+	if got, _ := os.ReadFile(path); string(got) != "fourth\n" {
+		t.Errorf("%s after recovery = %q", path, got)
+	}
+}
@@ -0,0 +1,103 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"ddns-watchdog/internal/i18n"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// ACMEConf 自动申请和续期证书，支持 HTTP-01 和 TLS-ALPN-01 验证
+// http_address 为空时只使用 TLS-ALPN-01，此时 HTTPS 必须监听 443 端口
+// ca_root_file 用于信任 Pebble 等测试服务器的自签名证书
+type ACMEConf struct {
+	Enable          bool     `json:"enable"`
+	DirectoryUrl    string   `json:"directory_url"`
+	Email           string   `json:"email"`
+	Domains         []string `json:"domains"`
+	CacheDir        string   `json:"cache_dir"`
+	HTTPAddress     string   `json:"http_address"`
+	RenewBeforeDays int      `json:"renew_before_days"`
+	CARootFile      string   `json:"ca_root_file"`
+}
+
+// newACMEManager 证书和账户密钥保存在 cache_dir，相对路径相对于配置文件目录
+func newACMEManager(conf ACMEConf) (manager *autocert.Manager, err error) {
+	if len(conf.Domains) == 0 {
+		return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "tls.acme.domains")
+	}
+	if conf.DirectoryUrl == "" {
+		conf.DirectoryUrl = acme.LetsEncryptURL
+	}
+	if conf.CacheDir == "" {
+		conf.CacheDir = "acme"
+	}
+	if conf.RenewBeforeDays <= 0 {
+		conf.RenewBeforeDays = 30
+	}
+	httpClient := &http.Client{Timeout: 60 * time.Second}
+	if conf.CARootFile != "" {
+		pem, readErr := os.ReadFile(confPath(conf.CARootFile))
+		if readErr != nil {
+			return nil, readErr
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "tls.acme.ca_root_file")
+		}
+		httpClient.Transport = &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: &tls.Config{RootCAs: pool},
+		}
+	}
+	domains := make([]string, len(conf.Domains))
+	for i, domain := range conf.Domains {
+		domains[i] = strings.ToLower(strings.TrimSuffix(domain, "."))
+	}
+	return &autocert.Manager{
+		Prompt:      autocert.AcceptTOS,
+		Cache:       autocert.DirCache(confPath(conf.CacheDir)),
+		HostPolicy:  autocert.HostWhitelist(domains...),
+		RenewBefore: time.Duration(conf.RenewBeforeDays) * 24 * time.Hour,
+		Email:       conf.Email,
+		Client: &acme.Client{
+			DirectoryURL: conf.DirectoryUrl,
+			HTTPClient:   httpClient,
+			UserAgent:    RunningName,
+		},
+	}, nil
+}
+
+// HTTPHandler 启用 ACME 时处理 HTTP-01 验证请求，其他请求交给 fallback
+// fallback 为 nil 时 GET 和 HEAD 请求重定向到 HTTPS
+func (manager *CertManager) HTTPHandler(fallback http.Handler) http.Handler {
+	if manager == nil || manager.acme == nil {
+		return fallback
+	}
+	handler := manager.acme.HTTPHandler(fallback)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// 域名白名单不包含端口，http_address 不是 80 端口时 Host 带有端口
+		if host, _, err := net.SplitHostPort(req.Host); err == nil {
+			req = req.Clone(req.Context())
+			req.Host = host
+		}
+		handler.ServeHTTP(w, req)
+	})
+}
+
+// acmeHost 是否由 ACME 提供证书
+func (manager *CertManager) acmeHost(name string) bool {
+	for _, domain := range manager.acmeDomains {
+		if domain == name {
+			return true
+		}
+	}
+	return false
+}
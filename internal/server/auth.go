@@ -0,0 +1,85 @@
+package server
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"ddns-watchdog/internal/i18n"
+	"encoding/hex"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// checkTokens token 不能为空，"sha256:" 后不能为空
+func checkTokens(field string, tokens []string) error {
+	for _, token := range tokens {
+		if token == "" || token == "sha256:" {
+			return i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, field)
+		}
+	}
+	return nil
+}
+
+// tokenAllowed token 为空时返回 false，比较的耗时与 token 内容无关
+// tokens 可以是明文，也可以是 "sha256:" 加十六进制摘要
+func tokenAllowed(tokens []string, token string) (ok bool) {
+	if token == "" {
+		return false
+	}
+	sum := sha256.Sum256([]byte(token))
+	for _, allowed := range tokens {
+		var match int
+		if strings.HasPrefix(allowed, "sha256:") {
+			digest := strings.ToLower(strings.TrimPrefix(allowed, "sha256:"))
+			match = subtle.ConstantTimeCompare([]byte(digest), []byte(hex.EncodeToString(sum[:])))
+		} else {
+			match = subtle.ConstantTimeCompare([]byte(allowed), []byte(token))
+		}
+		if match == 1 {
+			ok = true
+		}
+	}
+	return
+}
+
+// requestToken 支持 Basic (使用密码，用户名忽略) 和 Bearer 认证
+func requestToken(req *http.Request) string {
+	if _, password, ok := req.BasicAuth(); ok {
+		return password
+	}
+	if auth := req.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(auth, "Bearer "))
+	}
+	return ""
+}
+
+// CheckAuth 检查 auth_tokens 和 tls.client_ca_file
+func (conf ServerConf) CheckAuth() (err error) {
+	err = checkTokens("auth_tokens", conf.AuthTokens)
+	if err != nil {
+		return
+	}
+	if conf.TLS.ClientCAFile != "" {
+		_, err = loadClientCAs(conf.TLS.ClientCAFile)
+	}
+	return
+}
+
+// authorized auth_tokens 为空时不需要认证
+func (conf ServerConf) authorized(req *http.Request) bool {
+	return len(conf.AuthTokens) == 0 || tokenAllowed(conf.AuthTokens, requestToken(req))
+}
+
+// loadClientCAs 读取签发客户端证书的 CA，相对路径相对于配置文件目录
+func loadClientCAs(file string) (pool *x509.CertPool, err error) {
+	pem, err := os.ReadFile(confPath(file))
+	if err != nil {
+		return nil, i18n.NewError(i18n.MsgTLSLoadFailed, confPath(file), err)
+	}
+	pool = x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "tls.client_ca_file")
+	}
+	return
+}
@@ -0,0 +1,273 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+// certCheckInterval 检查证书文件是否变化的间隔
+const certCheckInterval = 30 * time.Second
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// CertConf 路径为相对路径时相对于配置文件目录
+type CertConf struct {
+	CertFile string `json:"cert_file"`
+	KeyFile  string `json:"key_file"`
+}
+
+type loadedCert struct {
+	cert  *tls.Certificate
+	names []string
+}
+
+// CertManager 按 SNI 选择证书，证书文件变化或收到 SIGHUP 时重新加载
+// 重新加载失败时继续使用原来的证书
+type CertManager struct {
+	pairs       []CertConf
+	mutex       sync.RWMutex
+	certs       []loadedCert
+	modTime     map[string]time.Time
+	config      *tls.Config
+	acme        *autocert.Manager
+	acmeDomains []string
+}
+
+// NewCertManager 第一个证书为 cert_file 和 key_file，没有匹配 SNI 的证书时使用第一个
+// 启用 ACME 时 acme.domains 中的域名使用自动申请的证书，此时可以不设置证书文件
+func NewCertManager(conf TLSConf) (manager *CertManager, err error) {
+	manager = &CertManager{modTime: make(map[string]time.Time)}
+	if conf.CertFile != "" || conf.KeyFile != "" {
+		manager.pairs = append(manager.pairs, CertConf{CertFile: conf.CertFile, KeyFile: conf.KeyFile})
+	}
+	manager.pairs = append(manager.pairs, conf.Certificates...)
+	if conf.ACME.Enable {
+		manager.acme, err = newACMEManager(conf.ACME)
+		if err != nil {
+			return nil, err
+		}
+		for _, domain := range conf.ACME.Domains {
+			manager.acmeDomains = append(manager.acmeDomains, strings.ToLower(strings.TrimSuffix(domain, ".")))
+		}
+	} else if len(manager.pairs) == 0 {
+		return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "tls.cert_file, tls.key_file")
+	}
+
+	minVersion := tls.VersionTLS12
+	if conf.MinVersion != "" {
+		version, ok := tlsVersions[strings.TrimPrefix(conf.MinVersion, "TLS")]
+		if !ok {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "tls.min_version")
+		}
+		minVersion = int(version)
+	}
+	var cipherSuites []uint16
+	for _, name := range conf.CipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "tls.cipher_suites")
+		}
+		cipherSuites = append(cipherSuites, id)
+	}
+	manager.config = &tls.Config{
+		MinVersion:     uint16(minVersion),
+		CipherSuites:   cipherSuites,
+		GetCertificate: manager.GetCertificate,
+	}
+	if manager.acme != nil {
+		// TLS-ALPN-01 验证使用 acme-tls/1 协议
+		manager.config.NextProtos = []string{"h2", "http/1.1", acme.ALPNProto}
+	}
+	if conf.ClientCAFile != "" {
+		manager.config.ClientCAs, err = loadClientCAs(conf.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		manager.config.ClientAuth = tls.RequireAndVerifyClientCert
+		if manager.acme != nil {
+			// ACME 服务器的 TLS-ALPN-01 验证不会提供客户端证书
+			acmeConfig := manager.config.Clone()
+			acmeConfig.ClientAuth = tls.NoClientCert
+			manager.config.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+				for _, proto := range hello.SupportedProtos {
+					if proto == acme.ALPNProto {
+						return acmeConfig, nil
+					}
+				}
+				return nil, nil
+			}
+		}
+	}
+
+	err = manager.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+// cipherSuiteID 只接受 Go 认为安全的 TLS 1.2 加密套件，TLS 1.3 的套件不可配置
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
+
+// TLSConfig 每个监听地址使用各自的副本
+func (manager *CertManager) TLSConfig() *tls.Config {
+	return manager.config.Clone()
+}
+
+// Reload 重新读取全部证书，任意一个失败时保留原来的证书
+func (manager *CertManager) Reload() (err error) {
+	certs := make([]loadedCert, 0, len(manager.pairs))
+	modTime := make(map[string]time.Time)
+	for _, pair := range manager.pairs {
+		certFile, keyFile := confPath(pair.CertFile), confPath(pair.KeyFile)
+		cert, loadErr := tls.LoadX509KeyPair(certFile, keyFile)
+		if loadErr != nil {
+			return i18n.NewError(i18n.MsgTLSLoadFailed, certFile, loadErr)
+		}
+		if cert.Leaf == nil {
+			cert.Leaf, loadErr = x509.ParseCertificate(cert.Certificate[0])
+			if loadErr != nil {
+				return i18n.NewError(i18n.MsgTLSLoadFailed, certFile, loadErr)
+			}
+		}
+		names := cert.Leaf.DNSNames
+		if len(names) == 0 && cert.Leaf.Subject.CommonName != "" {
+			names = []string{cert.Leaf.Subject.CommonName}
+		}
+		for i := range names {
+			names[i] = strings.ToLower(names[i])
+		}
+		certs = append(certs, loadedCert{cert: &cert, names: names})
+		for _, file := range []string{certFile, keyFile} {
+			if info, statErr := os.Stat(file); statErr == nil {
+				modTime[file] = info.ModTime()
+			}
+		}
+	}
+	manager.mutex.Lock()
+	manager.certs = certs
+	manager.modTime = modTime
+	manager.mutex.Unlock()
+	return
+}
+
+// GetCertificate ACME 的域名优先，其次依次匹配完整域名和通配符，都不匹配时返回第一个证书
+// 没有证书文件时使用 ACME 的第一个域名的证书
+func (manager *CertManager) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if manager.acme != nil && manager.acmeHost(name) {
+		return manager.acme.GetCertificate(hello)
+	}
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	if len(manager.certs) == 0 {
+		defaultHello := *hello
+		defaultHello.ServerName = manager.acmeDomains[0]
+		return manager.acme.GetCertificate(&defaultHello)
+	}
+	if name != "" {
+		wildcard := ""
+		if i := strings.Index(name, "."); i > 0 {
+			wildcard = "*" + name[i:]
+		}
+		for _, want := range []string{name, wildcard} {
+			for _, loaded := range manager.certs {
+				for _, certName := range loaded.names {
+					if want != "" && certName == want {
+						return loaded.cert, nil
+					}
+				}
+			}
+		}
+	}
+	return manager.certs[0].cert, nil
+}
+
+// Watch 定时检查证书文件的修改时间，收到 SIGHUP 时立即重新加载
+func (manager *CertManager) Watch() {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	ticker := time.NewTicker(certCheckInterval)
+	go func() {
+		for {
+			select {
+			case <-hup:
+			case <-ticker.C:
+				if !manager.changed() {
+					continue
+				}
+			}
+			err := manager.Reload()
+			if err != nil {
+				logger.Error(i18n.T(i18n.MsgTLSReloadFailed), "error", err)
+				continue
+			}
+			logger.Info(i18n.T(i18n.MsgTLSReloaded), "certificates", len(manager.pairs))
+		}
+	}()
+}
+
+func (manager *CertManager) changed() bool {
+	manager.mutex.RLock()
+	defer manager.mutex.RUnlock()
+	for _, pair := range manager.pairs {
+		for _, file := range []string{confPath(pair.CertFile), confPath(pair.KeyFile)} {
+			info, err := os.Stat(file)
+			if err == nil && !info.ModTime().Equal(manager.modTime[file]) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// confPath 相对路径相对于配置文件目录
+func confPath(path string) string {
+	if filepath.IsAbs(path) {
+		return path
+	}
+	return ConfDirectoryName + "/" + path
+}
+
+// RedirectHandler 将 HTTP 请求以 308 重定向到 HTTPS，port 为空或 443 时不带端口
+func RedirectHandler(port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		if port != "" && port != "443" {
+			host += ":" + port
+		}
+		http.Redirect(w, req, "https://"+host+req.URL.RequestURI(), http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,160 @@
+package server
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIPResolver 只有直接连接的地址属于受信任代理时才读取转发请求头
+type ClientIPResolver struct {
+	trusted []*net.IPNet
+}
+
+// NewClientIPResolver cidrs 可以是 CIDR 也可以是单个 IP
+func NewClientIPResolver(cidrs []string) (resolver *ClientIPResolver, err error) {
+	trusted, err := parseCIDRs("trusted_proxies", cidrs)
+	if err != nil {
+		return
+	}
+	return &ClientIPResolver{trusted: trusted}, nil
+}
+
+// HasTrusted 是否配置了受信任代理
+func (resolver *ClientIPResolver) HasTrusted() bool {
+	return len(resolver.trusted) > 0
+}
+
+// IsTrusted ip 为 nil 时返回 false
+func (resolver *ClientIPResolver) IsTrusted(ip net.IP) bool {
+	return containsIP(resolver.trusted, ip)
+}
+
+// parseCIDRs 单个 IP 视为 /32 或 /128，field 为 server.json 中的字段名，用于错误信息
+func parseCIDRs(field string, cidrs []string) (ipNets []*net.IPNet, err error) {
+	for _, cidr := range cidrs {
+		cidr = strings.TrimSpace(cidr)
+		if !strings.Contains(cidr, "/") {
+			ip := net.ParseIP(cidr)
+			if ip == nil {
+				return nil, i18n.NewError(i18n.MsgCIDRInvalid, field, cidr)
+			}
+			if ip.To4() != nil {
+				cidr += "/32"
+			} else {
+				cidr += "/128"
+			}
+		}
+		_, ipNet, parseErr := net.ParseCIDR(cidr)
+		if parseErr != nil {
+			return nil, i18n.NewError(i18n.MsgCIDRInvalid, field, cidr)
+		}
+		ipNets = append(ipNets, ipNet)
+	}
+	return
+}
+
+func containsIP(ipNets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range ipNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP 从直接连接的地址开始，依次从右向左跳过受信任代理，返回第一个不受信任的地址
+// 优先使用 Forwarded，其次 X-Forwarded-For，最后 X-Real-IP
+// 转发链中出现无法解析的地址时停止，返回最后一个受信任的地址
+func (resolver *ClientIPResolver) ClientIP(req *http.Request) string {
+	ip, _ := resolver.ClientAddr(req)
+	return ip
+}
+
+// ClientAddr 同 ClientIP，同时返回端口，转发链中的地址没有端口时为空
+// 通过 Unix socket 的连接与受信任代理相同
+func (resolver *ClientIPResolver) ClientAddr(req *http.Request) (ipAddr, port string) {
+	ip, port := parseHostPort(req.RemoteAddr)
+	local, _ := req.Context().Value(localConnKey{}).(bool)
+	if !local && !resolver.IsTrusted(ip) {
+		return formatIP(ip, req.RemoteAddr), port
+	}
+	var hops []string
+	switch {
+	case len(req.Header.Values("Forwarded")) > 0:
+		hops = forwardedFor(req.Header.Values("Forwarded"))
+	case len(req.Header.Values("X-Forwarded-For")) > 0:
+		for _, value := range req.Header.Values("X-Forwarded-For") {
+			hops = append(hops, strings.Split(value, ",")...)
+		}
+	case req.Header.Get("X-Real-IP") != "":
+		hops = []string{req.Header.Get("X-Real-IP")}
+	}
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, hopPort := parseHostPort(strings.TrimSpace(hops[i]))
+		if hop == nil {
+			break
+		}
+		ip, port = hop, hopPort
+		if !resolver.IsTrusted(ip) {
+			break
+		}
+	}
+	return formatIP(ip, req.RemoteAddr), port
+}
+
+// forwardedFor 按顺序取出 RFC 7239 Forwarded 请求头中的 for 参数
+func forwardedFor(values []string) (hops []string) {
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			found := ""
+			for _, pair := range strings.Split(element, ";") {
+				key, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					found = strings.Trim(val, `"`)
+				}
+			}
+			// 没有 for 参数的元素按无法解析处理
+			hops = append(hops, found)
+		}
+	}
+	return
+}
+
+// parseHostIP 解析 ip、ip:port、[ipv6]:port 和 [ipv6]，无法解析时返回 nil
+func parseHostIP(str string) net.IP {
+	ip, _ := parseHostPort(str)
+	return ip
+}
+
+func parseHostPort(str string) (ip net.IP, port string) {
+	if host, p, err := net.SplitHostPort(str); err == nil {
+		str, port = host, p
+	}
+	str = strings.TrimSuffix(strings.TrimPrefix(str, "["), "]")
+	// 去掉 IPv6 的 zone
+	if i := strings.Index(str, "%"); i >= 0 {
+		str = str[:i]
+	}
+	ip = net.ParseIP(str)
+	if ip == nil {
+		port = ""
+	}
+	return
+}
+
+// formatIP IPv4 映射的 IPv6 地址转换为 IPv4，IPv6 展开 ::
+func formatIP(ip net.IP, fallback string) string {
+	if ip == nil {
+		return fallback
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.String()
+	}
+	return common.DecodeIPv6(ip.String())
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientAddr(t *testing.T) {
+	resolver, err := NewClientIPResolver([]string{"10.0.0.0/8", "192.168.1.1", "fd00::/8"})
+	if err != nil {
+		t.Fatalf("NewClientIPResolver() error = %v", err)
+	}
+	tests := []struct {
+		name     string
+		remote   string
+		local    bool
+		headers  map[string][]string
+		wantIP   string
+		wantPort string
+	}{
+		{
+			name:     "untrusted peer",
+			remote:   "203.0.113.5:1234",
+			wantIP:   "203.0.113.5",
+			wantPort: "1234",
+		},
+		{
+			// 不受信任的连接伪造的请求头全部忽略
+			name:   "untrusted peer spoofed headers",
+			remote: "203.0.113.5:1234",
+			headers: map[string][]string{
+				"Forwarded":       {"for=198.51.100.1"},
+				"X-Forwarded-For": {"198.51.100.2"},
+				"X-Real-IP":       {"198.51.100.3"},
+			},
+			wantIP:   "203.0.113.5",
+			wantPort: "1234",
+		},
+		{
+			name:     "trusted peer without headers",
+			remote:   "10.0.0.1:5678",
+			wantIP:   "10.0.0.1",
+			wantPort: "5678",
+		},
+		{
+			// 从右向左跳过受信任代理，最左边的地址是客户端伪造的
+			name:    "xff skips trusted hops",
+			remote:  "10.0.0.1:5678",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7, 192.168.1.1, 10.0.0.2"}},
+			wantIP:  "203.0.113.7",
+		},
+		{
+			name:    "xff multiple headers",
+			remote:  "10.0.0.1:5678",
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1, 203.0.113.7", "10.0.0.2"}},
+			wantIP:  "203.0.113.7",
+		},
+		{
+			name:    "xff all trusted",
+			remote:  "10.0.0.1:5678",
+			headers: map[string][]string{"X-Forwarded-For": {"10.0.0.3, 10.0.0.2"}},
+			wantIP:  "10.0.0.3",
+		},
+		{
+			// 遇到无法解析的地址时返回最后一个受信任的地址
+			name:    "xff garbage hop",
+			remote:  "10.0.0.1:5678",
+			headers: map[string][]string{"X-Forwarded-For": {"203.0.113.7, garbage, 10.0.0.2"}},
+			wantIP:  "10.0.0.2",
+		},
+		{
+			name:     "forwarded ipv6 with port",
+			remote:   "10.0.0.1:5678",
+			headers:  map[string][]string{"Forwarded": {`for="[2001:db8::7]:4711";proto=https, for=10.0.0.2`}},
+			wantIP:   "2001:db8:0:0:0:0:0:7",
+			wantPort: "4711",
+		},
+		{
+			name:     "forwarded unknown",
+			remote:   "10.0.0.1:5678",
+			headers:  map[string][]string{"Forwarded": {"for=203.0.113.7, for=unknown"}},
+			wantIP:   "10.0.0.1",
+			wantPort: "5678",
+		},
+		{
+			name:     "forwarded without for",
+			remote:   "10.0.0.1:5678",
+			headers:  map[string][]string{"Forwarded": {"proto=https;by=10.0.0.1"}},
+			wantIP:   "10.0.0.1",
+			wantPort: "5678",
+		},
+		{
+			name:   "forwarded preferred over xff",
+			remote: "10.0.0.1:5678",
+			headers: map[string][]string{
+				"Forwarded":       {"For=198.51.100.8"},
+				"X-Forwarded-For": {"198.51.100.9"},
+			},
+			wantIP: "198.51.100.8",
+		},
+		{
+			name:    "x-real-ip fallback",
+			remote:  "10.0.0.1:5678",
+			headers: map[string][]string{"X-Real-IP": {"198.51.100.9"}},
+			wantIP:  "198.51.100.9",
+		},
+		{
+			name:    "trusted ipv6 peer",
+			remote:  "[fd00::1]:5678",
+			headers: map[string][]string{"X-Real-IP": {"[2001:db8::9]"}},
+			wantIP:  "2001:db8:0:0:0:0:0:9",
+		},
+		{
+			name:     "ipv4 mapped peer",
+			remote:   "[::ffff:203.0.113.5]:1234",
+			headers:  map[string][]string{"X-Real-IP": {"198.51.100.9"}},
+			wantIP:   "203.0.113.5",
+			wantPort: "1234",
+		},
+		{
+			// 通过 Unix socket 的连接没有地址，与受信任代理相同
+			name:    "unix socket",
+			remote:  "@",
+			local:   true,
+			headers: map[string][]string{"X-Forwarded-For": {"198.51.100.1"}},
+			wantIP:  "198.51.100.1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remote
+			for key, values := range tt.headers {
+				for _, value := range values {
+					req.Header.Add(key, value)
+				}
+			}
+			if tt.local {
+				req = req.WithContext(context.WithValue(req.Context(), localConnKey{}, true))
+			}
+			ip, port := resolver.ClientAddr(req)
+			if ip != tt.wantIP || port != tt.wantPort {
+				t.Errorf("ClientAddr() = %q, %q, want %q, %q", ip, port, tt.wantIP, tt.wantPort)
+			}
+		})
+	}
+}
+
+func TestNewClientIPResolverInvalid(t *testing.T) {
+	for _, cidr := range []string{"10.0.0.0/33", "example.com", ""} {
+		if _, err := NewClientIPResolver([]string{cidr}); err == nil {
+			t.Errorf("NewClientIPResolver(%q) error = nil", cidr)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package server
+
+import (
+	"ddns-watchdog/internal/client"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// ddnsMaxHosts 单次请求最多更新的主机名数量
+const ddnsMaxHosts = 20
+
+// ddnsMutex 服务商的配置和缓存不是并发安全的，更新请求依次执行
+var ddnsMutex sync.Mutex
+
+// DDNSConf 服务端 DDNS，服务商的凭据只保存在服务端，客户端使用各主机名的 token 提交更新
+type DDNSConf struct {
+	Enable bool       `json:"enable"`
+	Hosts  []DDNSHost `json:"hosts"`
+}
+
+// DDNSHost provider 为 client.json 中 services 的字段名，对应的配置文件放在服务端的配置目录
+// tokens 可以是明文，也可以是 "sha256:" 加十六进制摘要
+type DDNSHost struct {
+	Name     string   `json:"name"`
+	Provider string   `json:"provider"`
+	Tokens   []string `json:"tokens"`
+}
+
+// LoadProviders 检查 hosts 并加载用到的服务商配置，builtin 为是否启用了内置 DNS
+func (conf DDNSConf) LoadProviders(builtin bool) (err error) {
+	client.ConfDirectoryName = ConfDirectoryName
+	loaded := make(map[string]bool)
+	for _, host := range conf.Hosts {
+		if host.Name == "" || host.Provider == "" || len(host.Tokens) == 0 {
+			return i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "ddns.hosts.name, ddns.hosts.provider, ddns.hosts.tokens")
+		}
+		err = checkTokens("ddns.hosts.tokens", host.Tokens)
+		if err != nil {
+			return
+		}
+		if host.Provider == ProviderBuiltin {
+			if !builtin {
+				return i18n.NewError(i18n.MsgDNSNotEnabled, host.Name)
+			}
+			continue
+		}
+		if loaded[host.Provider] {
+			continue
+		}
+		err = client.LoadProvider(host.Provider)
+		if err != nil {
+			return
+		}
+		loaded[host.Provider] = true
+	}
+	return
+}
+
+// host 返回名称匹配的主机，不区分大小写
+func (conf DDNSConf) host(name string) (DDNSHost, bool) {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	for _, host := range conf.Hosts {
+		if strings.ToLower(strings.TrimSuffix(host.Name, ".")) == name {
+			return host, true
+		}
+	}
+	return DDNSHost{}, false
+}
+
+// authorized 比较的耗时与 token 内容无关
+func (host DDNSHost) authorized(token string) bool {
+	return tokenAllowed(host.Tokens, token)
+}
+
+// serveUpdate 兼容 dyndns2 协议的 /nic/update?hostname=&myip=
+// myip 为空时使用客户端的 IP，可以用逗号分隔同时提交 IPv4 和 IPv6，也可以用 myipv6 单独提交 IPv6
+// 每个主机名返回一行 good nochg nohost notfqdn dnserr 等响应码
+func (handler Handler) serveUpdate(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	clientIP := handler.Resolver.ClientIP(req)
+	token := requestToken(req)
+	query := req.URL.Query()
+
+	var ips []string
+	for _, value := range append(strings.Split(query.Get("myip"), ","), query.Get("myipv6")) {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		ip := parseHostIP(value)
+		if ip == nil {
+			http.Error(w, i18n.T(i18n.MsgDDNSBadIP, value), http.StatusBadRequest)
+			return
+		}
+		ips = append(ips, formatIP(ip, value))
+	}
+	if len(ips) == 0 {
+		ips = []string{clientIP}
+	}
+
+	var hostnames []string
+	for _, name := range strings.Split(query.Get("hostname"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			hostnames = append(hostnames, name)
+		}
+	}
+	if len(hostnames) == 0 {
+		_, _ = io.WriteString(w, "notfqdn\n")
+		return
+	}
+	if len(hostnames) > ddnsMaxHosts {
+		_, _ = io.WriteString(w, "numhost\n")
+		return
+	}
+
+	// 没有任何一个主机名认证通过时返回 badauth，否则对未授权的主机名返回 nohost，避免泄露主机名是否存在
+	hosts := make([]DDNSHost, len(hostnames))
+	authorized := false
+	for i, name := range hostnames {
+		if host, ok := handler.Conf.DDNS.host(name); ok && host.authorized(token) {
+			hosts[i] = host
+			authorized = true
+		}
+	}
+	if !authorized {
+		logger.Warn(i18n.T(i18n.MsgDDNSBadAuth), "ip", clientIP, "hostname", query.Get("hostname"), "remote_addr", req.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="`+RunningName+`"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = io.WriteString(w, "badauth\n")
+		return
+	}
+
+	ddnsMutex.Lock()
+	defer ddnsMutex.Unlock()
+	var lines []string
+	for i, host := range hosts {
+		if host.Name == "" {
+			lines = append(lines, "nohost")
+			continue
+		}
+		code := "nochg"
+		for _, ip := range ips {
+			recordType := "A"
+			if net.ParseIP(ip).To4() == nil {
+				recordType = "AAAA"
+			}
+			changed, err := handler.updateHost(host, recordType, ip)
+			if err != nil {
+				code = "dnserr"
+				logger.Error(err.Error(), "hostname", hostnames[i], "provider", host.Provider, "ip", ip, "remote_addr", req.RemoteAddr)
+				break
+			}
+			if changed {
+				code = "good"
+			}
+		}
+		if code == "dnserr" {
+			lines = append(lines, code)
+		} else {
+			lines = append(lines, code+" "+strings.Join(ips, ","))
+		}
+	}
+	_, err := io.WriteString(w, strings.Join(lines, "\n")+"\n")
+	if err != nil {
+		logger.Warn(err.Error(), "remote_addr", req.RemoteAddr)
+		return
+	}
+	logger.Debug(i18n.T(i18n.MsgServerRespond), "ip", clientIP, "hostname", query.Get("hostname"), "result", strings.Join(lines, "; "), "remote_addr", req.RemoteAddr)
+}
+
+// updateHost builtin 的主机更新内置 DNS，其他主机通过服务商更新
+func (handler Handler) updateHost(host DDNSHost, recordType, ip string) (changed bool, err error) {
+	if host.Provider == ProviderBuiltin {
+		return handler.Zone.Update(host.Name, recordType, ip)
+	}
+	msg, err := client.UpdateHost(host.Provider, host.Name, recordType, ip)
+	return msg != "", err
+}
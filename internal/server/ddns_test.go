@@ -0,0 +1,143 @@
+package server
+
+import (
+	"ddns-watchdog/internal/client"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newDDNSHandler home 和 office 由内置 DNS 解析，ghost 使用内置 DNS 但不在区域中，nas 通过 local_file 服务商更新
+func newDDNSHandler(t *testing.T) (handler Handler, hostsFile string) {
+	t.Helper()
+	useConfDirectory(t, t.TempDir())
+	previousDirectory, previousConf := client.ConfDirectoryName, client.Lfc
+	t.Cleanup(func() { client.ConfDirectoryName, client.Lfc = previousDirectory, previousConf })
+	hostsFile = filepath.Join(ConfDirectoryName, "hosts")
+	conf := `{"domain": {"a": "configured.example.com"}, "targets": [{"format": "hosts", "path": ` + strconv.Quote(hostsFile) + `}]}`
+	if err := os.WriteFile(filepath.Join(ConfDirectoryName, client.LocalFileConfFileName), []byte(conf), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	handler.Conf.DDNS = DDNSConf{Enable: true, Hosts: []DDNSHost{
+		{Name: "home.dyn.example.com", Provider: ProviderBuiltin, Tokens: []string{"home-token"}},
+		{Name: "office.dyn.example.com", Provider: ProviderBuiltin, Tokens: []string{"office-token"}},
+		{Name: "nas.example.com", Provider: client.ProviderLocalFile, Tokens: []string{"home-token"}},
+	}}
+	err := handler.Conf.DDNS.LoadProviders(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.Zone, err = NewDNSZone(testDNSConf(), handler.Conf.DDNS.Hosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler.Conf.DDNS.Hosts = append(handler.Conf.DDNS.Hosts,
+		DDNSHost{Name: "ghost.dyn.example.com", Provider: ProviderBuiltin, Tokens: []string{"home-token"}})
+	handler.Resolver, err = NewClientIPResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func ddnsUpdate(handler Handler, query, token string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/nic/update?"+query, nil)
+	req.RemoteAddr = "198.51.100.7:40000"
+	if token != "" {
+		req.SetBasicAuth("ddns", token)
+	}
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestServeUpdate(t *testing.T) {
+	handler, _ := newDDNSHandler(t)
+	tooMany := strings.TrimSuffix(strings.Repeat("home.dyn.example.com,", ddnsMaxHosts+1), ",")
+	// 按顺序执行，后面的用例依赖前面更新后的记录
+	tests := []struct {
+		name       string
+		query      string
+		token      string
+		wantStatus int
+		wantBody   string
+	}{
+		{"no token", "hostname=home.dyn.example.com", "", http.StatusUnauthorized, "badauth\n"},
+		{"wrong token", "hostname=home.dyn.example.com", "wrong", http.StatusUnauthorized, "badauth\n"},
+		// 未登记的主机名与 token 错误的响应相同
+		{"unknown host", "hostname=missing.dyn.example.com", "home-token", http.StatusUnauthorized, "badauth\n"},
+		{"another host's token", "hostname=office.dyn.example.com", "home-token", http.StatusUnauthorized, "badauth\n"},
+		{"no hostname", "hostname=", "home-token", http.StatusOK, "notfqdn\n"},
+		{"too many hostnames", "hostname=" + tooMany, "home-token", http.StatusOK, "numhost\n"},
+		{"bad myip", "hostname=home.dyn.example.com&myip=192.0.2.300", "home-token", http.StatusBadRequest, ""},
+		{"client IP", "hostname=home.dyn.example.com", "home-token", http.StatusOK, "good 198.51.100.7\n"},
+		{"unchanged", "hostname=home.dyn.example.com", "home-token", http.StatusOK, "nochg 198.51.100.7\n"},
+		// 部分主机名认证通过时，未授权和未登记的主机名都返回 nohost
+		{"mixed hostnames", "hostname=office.dyn.example.com,home.dyn.example.com,missing.dyn.example.com&myip=192.0.2.1",
+			"home-token", http.StatusOK, "nohost\ngood 192.0.2.1\nnohost\n"},
+		{"comma-separated myip", "hostname=home.dyn.example.com&myip=192.0.2.1,2001:db8::1", "home-token", http.StatusOK,
+			"good 192.0.2.1,2001:db8:0:0:0:0:0:1\n"},
+		{"myip with myipv6", "hostname=home.dyn.example.com&myip=192.0.2.2&myipv6=2001:db8::2", "home-token", http.StatusOK,
+			"good 192.0.2.2,2001:db8:0:0:0:0:0:2\n"},
+		{"myipv6 only", "hostname=home.dyn.example.com&myipv6=2001:db8::2", "home-token", http.StatusOK,
+			"nochg 2001:db8:0:0:0:0:0:2\n"},
+		{"builtin host outside the zone", "hostname=ghost.dyn.example.com,home.dyn.example.com&myip=192.0.2.2", "home-token", http.StatusOK,
+			"dnserr\nnochg 192.0.2.2\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := ddnsUpdate(handler, tt.query, tt.token)
+			if w.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d, body %q", w.Code, tt.wantStatus, w.Body.String())
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Errorf("body = %q, want %q", w.Body.String(), tt.wantBody)
+			}
+			if w.Code == http.StatusUnauthorized && !strings.HasPrefix(w.Header().Get("WWW-Authenticate"), "Basic ") {
+				t.Errorf("WWW-Authenticate = %q", w.Header().Get("WWW-Authenticate"))
+			}
+		})
+	}
+
+	record := handler.Zone.data.Records["home.dyn.example.com."]
+	if record.A != "192.0.2.2" || record.AAAA != "2001:db8:0:0:0:0:0:2" {
+		t.Errorf("home record = %+v", *record)
+	}
+	if record = handler.Zone.data.Records["office.dyn.example.com."]; record.A != "" || record.AAAA != "" {
+		t.Errorf("office record = %+v", *record)
+	}
+}
+
+func TestServeUpdateRouting(t *testing.T) {
+	handler, hostsFile := newDDNSHandler(t)
+	w := ddnsUpdate(handler, "hostname=nas.example.com,home.dyn.example.com&myip=192.0.2.9", "home-token")
+	if want := "good 192.0.2.9\ngood 192.0.2.9\n"; w.Body.String() != want {
+		t.Fatalf("body = %q, want %q", w.Body.String(), want)
+	}
+	// builtin 的主机写入内置 DNS，其他主机交给服务商，不会写入内置 DNS
+	if _, ok := handler.Zone.data.Records["nas.example.com."]; ok {
+		t.Error("provider host was added to the builtin zone")
+	}
+	if record := handler.Zone.data.Records["home.dyn.example.com."]; record.A != "192.0.2.9" {
+		t.Errorf("home record = %+v", *record)
+	}
+	content, err := os.ReadFile(hostsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(content), "192.0.2.9\tnas.example.com\n") || strings.Contains(string(content), "home.dyn.example.com") {
+		t.Errorf("hosts file = %q", content)
+	}
+
+	// 未启用服务端 DDNS 时 /nic/update 与其他路径相同
+	handler.Conf.DDNS.Enable = false
+	w = ddnsUpdate(handler, "hostname=nas.example.com&myip=192.0.2.10", "home-token")
+	if w.Code != http.StatusOK || strings.Contains(w.Body.String(), "good") {
+		t.Errorf("disabled DDNS response = %d %q", w.Code, w.Body.String())
+	}
+}
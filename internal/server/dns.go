@@ -0,0 +1,279 @@
+package server
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+const (
+	// ProviderBuiltin 服务端 DDNS 的主机由内置的权威 DNS 解析
+	ProviderBuiltin = "builtin"
+	// DNSRecordsFileName 保存内置 DNS 的记录，重新启动后继续解析
+	DNSRecordsFileName = "dns_records.json"
+)
+
+// DNSConf 内置的权威 DNS，需要将子域名 (例如 dyn.example.com) 的 NS 委派到本服务器
+// hostmaster 可以是邮箱地址，也可以是 SOA 的 rname 格式
+// name_server_addresses 为属于 zone 的 name_servers 提供 A 和 AAAA 记录，否则无法解析这些名称服务器
+type DNSConf struct {
+	Enable              bool                `json:"enable"`
+	Listen              string              `json:"listen"`
+	Zone                string              `json:"zone"`
+	NameServers         []string            `json:"name_servers"`
+	NameServerAddresses map[string][]string `json:"name_server_addresses"`
+	Hostmaster          string              `json:"hostmaster"`
+	TTL                 uint32              `json:"ttl"`
+}
+
+type dnsRecord struct {
+	A    string `json:"a,omitempty"`
+	AAAA string `json:"aaaa,omitempty"`
+}
+
+type dnsRecords struct {
+	Serial  uint32                `json:"serial"`
+	Records map[string]*dnsRecord `json:"records"`
+}
+
+// DNSZone 只解析 hosts 中 provider 为 builtin 的主机名和区域内的名称服务器，其他名称返回 NXDOMAIN
+type DNSZone struct {
+	conf  DNSConf
+	mutex sync.RWMutex
+	data  dnsRecords
+	// nameServers 区域内名称服务器的地址，启动后不会变化
+	nameServers map[string][]net.IP
+}
+
+// NewDNSZone 检查配置，注册 hosts 中由内置 DNS 解析的主机名并读取已保存的记录
+func NewDNSZone(conf DNSConf, hosts []DDNSHost) (zone *DNSZone, err error) {
+	if conf.Zone == "" || len(conf.NameServers) == 0 {
+		return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "dns.zone, dns.name_servers")
+	}
+	conf.Zone = dns.CanonicalName(conf.Zone)
+	for i := range conf.NameServers {
+		conf.NameServers[i] = dns.CanonicalName(conf.NameServers[i])
+	}
+	if conf.Listen == "" {
+		conf.Listen = ":53"
+	}
+	if conf.Hostmaster == "" {
+		conf.Hostmaster = "hostmaster." + conf.Zone
+	}
+	conf.Hostmaster = dns.Fqdn(strings.Replace(conf.Hostmaster, "@", ".", 1))
+	if conf.TTL == 0 {
+		conf.TTL = 60
+	}
+	zone = &DNSZone{conf: conf, data: dnsRecords{Records: make(map[string]*dnsRecord)}, nameServers: make(map[string][]net.IP)}
+	for name, addresses := range conf.NameServerAddresses {
+		name = dns.CanonicalName(name)
+		listed := false
+		for _, nameServer := range conf.NameServers {
+			listed = listed || nameServer == name
+		}
+		if !listed || !dns.IsSubDomain(conf.Zone, name) {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "dns.name_server_addresses")
+		}
+		for _, address := range addresses {
+			ip := net.ParseIP(address)
+			if ip == nil {
+				return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "dns.name_server_addresses")
+			}
+			zone.nameServers[name] = append(zone.nameServers[name], ip)
+		}
+	}
+	for _, nameServer := range conf.NameServers {
+		if dns.IsSubDomain(conf.Zone, nameServer) && len(zone.nameServers[nameServer]) == 0 {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "dns.name_server_addresses")
+		}
+	}
+
+	saved := dnsRecords{}
+	err = common.LoadAndUnmarshal(ConfDirectoryName+"/"+DNSRecordsFileName, &saved)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+	err = nil
+	zone.data.Serial = saved.Serial
+	for _, host := range hosts {
+		if host.Provider != ProviderBuiltin {
+			continue
+		}
+		name := dns.CanonicalName(host.Name)
+		if !dns.IsSubDomain(conf.Zone, name) {
+			return nil, i18n.NewError(i18n.MsgHostNotInZone, host.Name, conf.Zone)
+		}
+		if _, ok := zone.nameServers[name]; ok {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "dns.name_server_addresses")
+		}
+		zone.data.Records[name] = &dnsRecord{}
+		if record, ok := saved.Records[name]; ok && record != nil {
+			*zone.data.Records[name] = *record
+		}
+	}
+	return
+}
+
+// Update 记录无变化时 changed 为 false，变化后保存到 DNSRecordsFileName 并增加 SOA 的序列号
+// 新的记录在副本中修改，保存成功后才生效，保存失败时继续解析原来的记录
+func (zone *DNSZone) Update(name, recordType, ip string) (changed bool, err error) {
+	name = dns.CanonicalName(name)
+	zone.mutex.Lock()
+	defer zone.mutex.Unlock()
+	record, ok := zone.data.Records[name]
+	if !ok {
+		return false, i18n.NewError(i18n.MsgHostNotInZone, name, zone.conf.Zone)
+	}
+	if (recordType == "AAAA" && record.AAAA == ip) || (recordType != "AAAA" && record.A == ip) {
+		return false, nil
+	}
+	// 序列号使用时间戳，同一秒内多次更新时递增
+	serial := uint32(time.Now().Unix())
+	if serial <= zone.data.Serial {
+		serial = zone.data.Serial + 1
+	}
+	data := dnsRecords{Serial: serial, Records: make(map[string]*dnsRecord, len(zone.data.Records))}
+	for key, value := range zone.data.Records {
+		copied := *value
+		data.Records[key] = &copied
+	}
+	if recordType == "AAAA" {
+		data.Records[name].AAAA = ip
+	} else {
+		data.Records[name].A = ip
+	}
+	err = common.MarshalAndSave(data, ConfDirectoryName+"/"+DNSRecordsFileName)
+	if err != nil {
+		return
+	}
+	zone.data = data
+	logger.Info(i18n.T(i18n.MsgDNSRecordUpdated, name, ip), "record", name, "type", recordType, "serial", serial)
+	return true, nil
+}
+
+// ListenAndServe 同时监听 UDP 和 TCP，任意一个退出时返回错误
+func (zone *DNSZone) ListenAndServe() error {
+	errs := make(chan error, 2)
+	for _, network := range []string{"udp", "tcp"} {
+		srv := &dns.Server{Addr: zone.conf.Listen, Net: network, Handler: zone}
+		go func() {
+			errs <- srv.ListenAndServe()
+		}()
+	}
+	logger.Info(i18n.T(i18n.MsgDNSListen), "addr", zone.conf.Listen, "zone", zone.conf.Zone)
+	return <-errs
+}
+
+func (zone *DNSZone) ServeDNS(w dns.ResponseWriter, req *dns.Msg) {
+	resp := new(dns.Msg)
+	resp.SetReply(req)
+	resp.Compress = true
+	if len(req.Question) != 1 || req.Opcode != dns.OpcodeQuery {
+		resp.SetRcode(req, dns.RcodeNotImplemented)
+		_ = w.WriteMsg(resp)
+		return
+	}
+	question := req.Question[0]
+	name := dns.CanonicalName(question.Name)
+	if question.Qclass != dns.ClassINET || !dns.IsSubDomain(zone.conf.Zone, name) {
+		resp.SetRcode(req, dns.RcodeRefused)
+		_ = w.WriteMsg(resp)
+		return
+	}
+	resp.Authoritative = true
+
+	zone.mutex.RLock()
+	defer zone.mutex.RUnlock()
+	if name == zone.conf.Zone {
+		if question.Qtype == dns.TypeSOA || question.Qtype == dns.TypeANY {
+			resp.Answer = append(resp.Answer, zone.soa())
+		}
+		if question.Qtype == dns.TypeNS || question.Qtype == dns.TypeANY {
+			resp.Answer = append(resp.Answer, zone.ns()...)
+			// 区域内的名称服务器在 additional 中附带地址
+			for _, nameServer := range zone.conf.NameServers {
+				resp.Extra = append(resp.Extra, zone.addresses(nameServer, dns.TypeANY, zone.nameServers[nameServer])...)
+			}
+		}
+	} else if record, ok := zone.data.Records[name]; ok {
+		var ips []net.IP
+		for _, value := range []string{record.A, record.AAAA} {
+			if value != "" {
+				ips = append(ips, net.ParseIP(value))
+			}
+		}
+		resp.Answer = zone.addresses(question.Name, question.Qtype, ips)
+	} else if ips, ok := zone.nameServers[name]; ok {
+		resp.Answer = zone.addresses(question.Name, question.Qtype, ips)
+	} else if !zone.hasDescendant(name) {
+		// 已登记名称的上级 (空的非终端节点) 没有记录但存在，返回 NODATA 而不是 NXDOMAIN
+		resp.Rcode = dns.RcodeNameError
+	}
+	// NODATA 和 NXDOMAIN 在 authority 中附带 SOA，用于否定缓存
+	if len(resp.Answer) == 0 {
+		resp.Ns = append(resp.Ns, zone.soa())
+	}
+	_ = w.WriteMsg(resp)
+}
+
+func (zone *DNSZone) soa() dns.RR {
+	return &dns.SOA{
+		Hdr:     dns.RR_Header{Name: zone.conf.Zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: zone.conf.TTL},
+		Ns:      zone.conf.NameServers[0],
+		Mbox:    zone.conf.Hostmaster,
+		Serial:  zone.data.Serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  zone.conf.TTL,
+	}
+}
+
+// addresses 返回 qtype 对应的 A 和 AAAA 记录，qtype 为 ANY 时返回全部
+func (zone *DNSZone) addresses(name string, qtype uint16, ips []net.IP) (rrs []dns.RR) {
+	for _, ip := range ips {
+		header := dns.RR_Header{Name: name, Class: dns.ClassINET, Ttl: zone.conf.TTL}
+		if ip4 := ip.To4(); ip4 != nil {
+			if qtype == dns.TypeA || qtype == dns.TypeANY {
+				header.Rrtype = dns.TypeA
+				rrs = append(rrs, &dns.A{Hdr: header, A: ip4})
+			}
+		} else if qtype == dns.TypeAAAA || qtype == dns.TypeANY {
+			header.Rrtype = dns.TypeAAAA
+			rrs = append(rrs, &dns.AAAA{Hdr: header, AAAA: ip})
+		}
+	}
+	return
+}
+
+// hasDescendant name 下有已登记的主机名或名称服务器
+func (zone *DNSZone) hasDescendant(name string) bool {
+	for registered := range zone.data.Records {
+		if registered != name && dns.IsSubDomain(name, registered) {
+			return true
+		}
+	}
+	for nameServer := range zone.nameServers {
+		if nameServer != name && dns.IsSubDomain(name, nameServer) {
+			return true
+		}
+	}
+	return false
+}
+
+func (zone *DNSZone) ns() (rrs []dns.RR) {
+	for _, nameServer := range zone.conf.NameServers {
+		rrs = append(rrs, &dns.NS{
+			Hdr: dns.RR_Header{Name: zone.conf.Zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: zone.conf.TTL},
+			Ns:  nameServer,
+		})
+	}
+	return
+}
@@ -0,0 +1,270 @@
+package server
+
+import (
+	"ddns-watchdog/internal/i18n"
+	"errors"
+	"net"
+	"os"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// useConfDirectory 将 ConfDirectoryName 指向 dir，测试结束后恢复
+func useConfDirectory(t *testing.T, dir string) {
+	t.Helper()
+	previous := ConfDirectoryName
+	ConfDirectoryName = dir
+	t.Cleanup(func() { ConfDirectoryName = previous })
+}
+
+// testDNSConf dyn.example.com 有一个区域内和一个区域外的名称服务器
+func testDNSConf() DNSConf {
+	return DNSConf{
+		Zone:                "dyn.example.com",
+		NameServers:         []string{"ns1.dyn.example.com", "ns2.example.net"},
+		NameServerAddresses: map[string][]string{"ns1.dyn.example.com": {"192.0.2.53", "2001:db8::53"}},
+		Hostmaster:          "hostmaster@example.com",
+		TTL:                 60,
+	}
+}
+
+var testDNSHosts = []DDNSHost{
+	{Name: "home.dyn.example.com", Provider: ProviderBuiltin, Tokens: []string{"token"}},
+	{Name: "a.b.dyn.example.com", Provider: ProviderBuiltin, Tokens: []string{"token"}},
+	{Name: "www.example.com", Provider: "dnspod", Tokens: []string{"token"}},
+}
+
+// serveDNSZone 在本地 UDP 端口上运行 zone，返回地址
+func serveDNSZone(t *testing.T, zone *DNSZone) string {
+	t.Helper()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	srv := &dns.Server{PacketConn: conn, Handler: zone, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = srv.ActivateAndServe() }()
+	<-started
+	t.Cleanup(func() { _ = srv.Shutdown() })
+	return conn.LocalAddr().String()
+}
+
+func queryDNS(t *testing.T, addr, name string, qtype uint16) *dns.Msg {
+	t.Helper()
+	req := new(dns.Msg)
+	req.SetQuestion(name, qtype)
+	resp, _, err := new(dns.Client).Exchange(req, addr)
+	if err != nil {
+		t.Fatalf("Exchange(%s %s) error = %v", name, dns.TypeToString[qtype], err)
+	}
+	return resp
+}
+
+// rrValues 以 "类型 值" 的形式返回记录，按字符串排序
+func rrValues(rrs []dns.RR) (values []string) {
+	for _, rr := range rrs {
+		switch rr := rr.(type) {
+		case *dns.A:
+			values = append(values, "A "+rr.A.String())
+		case *dns.AAAA:
+			values = append(values, "AAAA "+rr.AAAA.String())
+		case *dns.NS:
+			values = append(values, "NS "+rr.Ns)
+		case *dns.SOA:
+			values = append(values, "SOA "+rr.Ns)
+		default:
+			values = append(values, rr.String())
+		}
+	}
+	sort.Strings(values)
+	return
+}
+
+func TestDNSZoneServe(t *testing.T) {
+	useConfDirectory(t, t.TempDir())
+	zone, err := NewDNSZone(testDNSConf(), testDNSHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, update := range [][2]string{{"A", "192.0.2.1"}, {"AAAA", "2001:db8::1"}} {
+		if _, err = zone.Update("home.dyn.example.com", update[0], update[1]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	addr := serveDNSZone(t, zone)
+
+	tests := []struct {
+		name       string
+		qname      string
+		qtype      uint16
+		wantRcode  int
+		wantAnswer []string
+		wantExtra  []string
+	}{
+		{"A", "home.dyn.example.com.", dns.TypeA, dns.RcodeSuccess, []string{"A 192.0.2.1"}, nil},
+		{"AAAA", "home.dyn.example.com.", dns.TypeAAAA, dns.RcodeSuccess, []string{"AAAA 2001:db8::1"}, nil},
+		{"ANY", "home.dyn.example.com.", dns.TypeANY, dns.RcodeSuccess, []string{"A 192.0.2.1", "AAAA 2001:db8::1"}, nil},
+		{"case insensitive", "HOME.Dyn.Example.COM.", dns.TypeA, dns.RcodeSuccess, []string{"A 192.0.2.1"}, nil},
+		{"other type is NODATA", "home.dyn.example.com.", dns.TypeMX, dns.RcodeSuccess, nil, nil},
+		{"host without records is NODATA", "a.b.dyn.example.com.", dns.TypeA, dns.RcodeSuccess, nil, nil},
+		{"empty non-terminal is NODATA", "b.dyn.example.com.", dns.TypeA, dns.RcodeSuccess, nil, nil},
+		{"in-zone name server A", "ns1.dyn.example.com.", dns.TypeA, dns.RcodeSuccess, []string{"A 192.0.2.53"}, nil},
+		{"in-zone name server AAAA", "ns1.dyn.example.com.", dns.TypeAAAA, dns.RcodeSuccess, []string{"AAAA 2001:db8::53"}, nil},
+		{"unregistered name", "missing.dyn.example.com.", dns.TypeA, dns.RcodeNameError, nil, nil},
+		{"below a registered host", "x.home.dyn.example.com.", dns.TypeA, dns.RcodeNameError, nil, nil},
+		{"apex SOA", "dyn.example.com.", dns.TypeSOA, dns.RcodeSuccess, []string{"SOA ns1.dyn.example.com."}, nil},
+		{"apex NS with glue", "dyn.example.com.", dns.TypeNS, dns.RcodeSuccess,
+			[]string{"NS ns1.dyn.example.com.", "NS ns2.example.net."}, []string{"A 192.0.2.53", "AAAA 2001:db8::53"}},
+		{"apex A is NODATA", "dyn.example.com.", dns.TypeA, dns.RcodeSuccess, nil, nil},
+		{"out of zone", "www.example.com.", dns.TypeA, dns.RcodeRefused, nil, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := queryDNS(t, addr, tt.qname, tt.qtype)
+			if resp.Rcode != tt.wantRcode {
+				t.Fatalf("Rcode = %s, want %s", dns.RcodeToString[resp.Rcode], dns.RcodeToString[tt.wantRcode])
+			}
+			if got := rrValues(resp.Answer); !reflect.DeepEqual(got, tt.wantAnswer) {
+				t.Errorf("Answer = %v, want %v", got, tt.wantAnswer)
+			}
+			if got := rrValues(resp.Extra); !reflect.DeepEqual(got, tt.wantExtra) {
+				t.Errorf("Extra = %v, want %v", got, tt.wantExtra)
+			}
+			if tt.wantRcode == dns.RcodeRefused {
+				return
+			}
+			if !resp.Authoritative {
+				t.Error("response is not authoritative")
+			}
+			// 否定回答在 authority 中附带 SOA
+			if len(resp.Answer) == 0 && (len(resp.Ns) != 1 || resp.Ns[0].Header().Rrtype != dns.TypeSOA) {
+				t.Errorf("Ns = %v, want SOA", resp.Ns)
+			}
+		})
+	}
+}
+
+func TestDNSZoneUpdate(t *testing.T) {
+	useConfDirectory(t, t.TempDir())
+	zone, err := NewDNSZone(testDNSConf(), testDNSHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := serveDNSZone(t, zone)
+	serial := func() uint32 {
+		return queryDNS(t, addr, "dyn.example.com.", dns.TypeSOA).Answer[0].(*dns.SOA).Serial
+	}
+
+	changed, err := zone.Update("Home.dyn.example.com.", "A", "192.0.2.1")
+	if err != nil || !changed {
+		t.Fatalf("Update() = %v, %v", changed, err)
+	}
+	first := serial()
+	changed, err = zone.Update("home.dyn.example.com", "A", "192.0.2.1")
+	if err != nil || changed || serial() != first {
+		t.Fatalf("Update() with the same IP = %v, %v, serial %d -> %d", changed, err, first, serial())
+	}
+	var i18nErr *i18n.Error
+	if _, err = zone.Update("www.example.com", "A", "192.0.2.1"); !errors.As(err, &i18nErr) || i18nErr.ID != i18n.MsgHostNotInZone {
+		t.Fatalf("Update() of a provider host error = %v", err)
+	}
+
+	// 配置目录是普通文件时无法保存，保存失败时不修改正在解析的记录和序列号
+	notDirectory := ConfDirectoryName + "/not-a-directory"
+	if err = os.WriteFile(notDirectory, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	useConfDirectory(t, notDirectory)
+	if _, err = zone.Update("home.dyn.example.com", "A", "192.0.2.2"); err == nil {
+		t.Fatal("Update() with an unwritable directory error = nil")
+	}
+	if got := rrValues(queryDNS(t, addr, "home.dyn.example.com.", dns.TypeA).Answer); !reflect.DeepEqual(got, []string{"A 192.0.2.1"}) {
+		t.Errorf("Answer after a failed save = %v", got)
+	}
+	if serial() != first {
+		t.Errorf("serial after a failed save = %d, want %d", serial(), first)
+	}
+}
+
+func TestDNSZoneReload(t *testing.T) {
+	useConfDirectory(t, t.TempDir())
+	zone, err := NewDNSZone(testDNSConf(), testDNSHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = zone.Update("home.dyn.example.com", "AAAA", "2001:db8::1"); err != nil {
+		t.Fatal(err)
+	}
+	// 不再由内置 DNS 解析的主机名不会被加载
+	reloaded, err := NewDNSZone(testDNSConf(), testDNSHosts[1:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reloaded.data.Serial != zone.data.Serial {
+		t.Errorf("Serial = %d, want %d", reloaded.data.Serial, zone.data.Serial)
+	}
+	if _, ok := reloaded.data.Records["home.dyn.example.com."]; ok {
+		t.Error("removed host was loaded")
+	}
+	reloaded, err = NewDNSZone(testDNSConf(), testDNSHosts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record := reloaded.data.Records["home.dyn.example.com."]; record == nil || record.AAAA != "2001:db8::1" {
+		t.Errorf("reloaded record = %v", record)
+	}
+}
+
+func TestNewDNSZoneConf(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(conf *DNSConf, hosts *[]DDNSHost)
+		wantID i18n.MessageID
+	}{
+		{"in-zone name server without addresses", func(conf *DNSConf, _ *[]DDNSHost) {
+			conf.NameServerAddresses = nil
+		}, i18n.MsgConfCheckFields},
+		{"addresses for an unlisted name server", func(conf *DNSConf, _ *[]DDNSHost) {
+			conf.NameServerAddresses["ns3.dyn.example.com"] = []string{"192.0.2.54"}
+		}, i18n.MsgConfCheckFields},
+		{"invalid address", func(conf *DNSConf, _ *[]DDNSHost) {
+			conf.NameServerAddresses["ns1.dyn.example.com"] = []string{"192.0.2.300"}
+		}, i18n.MsgConfCheckFields},
+		{"host named like a name server", func(_ *DNSConf, hosts *[]DDNSHost) {
+			*hosts = append(*hosts, DDNSHost{Name: "NS1.dyn.example.com", Provider: ProviderBuiltin})
+		}, i18n.MsgConfCheckFields},
+		{"host outside the zone", func(_ *DNSConf, hosts *[]DDNSHost) {
+			*hosts = append(*hosts, DDNSHost{Name: "home.example.com", Provider: ProviderBuiltin})
+		}, i18n.MsgHostNotInZone},
+		{"missing zone", func(conf *DNSConf, _ *[]DDNSHost) {
+			conf.Zone = ""
+		}, i18n.MsgConfCheckFields},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useConfDirectory(t, t.TempDir())
+			conf := testDNSConf()
+			hosts := append([]DDNSHost{}, testDNSHosts...)
+			tt.modify(&conf, &hosts)
+			_, err := NewDNSZone(conf, hosts)
+			var i18nErr *i18n.Error
+			if !errors.As(err, &i18nErr) || i18nErr.ID != tt.wantID {
+				t.Fatalf("NewDNSZone() error = %v, want %s", err, tt.wantID)
+			}
+		})
+	}
+	// 区域外的名称服务器不需要地址
+	useConfDirectory(t, t.TempDir())
+	conf := testDNSConf()
+	conf.NameServers = []string{"ns2.example.net"}
+	conf.NameServerAddresses = nil
+	if _, err := NewDNSZone(conf, testDNSHosts); err != nil {
+		t.Fatalf("NewDNSZone() error = %v", err)
+	}
+	if _, err := os.Stat(ConfDirectoryName + "/" + DNSRecordsFileName); !os.IsNotExist(err) {
+		t.Errorf("records file stat error = %v, want not exist", err)
+	}
+}
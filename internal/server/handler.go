@@ -0,0 +1,177 @@
+package server
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"encoding/json"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 响应格式
+const (
+	FormatJSON = "json"
+	FormatText = "text"
+	FormatFull = "full"
+)
+
+// redactedHeaders 详细信息中不回显的请求头
+var redactedHeaders = []string{"Authorization", "Cookie", "Proxy-Authorization"}
+
+// FullInfo 详细信息，用于排查代理和网络问题
+type FullInfo struct {
+	IP         string              `json:"ip"`
+	Family     string              `json:"family"`
+	Port       string              `json:"port"`
+	Version    string              `json:"latest_version"`
+	ServerTime string              `json:"server_time"`
+	Headers    map[string][]string `json:"headers"`
+}
+
+// Handler 按路径最后一段选择行为，部署在反向代理的子路径下时同样生效
+// /ip 返回纯文本 IP，/ipv4-only 和 /ipv6-only 在地址族不符时返回 404，/info 返回详细信息
+// 启用服务端 DDNS 时 /update 和 /nic/update 用于提交更新
+// 设置了 auth_tokens 时其他路径需要 Authorization: Bearer <token>，DDNS 更新使用各主机名的 token
+// 其他路径保持原有的 JSON，也可以通过 format 参数或 Accept 请求头选择格式
+type Handler struct {
+	Conf     ServerConf
+	Resolver *ClientIPResolver
+	// Zone 未启用内置 DNS 时为 nil
+	Zone    *DNSZone
+	Version *VersionCache
+}
+
+func (handler Handler) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	w.Header().Add("Cache-Control", "no-store")
+	w.Header().Add("Vary", "Accept")
+	ip, port := handler.Resolver.ClientAddr(req)
+	family := "IPv4"
+	if strings.Contains(ip, ":") {
+		family = "IPv6"
+	}
+
+	if handler.Conf.DDNS.Enable && path.Base(req.URL.Path) == "update" {
+		handler.serveUpdate(w, req)
+		return
+	}
+	if !handler.Conf.authorized(req) {
+		logger.Debug(i18n.T(i18n.MsgAuthFailed), "ip", ip, "remote_addr", req.RemoteAddr, "path", req.URL.Path)
+		w.Header().Set("WWW-Authenticate", `Bearer realm="`+RunningName+`"`)
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	format := negotiateFormat(req)
+	switch path.Base(req.URL.Path) {
+	case "ip":
+		format = FormatText
+	case "ipv4-only", "ipv6-only":
+		if !strings.EqualFold(path.Base(req.URL.Path), family+"-only") {
+			http.Error(w, i18n.T(i18n.MsgServerFamilyMismatch, family), http.StatusNotFound)
+			logger.Debug(i18n.T(i18n.MsgServerFamilyMismatch, family), "ip", ip, "remote_addr", req.RemoteAddr, "path", req.URL.Path)
+			return
+		}
+		if req.URL.Query().Get("format") == "" {
+			format = FormatText
+		}
+	case "info":
+		format = FormatFull
+	}
+
+	var body []byte
+	var err error
+	switch format {
+	case FormatText:
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		body = []byte(ip + "\n")
+	case FormatFull:
+		w.Header().Set("Content-Type", "application/json")
+		info := FullInfo{
+			IP:         ip,
+			Family:     family,
+			Port:       port,
+			Version:    handler.latestVersion(),
+			ServerTime: time.Now().Format(time.RFC3339),
+			Headers:    req.Header.Clone(),
+		}
+		for _, name := range redactedHeaders {
+			if _, ok := info.Headers[name]; ok {
+				info.Headers[name] = []string{"[redacted]"}
+			}
+		}
+		body, err = json.Marshal(info)
+	default:
+		w.Header().Set("Content-Type", "application/json")
+		body, err = json.Marshal(common.PublicInfo{
+			IP:      ip,
+			Version: handler.latestVersion(),
+		})
+	}
+	if err != nil {
+		logger.Error(err.Error(), "remote_addr", req.RemoteAddr)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	_, err = io.WriteString(w, string(body))
+	if err != nil {
+		logger.Warn(err.Error(), "remote_addr", req.RemoteAddr)
+		return
+	}
+	logger.Debug(i18n.T(i18n.MsgServerRespond), "ip", ip, "format", format, "remote_addr", req.RemoteAddr, "user_agent", req.UserAgent())
+}
+
+// latestVersion 没有设置 Version 时每次请求都向根服务器获取
+func (handler Handler) latestVersion() string {
+	if handler.Version != nil {
+		return handler.Version.Get()
+	}
+	return handler.Conf.GetLatestVersion()
+}
+
+// negotiateFormat format 参数优先，其次比较 Accept 中 text/plain 和 application/json 的权重
+// 权重相同或都没有明确列出时返回 JSON，兼容原有客户端和 curl 默认的 */*
+func negotiateFormat(req *http.Request) string {
+	switch strings.ToLower(req.URL.Query().Get("format")) {
+	case "text", "txt", "plain":
+		return FormatText
+	case "full", "info":
+		return FormatFull
+	case "json":
+		return FormatJSON
+	}
+	textQ, jsonQ := -1.0, -1.0
+	for _, value := range req.Header.Values("Accept") {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			q := 1.0
+			if qStr, ok := params["q"]; ok {
+				if q, err = strconv.ParseFloat(qStr, 64); err != nil {
+					continue
+				}
+			}
+			switch mediaType {
+			case "text/plain":
+				if q > textQ {
+					textQ = q
+				}
+			case "application/json":
+				if q > jsonQ {
+					jsonQ = q
+				}
+			}
+		}
+	}
+	if textQ > 0 && textQ > jsonQ {
+		return FormatText
+	}
+	return FormatJSON
+}
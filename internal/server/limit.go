@@ -0,0 +1,148 @@
+package server
+
+import (
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// limitSweepInterval 清理已回满的令牌桶的间隔
+const limitSweepInterval = time.Minute
+
+// LimitConf rate 为每个客户端每秒补充的请求数，为 0 时不限制速率
+// allow 不为空时只允许其中的地址，deny 优先于 allow
+// 超时和大小为 0 时使用默认值
+type LimitConf struct {
+	Rate                     float64  `json:"rate"`
+	Burst                    int      `json:"burst"`
+	Allow                    []string `json:"allow"`
+	Deny                     []string `json:"deny"`
+	ReadHeaderTimeoutSeconds int      `json:"read_header_timeout_seconds"`
+	ReadTimeoutSeconds       int      `json:"read_timeout_seconds"`
+	WriteTimeoutSeconds      int      `json:"write_timeout_seconds"`
+	IdleTimeoutSeconds       int      `json:"idle_timeout_seconds"`
+	MaxHeaderBytes           int      `json:"max_header_bytes"`
+	MaxBodyBytes             int64    `json:"max_body_bytes"`
+}
+
+// HTTPServer 按配置设置超时和请求头大小
+func (conf LimitConf) HTTPServer(handler http.Handler) *http.Server {
+	seconds := func(value, fallback int) time.Duration {
+		if value <= 0 {
+			value = fallback
+		}
+		return time.Duration(value) * time.Second
+	}
+	maxHeaderBytes := conf.MaxHeaderBytes
+	if maxHeaderBytes <= 0 {
+		maxHeaderBytes = 16 << 10
+	}
+	return &http.Server{
+		Handler:           handler,
+		ReadHeaderTimeout: seconds(conf.ReadHeaderTimeoutSeconds, 10),
+		ReadTimeout:       seconds(conf.ReadTimeoutSeconds, 30),
+		WriteTimeout:      seconds(conf.WriteTimeoutSeconds, 30),
+		IdleTimeout:       seconds(conf.IdleTimeoutSeconds, 120),
+		MaxHeaderBytes:    maxHeaderBytes,
+	}
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter 按客户端 IP 的令牌桶限速，IPv6 按 /64 计算，客户端通常拥有整个 /64
+type Limiter struct {
+	conf      LimitConf
+	resolver  *ClientIPResolver
+	allow     []*net.IPNet
+	deny      []*net.IPNet
+	mutex     sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+}
+
+func NewLimiter(conf LimitConf, resolver *ClientIPResolver) (limiter *Limiter, err error) {
+	limiter = &Limiter{conf: conf, resolver: resolver, buckets: make(map[string]*tokenBucket), lastSweep: time.Now()}
+	limiter.allow, err = parseCIDRs("limit.allow", conf.Allow)
+	if err != nil {
+		return nil, err
+	}
+	limiter.deny, err = parseCIDRs("limit.deny", conf.Deny)
+	if err != nil {
+		return nil, err
+	}
+	if limiter.conf.Burst <= 0 {
+		limiter.conf.Burst = int(math.Ceil(conf.Rate))
+	}
+	if limiter.conf.MaxBodyBytes <= 0 {
+		limiter.conf.MaxBodyBytes = 64 << 10
+	}
+	return
+}
+
+// Wrap 被拒绝的地址返回 403，超过速率返回 429 并在 Retry-After 中给出需要等待的秒数
+func (limiter *Limiter) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		ipAddr := limiter.resolver.ClientIP(req)
+		ip := net.ParseIP(ipAddr)
+		if containsIP(limiter.deny, ip) || (len(limiter.allow) > 0 && !containsIP(limiter.allow, ip)) {
+			logger.Debug(i18n.T(i18n.MsgLimitDenied), "ip", ipAddr, "remote_addr", req.RemoteAddr)
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return
+		}
+		if ok, retryAfter := limiter.take(ip, time.Now()); !ok {
+			logger.Debug(i18n.T(i18n.MsgLimitExceeded), "ip", ipAddr, "retry_after", retryAfter, "remote_addr", req.RemoteAddr)
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		req.Body = http.MaxBytesReader(w, req.Body, limiter.conf.MaxBodyBytes)
+		next.ServeHTTP(w, req)
+	})
+}
+
+// take 取出一个令牌，不足时返回还需等待的时间
+func (limiter *Limiter) take(ip net.IP, now time.Time) (ok bool, retryAfter time.Duration) {
+	if limiter.conf.Rate <= 0 || ip == nil {
+		return true, 0
+	}
+	key := ip.String()
+	if ip.To4() == nil {
+		key = ip.Mask(net.CIDRMask(64, 128)).String()
+	}
+	burst := float64(limiter.conf.Burst)
+	limiter.mutex.Lock()
+	defer limiter.mutex.Unlock()
+	if now.Sub(limiter.lastSweep) >= limitSweepInterval {
+		limiter.sweep(now, burst)
+	}
+	bucket, found := limiter.buckets[key]
+	if !found {
+		bucket = &tokenBucket{tokens: burst, last: now}
+		limiter.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(burst, bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.conf.Rate)
+	bucket.last = now
+	if bucket.tokens < 1 {
+		return false, time.Duration((1 - bucket.tokens) / limiter.conf.Rate * float64(time.Second))
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep 删除已经回满的令牌桶，与新建的桶等价
+func (limiter *Limiter) sweep(now time.Time, burst float64) {
+	for key, bucket := range limiter.buckets {
+		if bucket.tokens+now.Sub(bucket.last).Seconds()*limiter.conf.Rate >= burst {
+			delete(limiter.buckets, key)
+		}
+	}
+	limiter.lastSweep = now
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"testing"
+	"time"
+)
+
+func newTestLimiter(t *testing.T, conf LimitConf) *Limiter {
+	t.Helper()
+	resolver, err := NewClientIPResolver(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	limiter, err := NewLimiter(conf, resolver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return limiter
+}
+
+func TestLimiterTake(t *testing.T) {
+	limiter := newTestLimiter(t, LimitConf{Rate: 2, Burst: 3})
+	start := time.Now()
+	limiter.lastSweep = start
+	// 按顺序执行，每秒补充 2 个令牌，最多 3 个
+	tests := []struct {
+		name      string
+		after     time.Duration
+		ip        string
+		wantOK    bool
+		wantRetry time.Duration
+	}{
+		{"burst 1", 0, "192.0.2.1", true, 0},
+		{"burst 2", 0, "192.0.2.1", true, 0},
+		{"burst 3", 0, "192.0.2.1", true, 0},
+		{"empty", 0, "192.0.2.1", false, 500 * time.Millisecond},
+		{"other IPv4 address", 0, "192.0.2.2", true, 0},
+		{"half refilled", 250 * time.Millisecond, "192.0.2.1", false, 250 * time.Millisecond},
+		{"refilled one", 500 * time.Millisecond, "192.0.2.1", true, 0},
+		{"empty again", 500 * time.Millisecond, "192.0.2.1", false, 500 * time.Millisecond},
+		{"refill capped at burst 1", 30 * time.Second, "192.0.2.1", true, 0},
+		{"refill capped at burst 2", 30 * time.Second, "192.0.2.1", true, 0},
+		{"refill capped at burst 3", 30 * time.Second, "192.0.2.1", true, 0},
+		{"refill capped at burst 4", 30 * time.Second, "192.0.2.1", false, 500 * time.Millisecond},
+		// 同一个 /64 共用令牌桶
+		{"IPv6 1", 0, "2001:db8::1", true, 0},
+		{"IPv6 same /64", 0, "2001:db8::ffff:1", true, 0},
+		{"IPv6 same /64 interface ID", 0, "2001:db8:0:0:1:2:3:4", true, 0},
+		{"IPv6 /64 empty", 0, "2001:db8::2", false, 500 * time.Millisecond},
+		{"IPv6 other /64", 0, "2001:db8:0:1::1", true, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ok, retryAfter := limiter.take(net.ParseIP(tt.ip), start.Add(tt.after))
+			if ok != tt.wantOK || retryAfter != tt.wantRetry {
+				t.Errorf("take() = %v, %v, want %v, %v", ok, retryAfter, tt.wantOK, tt.wantRetry)
+			}
+		})
+	}
+}
+
+func TestLimiterUnlimited(t *testing.T) {
+	limiter := newTestLimiter(t, LimitConf{})
+	now := time.Now()
+	for i := 0; i < 100; i++ {
+		if ok, _ := limiter.take(net.ParseIP("192.0.2.1"), now); !ok {
+			t.Fatalf("take() %d with rate 0 = false", i)
+		}
+	}
+	// 无法解析客户端 IP 时不限速
+	limiter = newTestLimiter(t, LimitConf{Rate: 1, Burst: 1})
+	for i := 0; i < 3; i++ {
+		if ok, _ := limiter.take(nil, now); !ok {
+			t.Fatalf("take() %d without an IP = false", i)
+		}
+	}
+	if len(limiter.buckets) != 0 {
+		t.Errorf("buckets = %v", limiter.buckets)
+	}
+}
+
+func TestLimiterSweep(t *testing.T) {
+	limiter := newTestLimiter(t, LimitConf{Rate: 2, Burst: 3})
+	start := time.Now()
+	limiter.lastSweep = start
+	limiter.take(net.ParseIP("192.0.2.1"), start)
+	for i := 0; i < 3; i++ {
+		limiter.take(net.ParseIP("192.0.2.2"), start.Add(limitSweepInterval-100*time.Millisecond))
+	}
+	// 间隔到达后的第一次 take 清理已回满的桶，还没回满的保留
+	limiter.take(net.ParseIP("192.0.2.3"), start.Add(limitSweepInterval))
+	var keys []string
+	for key := range limiter.buckets {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if len(keys) != 2 || keys[0] != "192.0.2.2" || keys[1] != "192.0.2.3" {
+		t.Errorf("buckets = %v, want 192.0.2.2 and 192.0.2.3", keys)
+	}
+	if !limiter.lastSweep.Equal(start.Add(limitSweepInterval)) {
+		t.Errorf("lastSweep = %v", limiter.lastSweep)
+	}
+	// 清理不影响仍在等待的桶
+	if ok, retryAfter := limiter.take(net.ParseIP("192.0.2.2"), start.Add(limitSweepInterval)); ok || retryAfter != 400*time.Millisecond {
+		t.Errorf("take() = %v, %v, want false, 400ms", ok, retryAfter)
+	}
+}
+
+func TestLimiterWrap(t *testing.T) {
+	tests := []struct {
+		name       string
+		conf       LimitConf
+		remoteAddr string
+		wantStatus int
+	}{
+		{"allowed", LimitConf{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.128/25"}}, "192.0.2.1:1234", http.StatusOK},
+		{"deny over allow", LimitConf{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.128/25"}}, "192.0.2.200:1234", http.StatusForbidden},
+		{"not in allow", LimitConf{Allow: []string{"192.0.2.0/24"}}, "198.51.100.1:1234", http.StatusForbidden},
+		{"deny without allow", LimitConf{Deny: []string{"2001:db8::/32"}}, "[2001:db8::1]:1234", http.StatusForbidden},
+		{"other without allow", LimitConf{Deny: []string{"2001:db8::/32"}}, "198.51.100.1:1234", http.StatusOK},
+		{"single IP deny", LimitConf{Allow: []string{"192.0.2.0/24"}, Deny: []string{"192.0.2.1"}}, "192.0.2.1:1234", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newTestLimiter(t, tt.conf)
+			handler := limiter.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			if w.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", w.Code, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestLimiterRetryAfter(t *testing.T) {
+	// 每秒 0.4 个令牌，用完后需要等待 2.5 秒，Retry-After 向上取整
+	limiter := newTestLimiter(t, LimitConf{Rate: 0.4, Burst: 1})
+	handler := limiter.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Fatalf("request %d status = %d, want %d", i, w.Code, want)
+		}
+		if want == http.StatusTooManyRequests && w.Header().Get("Retry-After") != "3" {
+			t.Errorf("Retry-After = %q, want 3", w.Header().Get("Retry-After"))
+		}
+	}
+	// 被拒绝的地址不消耗令牌，也没有 Retry-After
+	limiter = newTestLimiter(t, LimitConf{Rate: 0.4, Burst: 1, Deny: []string{"192.0.2.1"}})
+	handler = limiter.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden || w.Header().Get("Retry-After") != "" || len(limiter.buckets) != 0 {
+		t.Errorf("denied status = %d, Retry-After = %q, buckets = %d", w.Code, w.Header().Get("Retry-After"), len(limiter.buckets))
+	}
+}
@@ -0,0 +1,97 @@
+package server
+
+import (
+	"context"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+)
+
+// ListenerConf network 为 tcp (双栈) tcp4 tcp6 或 unix，address 为地址或 socket 文件路径
+// tls 使用 server.json 中 tls 的证书，socket_mode 为 socket 文件的八进制权限 (例如 "0660")
+type ListenerConf struct {
+	Network       string `json:"network"`
+	Address       string `json:"address"`
+	TLS           bool   `json:"tls"`
+	ProxyProtocol bool   `json:"proxy_protocol"`
+	SocketMode    string `json:"socket_mode,omitempty"`
+}
+
+// localConnKey 通过 Unix socket 连接的请求来自本机的反向代理，视为受信任代理
+type localConnKey struct{}
+
+// ListenerConfs listeners 为空时使用 port、tls.enable 和 proxy_protocol，兼容旧的配置文件
+// 启用 ACME 时至少需要一个 TLS 监听地址，否则证书不会被申请
+func (conf ServerConf) ListenerConfs() (listeners []ListenerConf, err error) {
+	listeners = conf.Listeners
+	if len(listeners) == 0 {
+		listeners = []ListenerConf{{Network: "tcp", Address: conf.Port, TLS: conf.TLS.Enable, ProxyProtocol: conf.ProxyProtocol}}
+	}
+	tls := false
+	for _, listener := range listeners {
+		tls = tls || listener.TLS
+	}
+	if conf.TLS.ACME.Enable && !tls {
+		return nil, i18n.NewError(i18n.MsgACMENoTLSListener)
+	}
+	for _, listener := range conf.Listeners {
+		switch listener.Network {
+		case "tcp", "tcp4", "tcp6", "unix":
+		default:
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "listeners.network")
+		}
+		if listener.Address == "" {
+			return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "listeners.address")
+		}
+		if listener.SocketMode != "" {
+			if _, parseErr := strconv.ParseUint(listener.SocketMode, 8, 32); parseErr != nil {
+				return nil, i18n.NewError(i18n.MsgConfCheckFields, ConfDirectoryName+"/"+ConfFileName, "listeners.socket_mode")
+			}
+		}
+	}
+	return
+}
+
+// Serve 监听并阻塞处理请求，httpServer 每个监听地址各自使用一个
+// tls 为 true 时 certs 不能为 nil
+func (lc ListenerConf) Serve(httpServer *http.Server, resolver *ClientIPResolver, certs *CertManager) (err error) {
+	// TCP 使用 PROXY 协议时必须指定受信任代理，Unix socket 只能由本机连接
+	if lc.ProxyProtocol && lc.Network != "unix" && !resolver.HasTrusted() {
+		return i18n.NewError(i18n.MsgProxyProtocolNoTrusted)
+	}
+	if lc.Network == "unix" {
+		// 删除上次退出时残留的 socket 文件，不删除普通文件
+		if info, statErr := os.Lstat(lc.Address); statErr == nil && info.Mode()&os.ModeSocket != 0 {
+			_ = os.Remove(lc.Address)
+		}
+	}
+	listener, err := net.Listen(lc.Network, lc.Address)
+	if err != nil {
+		return
+	}
+	if lc.Network == "unix" && lc.SocketMode != "" {
+		mode, _ := strconv.ParseUint(lc.SocketMode, 8, 32)
+		err = os.Chmod(lc.Address, os.FileMode(mode))
+		if err != nil {
+			_ = listener.Close()
+			return
+		}
+	}
+	if lc.ProxyProtocol {
+		listener = ProxyProtoListener{Listener: listener, Resolver: resolver}
+	} else if lc.Network == "unix" {
+		// 使用 PROXY 协议时地址来自协议头，不能再信任转发请求头
+		httpServer.ConnContext = func(ctx context.Context, _ net.Conn) context.Context {
+			return context.WithValue(ctx, localConnKey{}, true)
+		}
+	}
+	logger.Info(i18n.T(i18n.MsgServerListen), "network", lc.Network, "addr", lc.Address, "tls", lc.TLS, "proxy_protocol", lc.ProxyProtocol)
+	if lc.TLS {
+		httpServer.TLSConfig = certs.TLSConfig()
+		return httpServer.ServeTLS(listener, "", "")
+	}
+	return httpServer.Serve(listener)
+}
@@ -0,0 +1,47 @@
+package server
+
+import (
+	"ddns-watchdog/internal/i18n"
+	"errors"
+	"testing"
+)
+
+func TestListenerConfs(t *testing.T) {
+	tests := []struct {
+		name   string
+		conf   ServerConf
+		wantID i18n.MessageID
+		want   int
+	}{
+		{"legacy port", ServerConf{Port: ":10032"}, "", 1},
+		{"legacy port with ACME", ServerConf{Port: ":443", TLS: TLSConf{Enable: true, ACME: ACMEConf{Enable: true}}}, "", 1},
+		{"ACME without TLS on the legacy port", ServerConf{Port: ":10032", TLS: TLSConf{ACME: ACMEConf{Enable: true}}}, i18n.MsgACMENoTLSListener, 0},
+		{"ACME without TLS listeners", ServerConf{
+			Listeners: []ListenerConf{{Network: "tcp4", Address: ":10032"}, {Network: "unix", Address: "/run/ddns.sock"}},
+			// tls.enable 只用于未设置 listeners 的旧配置
+			TLS: TLSConf{Enable: true, ACME: ACMEConf{Enable: true}},
+		}, i18n.MsgACMENoTLSListener, 0},
+		{"ACME with a TLS listener", ServerConf{
+			Listeners: []ListenerConf{{Network: "tcp4", Address: ":10032"}, {Network: "tcp", Address: ":443", TLS: true}},
+			TLS:       TLSConf{ACME: ACMEConf{Enable: true}},
+		}, "", 2},
+		{"bad network", ServerConf{Listeners: []ListenerConf{{Network: "udp", Address: ":10032"}}}, i18n.MsgConfCheckFields, 0},
+		{"missing address", ServerConf{Listeners: []ListenerConf{{Network: "tcp"}}}, i18n.MsgConfCheckFields, 0},
+		{"bad socket mode", ServerConf{Listeners: []ListenerConf{{Network: "unix", Address: "/run/ddns.sock", SocketMode: "0999"}}}, i18n.MsgConfCheckFields, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			listeners, err := tt.conf.ListenerConfs()
+			if tt.wantID == "" {
+				if err != nil || len(listeners) != tt.want {
+					t.Fatalf("ListenerConfs() = %v, %v, want %d listeners", listeners, err, tt.want)
+				}
+				return
+			}
+			var i18nErr *i18n.Error
+			if !errors.As(err, &i18nErr) || i18nErr.ID != tt.wantID {
+				t.Fatalf("ListenerConfs() error = %v, want %s", err, tt.wantID)
+			}
+		})
+	}
+}
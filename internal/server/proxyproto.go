@@ -0,0 +1,149 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"ddns-watchdog/internal/i18n"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// proxyProtoV2Signature PROXY 协议 v2 头部的固定前缀
+var proxyProtoV2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+// proxyProtoTimeout 读取 PROXY 协议头的超时时间
+const proxyProtoTimeout = 10 * time.Second
+
+// ProxyProtoListener 接受来自受信任代理的 PROXY 协议 v1 和 v2 连接
+// 来自受信任代理和 Unix socket 的连接必须带有 PROXY 协议头，其他连接不解析，原样传递
+type ProxyProtoListener struct {
+	net.Listener
+	Resolver *ClientIPResolver
+}
+
+func (listener ProxyProtoListener) Accept() (net.Conn, error) {
+	conn, err := listener.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// Unix socket 只能由本机的代理连接
+	if conn.RemoteAddr().Network() != "unix" && !listener.Resolver.IsTrusted(parseHostIP(conn.RemoteAddr().String())) {
+		return conn, nil
+	}
+	return &proxyProtoConn{Conn: conn, reader: bufio.NewReader(conn)}, nil
+}
+
+// proxyProtoConn 在第一次读取或获取地址时解析协议头，避免阻塞 Accept
+type proxyProtoConn struct {
+	net.Conn
+	reader *bufio.Reader
+	once   sync.Once
+	remote net.Addr
+	err    error
+}
+
+func (conn *proxyProtoConn) Read(b []byte) (int, error) {
+	conn.once.Do(conn.readHeader)
+	if conn.err != nil {
+		return 0, conn.err
+	}
+	return conn.reader.Read(b)
+}
+
+func (conn *proxyProtoConn) RemoteAddr() net.Addr {
+	conn.once.Do(conn.readHeader)
+	if conn.remote != nil {
+		return conn.remote
+	}
+	return conn.Conn.RemoteAddr()
+}
+
+func (conn *proxyProtoConn) readHeader() {
+	_ = conn.Conn.SetReadDeadline(time.Now().Add(proxyProtoTimeout))
+	defer func() {
+		_ = conn.Conn.SetReadDeadline(time.Time{})
+	}()
+	prefix, err := conn.reader.Peek(len(proxyProtoV2Signature))
+	switch {
+	case err == nil && bytes.Equal(prefix, proxyProtoV2Signature):
+		conn.remote, err = readProxyProtoV2(conn.reader)
+	case len(prefix) >= 6 && string(prefix[:6]) == "PROXY ":
+		conn.remote, err = readProxyProtoV1(conn.reader)
+	case err == nil:
+		err = errors.New("missing header")
+	}
+	if err != nil {
+		conn.err = i18n.NewError(i18n.MsgProxyProtocolHeader, conn.Conn.RemoteAddr(), err)
+	}
+}
+
+// readProxyProtoV1 解析 "PROXY TCP4 源地址 目标地址 源端口 目标端口\r\n"，UNKNOWN 时返回 nil
+func readProxyProtoV1(reader *bufio.Reader) (addr net.Addr, err error) {
+	// v1 头部最长 107 字节
+	line := make([]byte, 0, 107)
+	for {
+		var b byte
+		b, err = reader.ReadByte()
+		if err != nil {
+			return
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= 107 {
+			return nil, errors.New("v1 header too long")
+		}
+	}
+	fields := strings.Fields(strings.TrimSuffix(string(line), "\r\n"))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, errors.New("bad v1 header")
+	}
+	ip := net.ParseIP(fields[2])
+	port, portErr := strconv.Atoi(fields[4])
+	if ip == nil || portErr != nil || port < 0 || port > 65535 {
+		return nil, errors.New("bad v1 address")
+	}
+	return &net.TCPAddr{IP: ip, Port: port}, nil
+}
+
+// readProxyProtoV2 解析二进制头部，LOCAL 命令和非 TCP/UDP 地址族时返回 nil
+func readProxyProtoV2(reader *bufio.Reader) (addr net.Addr, err error) {
+	header := make([]byte, 16)
+	if _, err = io.ReadFull(reader, header); err != nil {
+		return
+	}
+	if header[12]>>4 != 2 {
+		return nil, errors.New("bad v2 version")
+	}
+	payload := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	if _, err = io.ReadFull(reader, payload); err != nil {
+		return
+	}
+	// 0x0 为 LOCAL，例如代理自身的健康检查
+	if header[12]&0x0f == 0 {
+		return nil, nil
+	}
+	switch header[13] >> 4 {
+	case 1:
+		if len(payload) < 12 {
+			return nil, errors.New("short v2 IPv4 address")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:4]), Port: int(binary.BigEndian.Uint16(payload[8:10]))}, nil
+	case 2:
+		if len(payload) < 36 {
+			return nil, errors.New("short v2 IPv6 address")
+		}
+		return &net.TCPAddr{IP: net.IP(payload[0:16]), Port: int(binary.BigEndian.Uint16(payload[32:34]))}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,174 @@
+package server
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+// proxyProtoV2Header 生成 PROXY 协议 v2 头部，command 0x0 为 LOCAL，0x1 为 PROXY
+func proxyProtoV2Header(command byte, src, dst net.IP, srcPort, dstPort uint16) []byte {
+	header := append([]byte(nil), proxyProtoV2Signature...)
+	var family byte
+	var addrs []byte
+	switch {
+	case src == nil:
+	case src.To4() != nil:
+		family = 0x11
+		addrs = append(append(addrs, src.To4()...), dst.To4()...)
+	default:
+		family = 0x21
+		addrs = append(append(addrs, src.To16()...), dst.To16()...)
+	}
+	if src != nil {
+		addrs = append(addrs, uint16Bytes(srcPort)...)
+		addrs = append(addrs, uint16Bytes(dstPort)...)
+	}
+	header = append(header, 0x20|command, family)
+	header = append(header, uint16Bytes(uint16(len(addrs)))...)
+	return append(header, addrs...)
+}
+
+func uint16Bytes(v uint16) []byte {
+	b := make([]byte, 2)
+	binary.BigEndian.PutUint16(b, v)
+	return b
+}
+
+func TestProxyProtoListener(t *testing.T) {
+	const body = "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"
+	tests := []struct {
+		name       string
+		trusted    []string
+		header     string
+		wantRemote string // 为空时为实际连接的地址
+		wantBody   string
+		wantErr    bool
+	}{
+		{
+			name:       "v1 tcp4",
+			header:     "PROXY TCP4 198.51.100.1 10.0.0.1 4711 80\r\n",
+			wantRemote: "198.51.100.1:4711",
+			wantBody:   body,
+		},
+		{
+			name:       "v1 tcp6",
+			header:     "PROXY TCP6 2001:db8::1 2001:db8::2 4711 443\r\n",
+			wantRemote: "[2001:db8::1]:4711",
+			wantBody:   body,
+		},
+		{
+			name:     "v1 unknown",
+			header:   "PROXY UNKNOWN\r\n",
+			wantBody: body,
+		},
+		{
+			name:    "v1 bad address",
+			header:  "PROXY TCP4 example.com 10.0.0.1 4711 80\r\n",
+			wantErr: true,
+		},
+		{
+			name:    "v1 too long",
+			header:  "PROXY TCP4 " + strings.Repeat("1", 120) + "\r\n",
+			wantErr: true,
+		},
+		{
+			name:       "v2 inet",
+			header:     string(proxyProtoV2Header(0x1, net.ParseIP("198.51.100.2"), net.ParseIP("10.0.0.1"), 4712, 80)),
+			wantRemote: "198.51.100.2:4712",
+			wantBody:   body,
+		},
+		{
+			name:       "v2 inet6",
+			header:     string(proxyProtoV2Header(0x1, net.ParseIP("2001:db8::3"), net.ParseIP("2001:db8::2"), 4713, 443)),
+			wantRemote: "[2001:db8::3]:4713",
+			wantBody:   body,
+		},
+		{
+			// LOCAL 命令使用实际连接的地址，例如代理的健康检查
+			name:     "v2 local",
+			header:   string(proxyProtoV2Header(0x0, nil, nil, 0, 0)),
+			wantBody: body,
+		},
+		{
+			name:    "v2 bad version",
+			header:  string(append(append([]byte(nil), proxyProtoV2Signature...), 0x11, 0x11, 0, 0)),
+			wantErr: true,
+		},
+		{
+			// 受信任代理的连接必须带有 PROXY 协议头
+			name:    "trusted peer without header",
+			wantErr: true,
+		},
+		{
+			// 不受信任的连接不解析，协议头原样传递
+			name:     "untrusted peer",
+			trusted:  []string{"10.0.0.0/8"},
+			header:   "PROXY TCP4 198.51.100.1 10.0.0.1 4711 80\r\n",
+			wantBody: "PROXY TCP4 198.51.100.1 10.0.0.1 4711 80\r\n" + body,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			trusted := tt.trusted
+			if trusted == nil {
+				trusted = []string{"127.0.0.1"}
+			}
+			resolver, err := NewClientIPResolver(trusted)
+			if err != nil {
+				t.Fatalf("NewClientIPResolver() error = %v", err)
+			}
+			inner, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatalf("Listen() error = %v", err)
+			}
+			listener := ProxyProtoListener{Listener: inner, Resolver: resolver}
+			defer listener.Close()
+
+			client, err := net.Dial("tcp", inner.Addr().String())
+			if err != nil {
+				t.Fatalf("Dial() error = %v", err)
+			}
+			defer client.Close()
+			clientErr := make(chan error, 1)
+			go func() {
+				_, writeErr := client.Write([]byte(tt.header + body))
+				_ = client.(*net.TCPConn).CloseWrite()
+				clientErr <- writeErr
+			}()
+
+			conn, err := listener.Accept()
+			if err != nil {
+				t.Fatalf("Accept() error = %v", err)
+			}
+			remote := conn.RemoteAddr().String()
+			data, readErr := io.ReadAll(conn)
+			_ = conn.Close()
+			if err = <-clientErr; err != nil {
+				t.Fatalf("client error = %v", err)
+			}
+
+			if tt.wantErr {
+				if readErr == nil {
+					t.Errorf("Read() error = nil, data = %q", data)
+				}
+				return
+			}
+			if readErr != nil {
+				t.Fatalf("Read() error = %v", readErr)
+			}
+			wantRemote := tt.wantRemote
+			if wantRemote == "" {
+				wantRemote = client.LocalAddr().String()
+			}
+			if remote != wantRemote {
+				t.Errorf("RemoteAddr() = %s, want %s", remote, wantRemote)
+			}
+			if string(data) != tt.wantBody {
+				t.Errorf("data = %q, want %q", data, tt.wantBody)
+			}
+		})
+	}
+}
@@ -2,14 +2,14 @@ package server
 
 import (
 	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
 	"os"
-	"strings"
+	"time"
 )
 
 const (
@@ -22,89 +22,92 @@ var (
 	ConfDirectoryName = "conf"
 )
 
+// TLSConf certificates 为按 SNI 选择的其他证书，min_version 默认为 1.2
+// redirect_address 不为空时在该地址将 HTTP 重定向到 HTTPS 的 redirect_port
+// client_ca_file 不为空时要求客户端提供由该 CA 签发的证书
 type TLSConf struct {
-	Enable   bool   `json:"enable"`
-	CertFile string `json:"cert_file"`
-	KeyFile  string `json:"key_file"`
+	Enable          bool       `json:"enable"`
+	CertFile        string     `json:"cert_file"`
+	KeyFile         string     `json:"key_file"`
+	Certificates    []CertConf `json:"certificates"`
+	MinVersion      string     `json:"min_version"`
+	CipherSuites    []string   `json:"cipher_suites"`
+	RedirectAddress string     `json:"redirect_address"`
+	RedirectPort    string     `json:"redirect_port"`
+	ClientCAFile    string     `json:"client_ca_file"`
+	ACME            ACMEConf   `json:"acme"`
 }
 
 type ServerConf struct {
-	Port           string  `json:"port"`
-	IsRoot         bool    `json:"is_root"`
-	RootServerAddr string  `json:"root_server_addr"`
-	TLS            TLSConf `json:"tls"`
+	Port                  string         `json:"port"`
+	IsRoot                bool           `json:"is_root"`
+	RootServerAddr        string         `json:"root_server_addr"`
+	VersionRefreshMinutes int            `json:"version_refresh_minutes"`
+	TLS                   TLSConf        `json:"tls"`
+	AuthTokens            []string       `json:"auth_tokens"`
+	TrustedProxies        []string       `json:"trusted_proxies"`
+	ProxyProtocol         bool           `json:"proxy_protocol"`
+	Listeners             []ListenerConf `json:"listeners"`
+	DDNS                  DDNSConf       `json:"ddns"`
+	DNS                   DNSConf        `json:"dns"`
+	Limit                 LimitConf      `json:"limit"`
+	Log                   logger.Conf    `json:"log"`
+	Locale                string         `json:"locale"`
 }
 
 func (conf ServerConf) GetLatestVersion() (str string) {
 	if !conf.IsRoot {
-		resp, err := http.Get(conf.RootServerAddr)
+		version, err := conf.fetchLatestVersion()
 		if err != nil {
-			return "N/A (请检查网络连接)"
+			return "N/A (" + err.Error() + ")"
 		}
-		defer func(Body io.ReadCloser) {
-			err = Body.Close()
-			if err != nil {
-				str = err.Error()
-			}
-		}(resp.Body)
-		recvJson, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return "N/A (数据包错误)"
-		}
-		recv := common.PublicInfo{}
-		err = json.Unmarshal(recvJson, &recv)
-		if err != nil {
-			return "N/A (数据包错误)"
-		}
-		if recv.Version == "" {
-			return "N/A (没有获取到版本信息)"
-		}
-		return recv.Version
+		return version
 	}
 	return common.LocalVersion
 }
 
-func (conf ServerConf) CheckLatestVersion() {
-	if !conf.IsRoot {
-		LatestVersion := conf.GetLatestVersion()
-		common.VersionTips(LatestVersion)
-	} else {
-		fmt.Println("本机是根服务器")
-		fmt.Println("当前版本 ", common.LocalVersion)
+// fetchLatestVersion 从 root_server_addr 获取最新版本
+func (conf ServerConf) fetchLatestVersion() (version string, err error) {
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	resp, err := httpClient.Get(conf.RootServerAddr)
+	if err != nil {
+		return "", i18n.NewError(i18n.MsgVersionNetworkError)
 	}
-}
-
-func GetClientIP(req *http.Request) (ipAddr string) {
-	ipAddr = req.Header.Get("X-Forwarded-For")
-	if ipAddr != "" && strings.Contains(ipAddr, ",") {
-		// 如果只取第零个切片，这行其实可有可无
-		//ipAddr = strings.ReplaceAll(ipAddr, " ", "")
-		ipAddr = strings.Split(ipAddr, ",")[0]
+	defer func(Body io.ReadCloser) {
+		t := Body.Close()
+		if t != nil && err == nil {
+			err = t
+		}
+	}(resp.Body)
+	recvJson, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", i18n.NewError(i18n.MsgVersionBadPacket)
 	}
-	if ipAddr == "" {
-		ipAddr = req.Header.Get("X-Real-IP")
+	recv := common.PublicInfo{}
+	err = json.Unmarshal(recvJson, &recv)
+	if err != nil {
+		return "", i18n.NewError(i18n.MsgVersionBadPacket)
 	}
-	if ipAddr == "" {
-		// 只保留 ip:port 的 ip
-		if strings.Contains(req.RemoteAddr, "[") {
-			// IPv6
-			ipAddr = strings.Split(req.RemoteAddr[1:], "]:")[0]
-		} else {
-			// IPv4
-			ipAddr = strings.Split(req.RemoteAddr, ":")[0]
-		}
+	if recv.Version == "" {
+		return "", i18n.NewError(i18n.MsgVersionMissing)
 	}
+	return recv.Version, nil
+}
 
-	// IPv6 转格式 和 :: 解压
-	if strings.Contains(ipAddr, ":") {
-		ipAddr = common.DecodeIPv6(ipAddr)
+func (conf ServerConf) CheckLatestVersion() {
+	if !conf.IsRoot {
+		LatestVersion := conf.GetLatestVersion()
+		common.VersionTips(LatestVersion)
+	} else {
+		fmt.Println(i18n.T(i18n.MsgVersionLocal), common.LocalVersion)
+		fmt.Println(i18n.T(i18n.MsgVersionProjectUrl), common.ProjectUrl)
+		fmt.Println("\n" + i18n.T(i18n.MsgRootServer))
 	}
-	return
 }
 
 func Install() (err error) {
 	if common.IsWindows() {
-		err = errors.New("windows 暂不支持安装到系统")
+		err = i18n.NewError(i18n.MsgWindowsInstallUnsupported)
 	} else {
 		// 注册系统服务
 		wd, err := os.Getwd()
@@ -128,14 +131,14 @@ func Install() (err error) {
 			return err
 
 		}
-		log.Println("可以使用 systemctl 控制 " + RunningName + " 服务了")
+		logger.Info(i18n.T(i18n.MsgInstalled, RunningName), "path", InstallPath)
 	}
 	return
 }
 
 func Uninstall() (err error) {
 	if common.IsWindows() {
-		err = errors.New("windows 暂不支持安装到系统")
+		err = i18n.NewError(i18n.MsgWindowsInstallUnsupported)
 	} else {
 		wd, err := os.Getwd()
 		if err != nil {
@@ -145,8 +148,8 @@ func Uninstall() (err error) {
 		if err != nil {
 			return err
 		}
-		log.Println("卸载服务成功")
-		log.Println("若要完全删除，请移步到 " + wd + " 和 " + ConfDirectoryName + " 完全删除")
+		logger.Info(i18n.T(i18n.MsgUninstalled), "path", InstallPath)
+		logger.Info(i18n.T(i18n.MsgUninstallHint, wd, ConfDirectoryName))
 	}
 	return
 }
@@ -0,0 +1,116 @@
+package server
+
+import (
+	"ddns-watchdog/internal/common"
+	"ddns-watchdog/internal/i18n"
+	"ddns-watchdog/internal/logger"
+	"sync"
+	"time"
+)
+
+// versionRetryInterval 获取失败后重试的间隔，短于正常的刷新间隔
+const versionRetryInterval = time.Minute
+
+// VersionCache 非根服务器在后台定时从 root_server_addr 获取最新版本，响应请求时直接使用缓存
+// 缓存过期后先返回旧值，同时在后台刷新；获取失败时保留上一次成功的结果
+type VersionCache struct {
+	conf        ServerConf
+	interval    time.Duration
+	mutex       sync.Mutex
+	version     string
+	lastErr     error
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	fetching    bool
+	refreshed   chan time.Duration
+}
+
+// NewVersionCache version_refresh_minutes 为 0 时每 60 分钟刷新
+func NewVersionCache(conf ServerConf) *VersionCache {
+	interval := time.Duration(conf.VersionRefreshMinutes) * time.Minute
+	if interval <= 0 {
+		interval = 60 * time.Minute
+	}
+	return &VersionCache{conf: conf, interval: interval, refreshed: make(chan time.Duration, 1)}
+}
+
+// Start 立即获取一次，之后按间隔刷新，根服务器不需要刷新
+func (cache *VersionCache) Start() {
+	if cache.conf.IsRoot {
+		return
+	}
+	go func() {
+		wait := time.Duration(0)
+		for {
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+				if cache.begin() {
+					wait = cache.fetch()
+				} else {
+					wait = cache.interval
+				}
+			case wait = <-cache.refreshed:
+				// 由 Get 触发的刷新已经完成，重新计时
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+// Get 返回缓存的版本，还没有获取成功时返回与 GetLatestVersion 相同格式的错误信息
+func (cache *VersionCache) Get() string {
+	if cache.conf.IsRoot {
+		return common.LocalVersion
+	}
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	stale := time.Since(cache.fetchedAt) >= cache.interval && time.Since(cache.attemptedAt) >= versionRetryInterval
+	if stale && !cache.fetching {
+		cache.fetching = true
+		cache.attemptedAt = time.Now()
+		go func() {
+			wait := cache.fetch()
+			select {
+			case cache.refreshed <- wait:
+			default:
+			}
+		}()
+	}
+	if cache.version == "" {
+		if cache.lastErr != nil {
+			return "N/A (" + cache.lastErr.Error() + ")"
+		}
+		return "N/A"
+	}
+	return cache.version
+}
+
+// begin 同一时间只有一个请求发往根服务器，已经在获取时返回 false
+func (cache *VersionCache) begin() bool {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	if cache.fetching {
+		return false
+	}
+	cache.fetching = true
+	cache.attemptedAt = time.Now()
+	return true
+}
+
+// fetch 返回距下一次刷新的时间
+func (cache *VersionCache) fetch() time.Duration {
+	version, err := cache.conf.fetchLatestVersion()
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.fetching = false
+	cache.lastErr = err
+	if err != nil {
+		logger.Warn(err.Error(), "root_server_addr", cache.conf.RootServerAddr, "cached_version", cache.version)
+		return versionRetryInterval
+	}
+	cache.version = version
+	cache.fetchedAt = time.Now()
+	logger.Debug(i18n.T(i18n.MsgVersionInfo), "latest_version", version, "root_server_addr", cache.conf.RootServerAddr)
+	return cache.interval
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"ddns-watchdog/internal/common"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newRootServer 本地根服务器，每个请求按顺序使用 replies 中的一个版本，空字符串表示返回错误的数据包
+// replies 中没有版本时请求会等待，用于模拟正在进行的获取
+func newRootServer(t *testing.T, replies <-chan string) (addr string, hits *int32) {
+	t.Helper()
+	hits = new(int32)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		atomic.AddInt32(hits, 1)
+		select {
+		case version := <-replies:
+			if version == "" {
+				w.WriteHeader(http.StatusBadGateway)
+				_, _ = w.Write([]byte("bad gateway"))
+				return
+			}
+			_ = json.NewEncoder(w).Encode(common.PublicInfo{IP: "192.0.2.1", Version: version})
+		case <-time.After(5 * time.Second):
+			t.Error("unexpected request to root server")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, hits
+}
+
+// waitFetched 等待后台获取结束
+func waitFetched(t *testing.T, cache *VersionCache) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		cache.mutex.Lock()
+		done := !cache.fetching && !cache.attemptedAt.IsZero()
+		cache.mutex.Unlock()
+		if done {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("background fetch did not finish")
+}
+
+// expire 把上一次成功和尝试的时间往前移，使缓存过期
+func expire(cache *VersionCache, age time.Duration) {
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+	cache.fetchedAt = cache.fetchedAt.Add(-age)
+	cache.attemptedAt = cache.attemptedAt.Add(-age)
+}
+
+func TestVersionCacheFirstFetch(t *testing.T) {
+	replies := make(chan string, 1)
+	replies <- "v1.0.0"
+	addr, hits := newRootServer(t, replies)
+	cache := NewVersionCache(ServerConf{RootServerAddr: addr})
+	cache.Start()
+	waitFetched(t, cache)
+	if got := cache.Get(); got != "v1.0.0" {
+		t.Errorf("Get() = %q, want v1.0.0", got)
+	}
+	// 刷新间隔内不再请求根服务器
+	cache.Get()
+	if hits := atomic.LoadInt32(hits); hits != 1 {
+		t.Errorf("root hits = %d, want 1", hits)
+	}
+}
+
+func TestVersionCacheRoot(t *testing.T) {
+	cache := NewVersionCache(ServerConf{IsRoot: true, RootServerAddr: "http://127.0.0.1:1"})
+	if got := cache.Get(); got != common.LocalVersion {
+		t.Errorf("Get() = %q, want %q", got, common.LocalVersion)
+	}
+}
+
+func TestVersionCacheStaleWhileRefreshing(t *testing.T) {
+	replies := make(chan string, 1)
+	replies <- "v1.0.0"
+	addr, hits := newRootServer(t, replies)
+	cache := NewVersionCache(ServerConf{RootServerAddr: addr})
+	// 还没有获取过时返回 N/A，同时在后台获取
+	if got := cache.Get(); got != "N/A" {
+		t.Errorf("first Get() = %q, want N/A", got)
+	}
+	waitFetched(t, cache)
+
+	expire(cache, cache.interval)
+	if got := cache.Get(); got != "v1.0.0" {
+		t.Errorf("Get() during refresh = %q, want stale v1.0.0", got)
+	}
+	// 刷新还没有完成，并发的 Get 都返回旧值且不会再次请求
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got := cache.Get(); got != "v1.0.0" {
+				t.Errorf("concurrent Get() = %q, want v1.0.0", got)
+			}
+		}()
+	}
+	wg.Wait()
+	replies <- "v2.0.0"
+	waitFetched(t, cache)
+	if got := cache.Get(); got != "v2.0.0" {
+		t.Errorf("Get() after refresh = %q, want v2.0.0", got)
+	}
+	if hits := atomic.LoadInt32(hits); hits != 2 {
+		t.Errorf("root hits = %d, want 2", hits)
+	}
+}
+
+func TestVersionCacheKeepsLastGoodValue(t *testing.T) {
+	replies := make(chan string, 1)
+	replies <- "v1.0.0"
+	addr, hits := newRootServer(t, replies)
+	cache := NewVersionCache(ServerConf{RootServerAddr: addr, VersionRefreshMinutes: 30})
+	if wait := cache.fetch(); wait != 30*time.Minute {
+		t.Errorf("fetch() wait = %v, want 30m", wait)
+	}
+
+	replies <- ""
+	expire(cache, cache.interval)
+	if got := cache.Get(); got != "v1.0.0" {
+		t.Errorf("Get() = %q, want v1.0.0", got)
+	}
+	waitFetched(t, cache)
+	cache.mutex.Lock()
+	lastErr := cache.lastErr
+	cache.mutex.Unlock()
+	if lastErr == nil {
+		t.Fatal("lastErr = nil after failed fetch")
+	}
+	if got := cache.Get(); got != "v1.0.0" {
+		t.Errorf("Get() after error = %q, want last good v1.0.0", got)
+	}
+	if hits := atomic.LoadInt32(hits); hits != 2 {
+		t.Fatalf("root hits = %d, want 2", hits)
+	}
+
+	// 失败后 versionRetryInterval 内不重试
+	expire(cache, versionRetryInterval-time.Second)
+	cache.Get()
+	if hits := atomic.LoadInt32(hits); hits != 2 {
+		t.Errorf("root hits within retry interval = %d, want 2", hits)
+	}
+	expire(cache, time.Second)
+	replies <- "v1.1.0"
+	cache.Get()
+	waitFetched(t, cache)
+	if got := cache.Get(); got != "v1.1.0" {
+		t.Errorf("Get() after retry = %q, want v1.1.0", got)
+	}
+	if hits := atomic.LoadInt32(hits); hits != 3 {
+		t.Errorf("root hits = %d, want 3", hits)
+	}
+
+	// 后台刷新失败时返回重试间隔
+	replies <- ""
+	if wait := cache.fetch(); wait != versionRetryInterval {
+		t.Errorf("fetch() wait after error = %v, want %v", wait, versionRetryInterval)
+	}
+}
+
+func TestVersionCacheNoValue(t *testing.T) {
+	// 第二个请求来自 GetLatestVersion
+	replies := make(chan string, 2)
+	replies <- ""
+	replies <- ""
+	addr, _ := newRootServer(t, replies)
+	cache := NewVersionCache(ServerConf{RootServerAddr: addr})
+	cache.Get()
+	waitFetched(t, cache)
+	// 从未成功时返回与 GetLatestVersion 相同格式的错误信息
+	if got, want := cache.Get(), cache.conf.GetLatestVersion(); got != want {
+		t.Errorf("Get() = %q, want %q", got, want)
+	}
+}